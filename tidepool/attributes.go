@@ -0,0 +1,134 @@
+package tidepool
+
+import (
+	"encoding/json"
+	"math"
+)
+
+// GetAttr returns attrs[key] as a T. It reports false when the key is missing
+// or the value cannot be represented as T. Because JSON decodes every number
+// as float64, numeric values are converted between numeric types when no
+// precision is lost: a float64 of 3 is returned as int 3, but 3.5 is not.
+func GetAttr[T any](attrs Attributes, key string) (T, bool) {
+	var zero T
+	value, ok := attrs[key]
+	if !ok || value == nil {
+		return zero, false
+	}
+	if v, ok := value.(T); ok {
+		return v, true
+	}
+
+	var converted any
+	switch any(zero).(type) {
+	case int:
+		n, ok := toInt64(value)
+		if !ok || n < math.MinInt || n > math.MaxInt {
+			return zero, false
+		}
+		converted = int(n)
+	case int64:
+		n, ok := toInt64(value)
+		if !ok {
+			return zero, false
+		}
+		converted = n
+	case float64:
+		f, ok := toFloat64(value)
+		if !ok {
+			return zero, false
+		}
+		converted = f
+	case float32:
+		f, ok := toFloat64(value)
+		if !ok {
+			return zero, false
+		}
+		converted = float32(f)
+	default:
+		return zero, false
+	}
+	return converted.(T), true
+}
+
+// GetString returns the string attribute at key.
+func (r VectorResult) GetString(key string) (string, bool) {
+	return GetAttr[string](r.Attributes, key)
+}
+
+// GetFloat returns the numeric attribute at key as a float64.
+func (r VectorResult) GetFloat(key string) (float64, bool) {
+	return GetAttr[float64](r.Attributes, key)
+}
+
+// GetInt returns the numeric attribute at key as an int. It reports false for
+// numbers with a fractional part or outside the range of int.
+func (r VectorResult) GetInt(key string) (int, bool) {
+	return GetAttr[int](r.Attributes, key)
+}
+
+// GetInt64 returns the numeric attribute at key as an int64. Integers beyond
+// 2^53 are exact only when the client was created with WithUseNumber, since
+// a float64 cannot hold them.
+func (r VectorResult) GetInt64(key string) (int64, bool) {
+	return GetAttr[int64](r.Attributes, key)
+}
+
+// GetBool returns the boolean attribute at key.
+func (r VectorResult) GetBool(key string) (bool, bool) {
+	return GetAttr[bool](r.Attributes, key)
+}
+
+func toFloat64(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func toInt64(value any) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n, true
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return 0, false
+		}
+		return floatToInt64(f)
+	case float32:
+		return floatToInt64(float64(v))
+	case float64:
+		return floatToInt64(v)
+	default:
+		return 0, false
+	}
+}
+
+// floatToInt64 converts f when it is a whole number within int64 range.
+func floatToInt64(f float64) (int64, bool) {
+	if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return 0, false
+	}
+	return int64(f), true
+}
@@ -0,0 +1,118 @@
+package tidepool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVectorResultAttributeAccessors(t *testing.T) {
+	var result VectorResult
+	data := `{"id":"a","score":0.1,"attributes":{"title":"shoes","price":19.5,"stock":3,"active":true,"big":1e300}}`
+	if err := json.Unmarshal([]byte(data), &result); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+
+	if v, ok := result.GetString("title"); !ok || v != "shoes" {
+		t.Fatalf("unexpected title %q %v", v, ok)
+	}
+	if v, ok := result.GetFloat("price"); !ok || v != 19.5 {
+		t.Fatalf("unexpected price %v %v", v, ok)
+	}
+	if v, ok := result.GetInt("stock"); !ok || v != 3 {
+		t.Fatalf("unexpected stock %v %v", v, ok)
+	}
+	if v, ok := result.GetBool("active"); !ok || !v {
+		t.Fatalf("unexpected active %v %v", v, ok)
+	}
+
+	if _, ok := result.GetInt("price"); ok {
+		t.Fatalf("expected fractional number to be rejected as int")
+	}
+	if _, ok := result.GetInt("big"); ok {
+		t.Fatalf("expected out-of-range number to be rejected as int")
+	}
+	if _, ok := result.GetString("price"); ok {
+		t.Fatalf("expected number to be rejected as string")
+	}
+	if _, ok := result.GetBool("missing"); ok {
+		t.Fatalf("expected missing key to report false")
+	}
+}
+
+func TestGetAttr(t *testing.T) {
+	attrs := Attributes{"n": float64(7), "f": 2, "tags": []any{"a"}, "nil": nil}
+
+	if v, ok := GetAttr[int64](attrs, "n"); !ok || v != 7 {
+		t.Fatalf("unexpected int64 %v %v", v, ok)
+	}
+	if v, ok := GetAttr[float32](attrs, "f"); !ok || v != 2 {
+		t.Fatalf("unexpected float32 %v %v", v, ok)
+	}
+	if v, ok := GetAttr[[]any](attrs, "tags"); !ok || len(v) != 1 {
+		t.Fatalf("unexpected tags %v %v", v, ok)
+	}
+	if _, ok := GetAttr[string](attrs, "nil"); ok {
+		t.Fatalf("expected nil value to report false")
+	}
+	if v, ok := GetAttr[json.Number](Attributes{"n": json.Number("12")}, "n"); !ok || v != "12" {
+		t.Fatalf("unexpected json.Number %v %v", v, ok)
+	}
+	if v, ok := GetAttr[int](Attributes{"n": json.Number("12")}, "n"); !ok || v != 12 {
+		t.Fatalf("unexpected int from json.Number %v %v", v, ok)
+	}
+}
+
+func TestUseNumberKeepsLargeIntegers(t *testing.T) {
+	const id int64 = 1<<53 + 1
+	payload := fmt.Sprintf(`{"results":[{"id":"a","score":0.5,"attributes":{"snowflake":%d,"nested":{"n":%d},"price":19.5}}]}`, id, id)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(payload))
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	lossy, err := New(WithQueryURL(srv.URL)).Query(ctx, Vector{1}, nil)
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if v, _ := lossy.Results[0].GetInt64("snowflake"); v == id {
+		t.Fatalf("expected float64 decoding to round %d", id)
+	}
+	if _, ok := lossy.Results[0].Attributes["price"].(float64); !ok {
+		t.Fatalf("expected float64 attributes by default, got %T", lossy.Results[0].Attributes["price"])
+	}
+
+	client := New(WithQueryURL(srv.URL), WithUseNumber(true))
+	resp, err := client.Query(ctx, Vector{1}, nil)
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	seq, err := client.QueryStream(ctx, Vector{1}, nil)
+	if err != nil {
+		t.Fatalf("query stream failed: %v", err)
+	}
+	results := resp.Results
+	for result, err := range seq {
+		if err != nil {
+			t.Fatalf("stream error: %v", err)
+		}
+		results = append(results, result)
+	}
+	for _, result := range results {
+		if v, ok := result.GetInt64("snowflake"); !ok || v != id {
+			t.Fatalf("expected exact %d, got %d (%v)", id, v, ok)
+		}
+		nested, _ := result.Attributes["nested"].(map[string]any)
+		if n, _ := nested["n"].(json.Number); n.String() != fmt.Sprint(id) {
+			t.Fatalf("expected nested json.Number, got %v", nested["n"])
+		}
+		if price, ok := result.GetFloat("price"); !ok || price != 19.5 || result.Score != 0.5 {
+			t.Fatalf("unexpected price %v or score %v", price, result.Score)
+		}
+	}
+}
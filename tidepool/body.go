@@ -0,0 +1,45 @@
+package tidepool
+
+import "context"
+
+// BuildUpsertBody returns the JSON body Upsert would send for docs and opts,
+// to log, diff, or replay with curl against POST /v1/vectors/{namespace} on
+// the ingest service. Documents are validated and prepared exactly as Upsert
+// prepares them (embedding, normalization, the batch TTL, quantization, and
+// the distance metric all apply), so it can make the same embedder, health,
+// and namespace requests Upsert would. The body holds every document; when a
+// batch size is configured, Upsert sends several bodies of the same shape.
+// Compression is not applied.
+func (c *Client) BuildUpsertBody(ctx context.Context, docs []Document, opts *UpsertOptions) ([]byte, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	_, _, docs, metric, err := c.prepareUpsert(ctx, nil, docs, opts)
+	if err != nil {
+		return nil, err
+	}
+	body, err := c.upsertBody(ctx, docs, metric)
+	if err != nil {
+		return nil, err
+	}
+	data, _, _, err := c.encodeBody(body)
+	return data, err
+}
+
+// BuildQueryBody returns the JSON body Query would send for vector and opts,
+// to log, diff, or replay with curl against POST /v1/vectors/{namespace} on
+// the query service. The request is validated and built exactly as Query
+// builds it, including embedding opts.Text and resolving the distance
+// metric, so it can make the same embedder and namespace requests Query
+// would. Compression is not applied.
+func (c *Client) BuildQueryBody(ctx context.Context, vector Vector, opts *QueryOptions) ([]byte, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	_, _, req, err := c.prepareQuery(ctx, nil, vector, opts)
+	if err != nil {
+		return nil, err
+	}
+	data, _, _, err := c.encodeBody(req)
+	return data, err
+}
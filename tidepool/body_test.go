@@ -0,0 +1,65 @@
+package tidepool
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBuildBodiesMatchRequests(t *testing.T) {
+	var sent []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		sent = append(sent, string(data))
+		_, _ = w.Write([]byte(`{"results":[]}`))
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	docs := []Document{
+		{ID: "a", Vector: Vector{3, 4}, Attributes: Attributes{"z": 1, "a": "x"}},
+		{ID: "b", Text: "hello", SparseVector: &SparseVector{Indices: []uint32{2}, Values: []float32{0.5}}},
+	}
+	upsertOpts := &UpsertOptions{TTL: time.Minute, Normalize: true, DistanceMetric: DistanceCosine}
+	queryOpts := &QueryOptions{Text: "hello", TopK: 3, MinScore: Float32(0.2), Filters: Attributes{"a": "x"}, Extra: Attributes{"rescore": true}}
+
+	configs := map[string][]Option{
+		"default":   nil,
+		"metric":    {WithDefaultDistanceMetric(DistanceDotProduct)},
+		"signed":    {WithRequestSigner(func([]byte) http.Header { return nil })},
+		"quantized": {WithQuantization(QuantConfig{SkipSupportCheck: true})},
+	}
+	for name, opts := range configs {
+		sent = nil
+		client := New(append([]Option{WithBaseURL(srv.URL)}, opts...)...)
+
+		upsertBody, err := client.BuildUpsertBody(ctx, docs, upsertOpts)
+		if err != nil {
+			t.Fatalf("%s: build upsert body: %v", name, err)
+		}
+		queryBody, err := client.BuildQueryBody(ctx, Vector{1, 0}, queryOpts)
+		if err != nil {
+			t.Fatalf("%s: build query body: %v", name, err)
+		}
+		if err := client.Upsert(ctx, docs, upsertOpts); err != nil {
+			t.Fatalf("%s: upsert failed: %v", name, err)
+		}
+		if _, err := client.Query(ctx, Vector{1, 0}, queryOpts); err != nil {
+			t.Fatalf("%s: query failed: %v", name, err)
+		}
+		if len(sent) != 2 || sent[0] != string(upsertBody) || sent[1] != string(queryBody) {
+			t.Fatalf("%s: expected built bodies to match the requests\nbuilt: %s\n       %s\nsent:  %q", name, upsertBody, queryBody, sent)
+		}
+	}
+
+	client := New(WithBaseURL(srv.URL))
+	if _, err := client.BuildUpsertBody(ctx, []Document{{ID: "a"}}, nil); !IsValidationError(err) {
+		t.Fatalf("expected validation error for an invalid document, got %v", err)
+	}
+	if _, err := client.BuildQueryBody(ctx, nil, nil); !IsValidationError(err) {
+		t.Fatalf("expected validation error for a query without a vector, got %v", err)
+	}
+}
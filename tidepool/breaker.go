@@ -0,0 +1,109 @@
+package tidepool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBreakerThreshold = 5
+	defaultBreakerCooldown  = 30 * time.Second
+)
+
+// ErrCircuitOpen is returned without contacting the server while a service's
+// circuit breaker is open. It wraps ErrServiceUnavailable.
+var ErrCircuitOpen = fmt.Errorf("%w: circuit breaker open", ErrServiceUnavailable)
+
+// CircuitBreakerSettings configures per-service circuit breaking. The query
+// and ingest services are tracked independently.
+type CircuitBreakerSettings struct {
+	// FailureThreshold is the number of consecutive failures that opens the
+	// breaker. Default: 5.
+	FailureThreshold int
+	// Cooldown is how long the breaker stays open before a single probe
+	// request is let through. Default: 30s.
+	Cooldown time.Duration
+}
+
+// circuitBreaker tracks consecutive failures for one service.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func newCircuitBreaker(settings CircuitBreakerSettings) *circuitBreaker {
+	b := &circuitBreaker{
+		threshold: settings.FailureThreshold,
+		cooldown:  settings.Cooldown,
+	}
+	if b.threshold <= 0 {
+		b.threshold = defaultBreakerThreshold
+	}
+	if b.cooldown <= 0 {
+		b.cooldown = defaultBreakerCooldown
+	}
+	return b
+}
+
+// allow reports whether a request may be sent. Once the cooldown has elapsed
+// exactly one probe is allowed until its outcome is recorded.
+func (b *circuitBreaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return true
+	}
+	if b.probing || now.Sub(b.openedAt) < b.cooldown {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// record updates the breaker with the outcome of a request.
+func (b *circuitBreaker) record(ctx context.Context, err error, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case err == nil || !isServiceFailure(err):
+		b.failures = 0
+	case ctx.Err() != nil:
+		// The caller gave up; that says nothing about the service.
+	default:
+		b.failures++
+		if b.failures >= b.threshold {
+			b.openedAt = now
+		}
+	}
+	b.probing = false
+}
+
+// isServiceFailure reports whether err indicates the service itself is
+// unhealthy: transport errors and 5xx responses. Client errors such as
+// validation or not-found mean the service answered and count as success.
+func isServiceFailure(err error) bool {
+	var tideErr *TidepoolError
+	if !errors.As(err, &tideErr) {
+		return true
+	}
+	return tideErr.StatusCode >= 500
+}
+
+// breakerFor returns the circuit breaker for service, or nil when circuit
+// breaking is disabled.
+func (c *Client) breakerFor(service string) *circuitBreaker {
+	if c.breakers == nil {
+		return nil
+	}
+	return c.breakers[service]
+}
@@ -0,0 +1,118 @@
+package tidepool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerOpensAndRecovers(t *testing.T) {
+	var calls atomic.Int32
+	var healthy atomic.Bool
+	ingest := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ingest.Close()
+	query := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode([]VectorResult{{ID: "a"}})
+	}))
+	defer query.Close()
+
+	client := New(
+		WithIngestURL(ingest.URL),
+		WithQueryURL(query.URL),
+		WithCircuitBreaker(CircuitBreakerSettings{FailureThreshold: 2, Cooldown: 50 * time.Millisecond}),
+	)
+	ctx := context.Background()
+	docs := []Document{{ID: "a", Vector: Vector{0.1}}}
+
+	for i := 0; i < 2; i++ {
+		if err := client.Upsert(ctx, docs, nil); !IsServerError(err) {
+			t.Fatalf("expected server error, got %v", err)
+		}
+	}
+
+	err := client.Upsert(ctx, docs, nil)
+	if !errors.Is(err, ErrCircuitOpen) || !IsServiceUnavailableError(err) {
+		t.Fatalf("expected open circuit, got %v", err)
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("expected open circuit to skip the server, got %d calls", calls.Load())
+	}
+
+	if _, err := client.Query(ctx, Vector{0.1}, nil); err != nil {
+		t.Fatalf("expected query service to be unaffected, got %v", err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if err := client.Upsert(ctx, docs, nil); !IsServerError(err) {
+		t.Fatalf("expected failed probe to reach server, got %v", err)
+	}
+	if err := client.Upsert(ctx, docs, nil); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected circuit to reopen after failed probe, got %v", err)
+	}
+
+	healthy.Store(true)
+	time.Sleep(60 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		if err := client.Upsert(ctx, docs, nil); err != nil {
+			t.Fatalf("expected circuit to close after successful probe, got %v", err)
+		}
+	}
+	if calls.Load() != 6 {
+		t.Fatalf("expected 6 server calls, got %d", calls.Load())
+	}
+}
+
+func TestCircuitBreakerIgnoresClientErrors(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	client := New(WithIngestURL(srv.URL), WithCircuitBreaker(CircuitBreakerSettings{FailureThreshold: 1}))
+	for i := 0; i < 3; i++ {
+		if err := client.Upsert(context.Background(), []Document{{ID: "a", Vector: Vector{1}}}, nil); !IsValidationError(err) {
+			t.Fatalf("expected validation error, got %v", err)
+		}
+	}
+	if calls.Load() != 3 {
+		t.Fatalf("expected every call to reach the server, got %d", calls.Load())
+	}
+}
+
+func TestCircuitBreakerSharedBaseURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_ = json.NewEncoder(w).Encode([]VectorResult{{ID: "a"}})
+	}))
+	defer srv.Close()
+
+	client := New(WithBaseURL(srv.URL), WithCircuitBreaker(CircuitBreakerSettings{FailureThreshold: 1, Cooldown: time.Hour}))
+	ctx := context.Background()
+
+	if err := client.Delete(ctx, []string{"a"}, nil); !IsServerError(err) {
+		t.Fatalf("expected server error, got %v", err)
+	}
+	if err := client.Delete(ctx, []string{"a"}, nil); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected the ingest circuit to open, got %v", err)
+	}
+	if _, err := client.Query(ctx, Vector{0.1}, nil); err != nil {
+		t.Fatalf("expected the query circuit to stay closed with a shared base URL, got %v", err)
+	}
+}
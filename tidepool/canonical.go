@@ -0,0 +1,25 @@
+package tidepool
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// canonicalJSON encodes v with the keys of every JSON object sorted, struct
+// fields and custom MarshalJSON output included, and numbers kept exactly as
+// first encoded. encoding/json already sorts map keys; re-encoding through a
+// generic value extends that to everything else, so the result depends only
+// on the content of v.
+func canonicalJSON(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var generic any
+	if err := dec.Decode(&generic); err != nil {
+		return nil, err
+	}
+	return json.Marshal(generic)
+}
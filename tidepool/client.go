@@ -2,450 +2,2630 @@ package tidepool
 
 import (
 	"bytes"
+	"cmp"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"math"
+	"mime"
+	"net"
 	"net/http"
 	"net/url"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unicode"
 )
 
 // Client is the Tidepool API client.
 type Client struct {
 	config Config
 	http   *http.Client
+
+	dimsMu sync.Mutex
+	dims   map[string]int
+
+	metricsMu sync.Mutex
+	metrics   map[string]DistanceMetric
+
+	breakers map[string]*circuitBreaker
+
+	quantMu        sync.Mutex
+	quantSupported *bool
+
+	closed atomic.Bool
+	// done is closed by Close, to stop background goroutines such as
+	// health monitors.
+	done chan struct{}
 }
 
 // New creates a new Tidepool client.
 func New(opts ...Option) *Client {
 	cfg := Config{
-		QueryURL:         defaultQueryURL,
-		IngestURL:        defaultIngestURL,
 		Timeout:          defaultTimeout,
 		DefaultNamespace: defaultNamespace,
+		PollInterval:     defaultPollInterval,
 	}
 	for _, opt := range opts {
 		if opt != nil {
 			opt(&cfg)
 		}
 	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaultPollInterval
+	}
+	if cfg.MaxResponseBytes == 0 {
+		cfg.MaxResponseBytes = defaultMaxResponseBytes
+	}
+	if cfg.QueryURL == "" {
+		cfg.QueryURL = cmp.Or(cfg.BaseURL, defaultQueryURL)
+	}
+	if cfg.IngestURL == "" {
+		cfg.IngestURL = cmp.Or(cfg.BaseURL, defaultIngestURL)
+	}
 	if cfg.DefaultNamespace == "" && cfg.Namespace != "" {
 		cfg.DefaultNamespace = cfg.Namespace
 	}
 
+	timeout := cfg.Timeout
+	if cfg.ContextTimeoutOnly {
+		timeout = 0
+	}
+
 	httpClient := cfg.HTTPClient
 	if httpClient == nil {
 		httpClient = &http.Client{
-			Timeout: cfg.Timeout,
+			Timeout: timeout,
+		}
+		if transport := newTransport(cfg); transport != nil {
+			httpClient.Transport = transport
 		}
-	} else if cfg.Timeout > 0 {
-		httpClient.Timeout = cfg.Timeout
+	} else if timeout > 0 {
+		httpClient.Timeout = timeout
 	}
 
-	return &Client{
-		config: cfg,
-		http:   httpClient,
+	client := &Client{
+		config:  cfg,
+		http:    httpClient,
+		dims:    make(map[string]int),
+		metrics: make(map[string]DistanceMetric),
+		done:    make(chan struct{}),
 	}
+	if cfg.CircuitBreaker != nil {
+		client.breakers = map[string]*circuitBreaker{
+			"query":  newCircuitBreaker(*cfg.CircuitBreaker),
+			"ingest": newCircuitBreaker(*cfg.CircuitBreaker),
+		}
+	}
+	return client
+}
+
+// Config returns a copy of the configuration the client resolved from its
+// options: defaults filled in, service URLs derived from BaseURL, and the
+// deprecated Namespace reconciled so that it equals DefaultNamespace, the
+// namespace actually used. Slices, maps, and settings structs are copied, so
+// modifying the result does not affect the client. HTTPClient is the one
+// passed to WithHTTPClient, if any, and is not copied.
+func (c *Client) Config() Config {
+	return c.config.clone()
+}
+
+// Close releases the client's idle pooled connections and stops health
+// monitors started with StartHealthMonitor. Afterwards every request fails
+// with ErrClientClosed, including retries of calls already in flight; calls
+// already sending a request are not interrupted. Close is safe to call more
+// than once and from multiple goroutines, and always returns nil. The
+// transport is only shut down when the client built it, i.e. when
+// WithTransport, WithTLSConfig, or WithHTTP2 is used without WithHTTPClient;
+// a client passed to WithHTTPClient or the shared http.DefaultTransport is
+// left alone.
+func (c *Client) Close() error {
+	if c.closed.Swap(true) {
+		return nil
+	}
+	close(c.done)
+	if c.config.HTTPClient == nil && c.http.Transport != nil {
+		c.http.CloseIdleConnections()
+	}
+	return nil
 }
 
 // Health checks service health. Service should be "query" or "ingest".
-func (c *Client) Health(ctx context.Context, service string) (*HealthResponse, error) {
+func (c *Client) Health(ctx context.Context, service string) (_ *HealthResponse, err error) {
+	ctx, op := c.startOperation(ctx, "Health")
+	defer func() { op.end(err) }()
+
+	resp, err := c.health(ctx, service)
+	if err != nil {
+		return nil, err
+	}
+	c.respond("Health", resp)
+	return resp, nil
+}
+
+func (c *Client) health(ctx context.Context, service string) (*HealthResponse, error) {
 	baseURL, err := c.serviceBaseURL(service)
 	if err != nil {
 		return nil, err
 	}
 
-	endpoint, err := joinURL(baseURL, "health")
+	endpoint, err := c.joinURL(baseURL, "health")
 	if err != nil {
 		return nil, err
 	}
 
-	body, err := c.doRequest(ctx, http.MethodGet, endpoint, nil)
+	body, err := c.doRequest(ctx, strings.ToLower(service), http.MethodGet, endpoint, nil)
 	if err != nil {
 		return nil, err
 	}
 
 	var resp HealthResponse
 	if err := json.Unmarshal(body, &resp); err != nil {
-		return nil, fmt.Errorf("decode health response: %w", err)
+		return nil, decodeError("health", body, err)
 	}
 
 	return &resp, nil
 }
 
-// Upsert inserts or updates vectors.
-func (c *Client) Upsert(ctx context.Context, docs []Document, opts *UpsertOptions) error {
-	if len(docs) == 0 {
-		return fmt.Errorf("%w: no documents provided", ErrValidation)
+// Ping checks the query and ingest services concurrently and returns nil only
+// when both report healthy. Otherwise it returns the failures joined, each
+// prefixed with its service name. When one service is unreachable or its
+// health request fails, the check of the other is cancelled rather than
+// waited on; a service that answers with an unhealthy status does not cancel
+// the other check, so both can be reported.
+func (c *Client) Ping(ctx context.Context) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	pingCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		service string
+		err     error
+		fatal   bool
+	}
+	services := []string{"query", "ingest"}
+	results := make(chan result, len(services))
+	for _, service := range services {
+		go func(service string) {
+			resp, err := c.Health(pingCtx, service)
+			switch {
+			case err != nil:
+				results <- result{service: service, err: err, fatal: true}
+			case !isHealthy(resp.Status):
+				results <- result{service: service, err: fmt.Errorf("%w: status %q", ErrServiceUnavailable, resp.Status)}
+			default:
+				results <- result{service: service}
+			}
+		}(service)
+	}
+
+	var errs []error
+	for range services {
+		r := <-results
+		if r.err == nil {
+			continue
+		}
+		if ctx.Err() == nil && pingCtx.Err() != nil && errors.Is(r.err, context.Canceled) {
+			// Cancelled by us after the other service failed.
+			continue
+		}
+		errs = append(errs, fmt.Errorf("%s service: %w", r.service, r.err))
+		if r.fatal {
+			cancel()
+		}
 	}
+	return errors.Join(errs...)
+}
 
-	desiredNamespace := ""
-	if opts != nil {
-		desiredNamespace = opts.Namespace
+// WaitReady polls Health for each service until it reports healthy or ctx
+// expires. With no services given it waits for both "query" and "ingest".
+// The poll interval is set with WithPollInterval and defaults to 500ms.
+func (c *Client) WaitReady(ctx context.Context, services ...string) error {
+	if ctx == nil {
+		ctx = context.Background()
 	}
-	namespace, err := c.namespaceOrDefault(desiredNamespace)
+	if len(services) == 0 {
+		services = []string{"query", "ingest"}
+	}
+	for _, service := range services {
+		if _, err := c.serviceBaseURL(service); err != nil {
+			return err
+		}
+	}
+
+	pending := make(map[string]error, len(services))
+	for _, service := range services {
+		pending[service] = nil
+	}
+
+	ticker := time.NewTicker(c.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for _, service := range services {
+			if _, ok := pending[service]; !ok {
+				continue
+			}
+			resp, err := c.Health(ctx, service)
+			switch {
+			case err != nil:
+				pending[service] = err
+			case !isHealthy(resp.Status):
+				pending[service] = fmt.Errorf("status %q", resp.Status)
+			default:
+				delete(pending, service)
+			}
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			errs := []error{ctx.Err()}
+			for _, service := range services {
+				if lastErr, ok := pending[service]; ok {
+					errs = append(errs, fmt.Errorf("%s service not ready: %w", service, lastErr))
+				}
+			}
+			return errors.Join(errs...)
+		case <-ticker.C:
+		}
+	}
+}
+
+// Upsert inserts or updates vectors. Every document must have an ID and a
+// vector, sparse vector, or text; invalid documents fail with ErrValidation
+// before anything is sent. When a batch size is configured, docs are sent in
+// sequential chunks and a failure is reported as a *BatchError.
+func (c *Client) Upsert(ctx context.Context, docs []Document, opts *UpsertOptions) (err error) {
+	ctx, op := c.startOperation(ctx, "Upsert")
+	defer func() { op.end(err) }()
+
+	if c.config.WarningHandler == nil {
+		_, err = c.upsert(ctx, op, docs, opts, nil)
+		return err
+	}
+	var warnings []string
+	_, err = c.upsert(ctx, op, docs, opts, func(body []byte, _ int) error {
+		warnings = append(warnings, upsertWarnings(body)...)
+		return nil
+	})
 	if err != nil {
 		return err
 	}
+	c.warn("Upsert", warnings)
+	return nil
+}
 
-	endpoint, err := c.ingestVectorsEndpoint(namespace)
+// UpsertWithResult is like Upsert but also returns what the server reported
+// writing. Upserted is summed over all batches; a batch whose response has no
+// body (such as 204 No Content) or no "upserted" count is counted as fully
+// written. Namespace is the namespace echoed by the server, or the resolved
+// namespace when none is echoed.
+func (c *Client) UpsertWithResult(ctx context.Context, docs []Document, opts *UpsertOptions) (_ *UpsertResponse, err error) {
+	ctx, op := c.startOperation(ctx, "UpsertWithResult")
+	defer func() { op.end(err) }()
+
+	result := &UpsertResponse{}
+	namespace, err := c.upsert(ctx, op, docs, opts, result.add)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	result.Namespace = cmp.Or(result.Namespace, namespace)
+	c.warn("UpsertWithResult", result.Warnings)
+	c.respond("UpsertWithResult", result)
+	return result, nil
+}
 
-	req := struct {
-		Vectors        []Document     `json:"vectors"`
-		DistanceMetric DistanceMetric `json:"distance_metric,omitempty"`
-	}{
-		Vectors: docs,
+// upsert implements Upsert and UpsertWithResult and returns the resolved
+// namespace. When onBatch is non-nil it is called with each batch's response
+// body and document count, and an error it returns fails the upsert.
+func (c *Client) upsert(ctx context.Context, op *operation, docs []Document, opts *UpsertOptions, onBatch func(body []byte, n int) error) (string, error) {
+	namespace, endpoint, docs, metric, err := c.prepareUpsert(ctx, op, docs, opts)
+	if err != nil {
+		return "", err
 	}
-	if opts != nil && opts.DistanceMetric != "" {
-		req.DistanceMetric = opts.DistanceMetric
+	batchSize := c.config.UpsertBatchSize
+	if opts != nil && opts.BatchSize > 0 {
+		batchSize = opts.BatchSize
 	}
 
-	_, err = c.doRequest(ctx, http.MethodPost, endpoint, req)
-	return err
+	batches := chunk(docs, batchSize)
+	committed := 0
+	for i, batch := range batches {
+		body, err := c.upsertBatch(ctx, endpoint, batch, metric)
+		if err != nil {
+			if len(batches) == 1 {
+				return "", err
+			}
+			return "", &BatchError{BatchIndex: i, Committed: committed, Err: err}
+		}
+		committed += len(batch)
+		c.rememberMetric(namespace, metric)
+		reportProgress(opts, committed, len(docs))
+		if onBatch != nil {
+			if err := onBatch(body, len(batch)); err != nil {
+				return "", err
+			}
+		}
+	}
+	return namespace, nil
 }
 
-// Query searches by vector similarity, full-text, or hybrid retrieval.
-// For text-only queries, pass a nil or empty vector and set opts.Text (and optionally opts.Mode).
-func (c *Client) Query(ctx context.Context, vector Vector, opts *QueryOptions) (*QueryResponse, error) {
+// prepareUpsert resolves the namespace, endpoint, and distance metric for an
+// upsert and returns docs prepared and checked for sending.
+func (c *Client) prepareUpsert(ctx context.Context, op *operation, docs []Document, opts *UpsertOptions) (namespace, endpoint string, prepared []Document, metric DistanceMetric, err error) {
+	if len(docs) == 0 {
+		return "", "", nil, "", fmt.Errorf("%w: no documents provided", ErrValidation)
+	}
+
 	desiredNamespace := ""
 	if opts != nil {
 		desiredNamespace = opts.Namespace
 	}
-	namespace, err := c.namespaceOrDefault(desiredNamespace)
+	namespace, err = c.namespaceOrDefault(desiredNamespace)
 	if err != nil {
-		return nil, err
+		return "", "", nil, "", err
 	}
+	op.setNamespace(namespace)
 
-	endpoint, err := c.queryVectorsEndpoint(namespace)
+	endpoint, err = c.ingestVectorsEndpoint(namespace)
 	if err != nil {
-		return nil, err
+		return "", "", nil, "", err
 	}
 
-	var (
-		text   string
-		mode   QueryMode
-		fusion FusionMode
-		alpha  *float32
-		rrfK   *int
-	)
+	prepared, err = c.prepareDocuments(ctx, docs, opts)
+	if err != nil {
+		return "", "", nil, "", err
+	}
+	if err := c.checkDocumentDimensions(ctx, namespace, prepared); err != nil {
+		return "", "", nil, "", err
+	}
 
+	metric = c.config.DefaultDistanceMetric
 	if opts != nil {
-		text = strings.TrimSpace(opts.Text)
-		mode = opts.Mode
-		fusion = opts.Fusion
-		alpha = opts.Alpha
-		rrfK = opts.RRFK
+		metric = cmp.Or(opts.DistanceMetric, metric)
+	}
+	return namespace, endpoint, prepared, metric, nil
+}
 
-		if opts.TopK < 0 {
-			return nil, fmt.Errorf("%w: top_k must be a positive integer", ErrValidation)
-		}
-		if opts.EfSearch < 0 {
-			return nil, fmt.Errorf("%w: ef_search must be a positive integer", ErrValidation)
-		}
-		if opts.NProbe < 0 {
-			return nil, fmt.Errorf("%w: nprobe must be a positive integer", ErrValidation)
-		}
+// UpsertAndWait upserts docs and then polls Fetch until every upserted ID is
+// visible or timeout elapses, for read-after-write flows such as integration
+// tests. A timeout of zero waits until ctx is done. Polls are spaced by the
+// configured poll interval. If some documents are still not visible, the
+// returned error wraps context.DeadlineExceeded and lists their IDs.
+func (c *Client) UpsertAndWait(ctx context.Context, docs []Document, opts *UpsertOptions, timeout time.Duration) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
 	}
 
-	hasVector := len(vector) > 0
-	if hasVector {
-		if err := ValidateVector(vector, 0); err != nil {
-			return nil, err
-		}
+	if err := c.Upsert(ctx, docs, opts); err != nil {
+		return err
 	}
 
-	hasText := text != ""
+	fetchOpts := &FetchOptions{}
+	if opts != nil {
+		fetchOpts.Namespace = opts.Namespace
+	}
+	pending := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		if doc.ID != "" {
+			pending = append(pending, doc.ID)
+		}
+	}
 
-	if mode == "" {
+	for {
+		exists, err := c.ExistsMany(ctx, pending, fetchOpts)
 		switch {
-		case hasVector && hasText:
-			mode = QueryModeHybrid
-		case hasText:
-			mode = QueryModeText
-		default:
-			mode = QueryModeVector
+		case err == nil:
+			remaining := pending[:0]
+			for _, id := range pending {
+				if !exists[id] {
+					remaining = append(remaining, id)
+				}
+			}
+			pending = remaining
+			if len(pending) == 0 {
+				return nil
+			}
+		case ctx.Err() == nil && !isRetryable(err):
+			return err
+		}
+
+		if waitErr := sleepContext(ctx, c.config.PollInterval); waitErr != nil {
+			return errors.Join(waitErr, fmt.Errorf("documents not visible: %s", strings.Join(pending, ", ")))
 		}
-	} else if mode != QueryModeVector && mode != QueryModeText && mode != QueryModeHybrid {
-		return nil, fmt.Errorf("%w: mode must be one of vector, text, hybrid", ErrValidation)
 	}
+}
 
-	if fusion != "" && fusion != FusionBlend && fusion != FusionRRF {
-		return nil, fmt.Errorf("%w: fusion must be one of blend, rrf", ErrValidation)
+// UpdateMetadata replaces the attributes of an existing vector without
+// re-sending the vector itself.
+func (c *Client) UpdateMetadata(ctx context.Context, id string, attrs Attributes, opts *UpsertOptions) (err error) {
+	ctx, op := c.startOperation(ctx, "UpdateMetadata")
+	defer func() { op.end(err) }()
+
+	if id == "" {
+		return fmt.Errorf("%w: id is required", ErrValidation)
+	}
+	if len(attrs) == 0 {
+		return fmt.Errorf("%w: no attributes provided", ErrValidation)
 	}
 
-	if mode == QueryModeVector && !hasVector {
-		return nil, fmt.Errorf("%w: vector is required", ErrValidation)
+	desiredNamespace := ""
+	if opts != nil {
+		desiredNamespace = opts.Namespace
 	}
-	if mode == QueryModeText && !hasText {
-		return nil, fmt.Errorf("%w: text is required", ErrValidation)
+	namespace, err := c.namespaceOrDefault(desiredNamespace)
+	if err != nil {
+		return err
 	}
-	if mode == QueryModeHybrid && (!hasVector || !hasText) {
-		return nil, fmt.Errorf("%w: vector and text are required for hybrid", ErrValidation)
+	op.setNamespace(namespace)
+
+	endpoint, err := c.ingestVectorsEndpoint(namespace)
+	if err != nil {
+		return err
+	}
+	endpoint, err = url.JoinPath(endpoint, id)
+	if err != nil {
+		return err
 	}
 
-	if alpha != nil {
-		if math.IsNaN(float64(*alpha)) || math.IsInf(float64(*alpha), 0) {
-			return nil, fmt.Errorf("%w: alpha must be a finite number", ErrValidation)
+	req := struct {
+		Attributes Attributes `json:"attributes"`
+	}{
+		Attributes: attrs,
+	}
+
+	_, err = c.doRequest(ctx, "ingest", http.MethodPatch, endpoint, req)
+	return err
+}
+
+// UpdateMetadataBatch replaces the attributes of several vectors, keyed by ID,
+// in a single request.
+func (c *Client) UpdateMetadataBatch(ctx context.Context, updates map[string]Attributes, opts *UpsertOptions) (err error) {
+	ctx, op := c.startOperation(ctx, "UpdateMetadataBatch")
+	defer func() { op.end(err) }()
+
+	if len(updates) == 0 {
+		return fmt.Errorf("%w: no updates provided", ErrValidation)
+	}
+
+	type metadataUpdate struct {
+		ID         string     `json:"id"`
+		Attributes Attributes `json:"attributes"`
+	}
+	items := make([]metadataUpdate, 0, len(updates))
+	for id, attrs := range updates {
+		if id == "" {
+			return fmt.Errorf("%w: id is required", ErrValidation)
+		}
+		if len(attrs) == 0 {
+			return fmt.Errorf("%w: no attributes provided for id %q", ErrValidation, id)
 		}
-		clamped := float32(math.Min(1, math.Max(0, float64(*alpha))))
-		alpha = &clamped
+		items = append(items, metadataUpdate{ID: id, Attributes: attrs})
 	}
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
 
-	if rrfK != nil && *rrfK <= 0 {
-		return nil, fmt.Errorf("%w: rrf_k must be a positive integer", ErrValidation)
+	desiredNamespace := ""
+	if opts != nil {
+		desiredNamespace = opts.Namespace
+	}
+	namespace, err := c.namespaceOrDefault(desiredNamespace)
+	if err != nil {
+		return err
+	}
+	op.setNamespace(namespace)
+
+	endpoint, err := c.ingestVectorsEndpoint(namespace)
+	if err != nil {
+		return err
 	}
 
 	req := struct {
-		Vector         Vector         `json:"vector,omitempty"`
-		Text           string         `json:"text,omitempty"`
-		Mode           string         `json:"mode,omitempty"`
-		Alpha          *float32       `json:"alpha,omitempty"`
-		Fusion         string         `json:"fusion,omitempty"`
-		RRFK           *int           `json:"rrf_k,omitempty"`
-		TopK           int            `json:"top_k,omitempty"`
-		EfSearch       int            `json:"ef_search,omitempty"`
-		NProbe         int            `json:"nprobe,omitempty"`
-		DistanceMetric DistanceMetric `json:"distance_metric,omitempty"`
-		IncludeVectors *bool          `json:"include_vectors,omitempty"`
-		Filters        Attributes     `json:"filters,omitempty"`
+		Updates []metadataUpdate `json:"updates"`
 	}{
-		Vector: vector,
-		Text:   text,
-		Mode:   string(mode),
-		Alpha:  alpha,
-		Fusion: string(fusion),
-		RRFK:   rrfK,
+		Updates: items,
+	}
+
+	_, err = c.doRequest(ctx, "ingest", http.MethodPatch, endpoint, req)
+	return err
+}
+
+// UpsertConcurrent upserts docs in batches using up to concurrency parallel
+// requests. Batches use the configured batch size, or defaultUpsertBatchSize
+// when none is set. Scheduling stops on context cancellation or once a batch
+// fails with a non-retryable error; all batch errors are joined. Each
+// *BatchError lists the batches that were written in Succeeded.
+//
+// Each in-flight batch holds one HTTP connection, so concurrency should not
+// exceed the transport's MaxConnsPerHost (when set), otherwise extra
+// workers block waiting for a connection.
+func (c *Client) UpsertConcurrent(ctx context.Context, docs []Document, opts *UpsertOptions, concurrency int) (err error) {
+	ctx, op := c.startOperation(ctx, "UpsertConcurrent")
+	defer func() { op.end(err) }()
+
+	if len(docs) == 0 {
+		return fmt.Errorf("%w: no documents provided", ErrValidation)
+	}
+	if concurrency <= 0 {
+		return fmt.Errorf("%w: concurrency must be a positive integer", ErrValidation)
+	}
+	if ctx == nil {
+		ctx = context.Background()
 	}
 
+	desiredNamespace := ""
 	if opts != nil {
-		if opts.TopK > 0 {
-			req.TopK = opts.TopK
-		}
-		if opts.EfSearch > 0 {
-			req.EfSearch = opts.EfSearch
-		}
-		if opts.NProbe > 0 {
-			req.NProbe = opts.NProbe
-		}
-		if opts.DistanceMetric != "" {
-			req.DistanceMetric = opts.DistanceMetric
-		}
-		req.Filters = opts.Filters
-		req.IncludeVectors = &opts.IncludeVectors
+		desiredNamespace = opts.Namespace
+	}
+	namespace, err := c.namespaceOrDefault(desiredNamespace)
+	if err != nil {
+		return err
 	}
+	op.setNamespace(namespace)
 
-	body, err := c.doRequest(ctx, http.MethodPost, endpoint, req)
+	endpoint, err := c.ingestVectorsEndpoint(namespace)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	results, err := decodeQueryResponse(body, namespace)
+	docs, err = c.prepareDocuments(ctx, docs, opts)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if err := c.checkDocumentDimensions(ctx, namespace, docs); err != nil {
+		return err
+	}
+
+	metric := c.config.DefaultDistanceMetric
+	batchSize := c.config.UpsertBatchSize
+	if opts != nil {
+		metric = cmp.Or(opts.DistanceMetric, metric)
+		if opts.BatchSize > 0 {
+			batchSize = opts.BatchSize
+		}
+	}
+	if batchSize <= 0 {
+		batchSize = defaultUpsertBatchSize
 	}
 
-	return results, nil
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		errs      []error
+		committed int
+		succeeded []int
+		sem       = make(chan struct{}, concurrency)
+	)
+
+schedule:
+	for i, batch := range chunk(docs, batchSize) {
+		select {
+		case <-ctx.Done():
+			break schedule
+		case sem <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func(index int, batch []Document) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			body, err := c.upsertBatch(ctx, endpoint, batch, metric)
+			if err == nil && c.config.WarningHandler != nil {
+				c.warn("UpsertConcurrent", upsertWarnings(body))
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, &BatchError{BatchIndex: index, Committed: committed, Err: err})
+				if !isRetryable(err) {
+					cancel()
+				}
+				return
+			}
+			committed += len(batch)
+			succeeded = append(succeeded, index)
+			c.rememberMetric(namespace, metric)
+			reportProgress(opts, committed, len(docs))
+		}(i, batch)
+	}
+	wg.Wait()
+
+	slices.Sort(succeeded)
+	for _, err := range errs {
+		err.(*BatchError).Succeeded = succeeded
+	}
+
+	if len(errs) == 0 {
+		if err := ctx.Err(); err != nil && committed < len(docs) {
+			return err
+		}
+	}
+	return errors.Join(errs...)
 }
 
-// Delete removes vectors by ID.
-func (c *Client) Delete(ctx context.Context, ids []string, opts *DeleteOptions) error {
-	if len(ids) == 0 {
-		return fmt.Errorf("%w: no ids provided", ErrValidation)
+// UpsertStream reads documents from docs and upserts them in batches as each
+// batch fills, so only one batch is held in memory at a time. Batches use the
+// configured batch size, or defaultUpsertBatchSize when none is set. A final
+// partial batch is flushed when docs is closed. On failure or context
+// cancellation it returns a *BatchError whose Committed field is the number
+// of documents written so far.
+func (c *Client) UpsertStream(ctx context.Context, docs <-chan Document, opts *UpsertOptions) (err error) {
+	ctx, op := c.startOperation(ctx, "UpsertStream")
+	defer func() { op.end(err) }()
+	if ctx == nil {
+		ctx = context.Background()
 	}
 
 	desiredNamespace := ""
 	if opts != nil {
 		desiredNamespace = opts.Namespace
 	}
-	namespace, err := c.namespaceOrDefault(desiredNamespace)
+	namespace, err := c.namespaceOrDefault(desiredNamespace)
+	if err != nil {
+		return err
+	}
+	op.setNamespace(namespace)
+
+	endpoint, err := c.ingestVectorsEndpoint(namespace)
+	if err != nil {
+		return err
+	}
+
+	metric := c.config.DefaultDistanceMetric
+	batchSize := c.config.UpsertBatchSize
+	if opts != nil {
+		metric = cmp.Or(opts.DistanceMetric, metric)
+		if opts.BatchSize > 0 {
+			batchSize = opts.BatchSize
+		}
+	}
+	if batchSize <= 0 {
+		batchSize = defaultUpsertBatchSize
+	}
+	if err := validateMetric(metric); err != nil {
+		return err
+	}
+
+	var (
+		batch      = make([]Document, 0, batchSize)
+		batchIndex int
+		committed  int
+	)
+	flush := func() error {
+		prepared, err := c.prepareDocuments(ctx, batch, opts)
+		if err == nil {
+			err = c.checkDocumentDimensions(ctx, namespace, prepared)
+		}
+		var body []byte
+		if err == nil {
+			body, err = c.upsertBatch(ctx, endpoint, prepared, metric)
+		}
+		if err != nil {
+			return &BatchError{BatchIndex: batchIndex, Committed: committed, Err: err}
+		}
+		if c.config.WarningHandler != nil {
+			c.warn("UpsertStream", upsertWarnings(body))
+		}
+		committed += len(batch)
+		batchIndex++
+		batch = batch[:0]
+		c.rememberMetric(namespace, metric)
+		reportProgress(opts, committed, -1)
+		return nil
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return &BatchError{BatchIndex: batchIndex, Committed: committed, Err: ctx.Err()}
+		case doc, ok := <-docs:
+			if !ok {
+				if len(batch) == 0 {
+					return nil
+				}
+				return flush()
+			}
+			batch = append(batch, doc)
+			if len(batch) >= batchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// upsertWarnings returns the warnings in an upsert response body. Success
+// does not depend on the body, so one that is not JSON carries none.
+func upsertWarnings(body []byte) []string {
+	var resp struct {
+		Warnings []string `json:"warnings"`
+	}
+	if json.Unmarshal(body, &resp) != nil {
+		return nil
+	}
+	return resp.Warnings
+}
+
+// reportProgress calls opts.OnProgress, if set.
+func reportProgress(opts *UpsertOptions, done, total int) {
+	if opts != nil && opts.OnProgress != nil {
+		opts.OnProgress(done, total)
+	}
+}
+
+// upsertBatch sends one upsert request and returns the response body.
+func (c *Client) upsertBatch(ctx context.Context, endpoint string, docs []Document, metric DistanceMetric) ([]byte, error) {
+	body, err := c.upsertBody(ctx, docs, metric)
+	if err != nil {
+		return nil, err
+	}
+	return c.doRequest(ctx, "ingest", http.MethodPost, endpoint, body)
+}
+
+// upsertBody returns the request body for one upsert batch, with vectors
+// quantized when the client quantizes upserts.
+func (c *Client) upsertBody(ctx context.Context, docs []Document, metric DistanceMetric) (any, error) {
+	if c.quantizeUpserts(ctx) {
+		quantized, err := quantizeDocuments(docs)
+		if err != nil {
+			return nil, err
+		}
+		return upsertRequest[quantizedDocument]{
+			Vectors:        quantized,
+			DistanceMetric: metric,
+		}, nil
+	}
+	return upsertRequest[Document]{
+		Vectors:        docs,
+		DistanceMetric: metric,
+	}, nil
+}
+
+type upsertRequest[D Document | quantizedDocument] struct {
+	Vectors        []D            `json:"vectors"`
+	DistanceMetric DistanceMetric `json:"distance_metric,omitempty"`
+}
+
+// Query searches by vector similarity, full-text, or hybrid retrieval.
+// For text-only queries, pass a nil or empty vector and set opts.Text (and optionally opts.Mode).
+// The returned QueryResponse carries the namespace echoed by the server (or the
+// resolved namespace when the server does not echo one) and the page cursor.
+func (c *Client) Query(ctx context.Context, vector Vector, opts *QueryOptions) (*QueryResponse, error) {
+	resp, _, err := c.query(ctx, vector, opts)
+	return resp, err
+}
+
+// query implements Query and also returns the request sent, so that callers
+// can see how it was resolved.
+func (c *Client) query(ctx context.Context, vector Vector, opts *QueryOptions) (_ *QueryResponse, req *queryRequest, err error) {
+	ctx, op := c.startOperation(ctx, "Query")
+	defer func() { op.end(err) }()
+
+	namespace, endpoint, req, err := c.prepareQuery(ctx, op, vector, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	start := time.Now()
+	body, err := c.doRequest(ctx, "query", http.MethodPost, endpoint, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	roundTrip := time.Since(start)
+
+	results, err := decodeQueryResponse(body, namespace, c.config.UseNumber)
+	if err != nil {
+		return nil, nil, err
+	}
+	results.RoundTrip = roundTrip
+	results.Results = req.applyMinScore(results.Results)
+	if opts != nil && opts.DedupeBy != "" {
+		results.Results = dedupeResults(results.Results, opts.DedupeBy)
+	}
+	if opts != nil && opts.Rerank != nil && len(results.Results) >= 2 {
+		results.Results, err = opts.Rerank(ctx, req.Text, results.Results)
+		if err != nil {
+			return nil, nil, fmt.Errorf("rerank: %w", err)
+		}
+	}
+	op.setResultCount(len(results.Results))
+
+	c.warn("Query", results.Warnings)
+	c.respond("Query", results)
+	return results, req, nil
+}
+
+// prepareQuery resolves the namespace and endpoint for a single query, embeds
+// opts.Text when needed, and builds and validates the request payload.
+func (c *Client) prepareQuery(ctx context.Context, op *operation, vector Vector, opts *QueryOptions) (namespace, endpoint string, req *queryRequest, err error) {
+	desiredNamespace := ""
+	if opts != nil {
+		desiredNamespace = opts.Namespace
+	}
+	namespace, err = c.namespaceOrDefault(desiredNamespace)
+	if err != nil {
+		return "", "", nil, err
+	}
+	op.setNamespace(namespace)
+
+	endpoint, err = c.queryVectorsEndpoint(namespace)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	vector, err = c.embedQueryText(ctx, vector, opts)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	req, err = c.buildQueryRequest(vector, opts)
+	if err != nil {
+		return "", "", nil, err
+	}
+	if err := c.checkFilters(namespace, req.Filters); err != nil {
+		return "", "", nil, err
+	}
+	c.resolveMetric(namespace, req)
+	op.setTopK(req.TopK)
+	if len(req.Vectors) > 0 {
+		err = c.checkDimensions(ctx, namespace, req.Vectors, "vectors[%d]")
+	} else if len(req.Vector) > 0 {
+		err = c.checkDimensions(ctx, namespace, []Vector{req.Vector}, "")
+	}
+	if err != nil {
+		return "", "", nil, err
+	}
+	return namespace, endpoint, req, nil
+}
+
+// FindSimilar returns documents similar to the stored document id, excluding
+// the document itself. TopK, Filters, and the other vector search options in
+// opts are honored; Text, Mode, Vectors, Cursor, DedupeBy, and Rerank are
+// ignored. It uses the server's similar endpoint and, when the server does
+// not provide one, falls back to fetching the document's vector and querying
+// with it.
+func (c *Client) FindSimilar(ctx context.Context, id string, opts *QueryOptions) (_ []VectorResult, err error) {
+	ctx, op := c.startOperation(ctx, "FindSimilar")
+	defer func() { op.end(err) }()
+
+	if id == "" {
+		return nil, fmt.Errorf("%w: id is required", ErrValidation)
+	}
+
+	var similar QueryOptions
+	if opts != nil {
+		similar = *opts
+	}
+	similar.Text = ""
+	similar.Mode = ""
+	similar.Vectors = nil
+	similar.Cursor = ""
+	similar.DedupeBy = ""
+	similar.Rerank = nil
+	topK := similar.TopK
+	if topK > 0 {
+		// Ask for one extra result to make up for dropping the seed.
+		similar.TopK = topK + 1
+	}
+
+	namespace, err := c.namespaceOrDefault(similar.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	similar.Namespace = namespace
+	op.setNamespace(namespace)
+	op.setTopK(topK)
+
+	endpoint, err := c.queryVectorsEndpoint(namespace)
+	if err != nil {
+		return nil, err
+	}
+	endpoint, err = url.JoinPath(endpoint, id, "similar")
+	if err != nil {
+		return nil, err
+	}
+	if err := validateMetric(similar.DistanceMetric); err != nil {
+		return nil, err
+	}
+	if err := validateMinScore(similar.MinScore); err != nil {
+		return nil, err
+	}
+	if err := validateExtra(similar.Extra); err != nil {
+		return nil, err
+	}
+	if err := validateIncludeAttributes(similar.IncludeAttributes); err != nil {
+		return nil, err
+	}
+	if err := c.checkFilters(namespace, similar.Filters); err != nil {
+		return nil, err
+	}
+
+	req := &queryRequest{
+		TopK:              similar.TopK,
+		EfSearch:          similar.EfSearch,
+		NProbe:            similar.NProbe,
+		DistanceMetric:    similar.DistanceMetric,
+		IncludeVectors:    similar.IncludeVectors,
+		IncludeAttributes: similar.IncludeAttributes,
+		Filters:           similar.Filters,
+		MinScore:          similar.MinScore,
+		Extra:             similar.Extra,
+	}
+	c.resolveMetric(namespace, req)
+
+	var results []VectorResult
+	body, err := c.doRequest(ctx, "query", http.MethodPost, endpoint, req)
+	switch {
+	case err == nil:
+		resp, err := decodeQueryResponse(body, namespace, c.config.UseNumber)
+		if err != nil {
+			return nil, err
+		}
+		c.warn("FindSimilar", resp.Warnings)
+		results = req.applyMinScore(resp.Results)
+	case isUnsupportedEndpoint(err):
+		results, err = c.findSimilarFallback(ctx, id, &similar)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	filtered := results[:0:0]
+	for _, result := range results {
+		if result.ID != id {
+			filtered = append(filtered, result)
+		}
+	}
+	if topK > 0 && len(filtered) > topK {
+		filtered = filtered[:topK]
+	}
+	op.setResultCount(len(filtered))
+
+	c.respond("FindSimilar", filtered)
+	return filtered, nil
+}
+
+// findSimilarFallback fetches the seed document's vector and queries with it.
+func (c *Client) findSimilarFallback(ctx context.Context, id string, opts *QueryOptions) ([]VectorResult, error) {
+	docs, err := c.Fetch(ctx, []string{id}, &FetchOptions{Namespace: opts.Namespace})
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 || len(docs[0].Vector) == 0 {
+		return nil, fmt.Errorf("find similar: document %q has no stored vector", id)
+	}
+
+	resp, err := c.Query(ctx, docs[0].Vector, opts)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Results, nil
+}
+
+// MultiQuery runs one query per vector in a single round trip to the batch
+// endpoint and returns one QueryResponse per input vector, in order. All
+// queries share opts. If the server has no batch endpoint (404 or 405) and
+// WithMultiQueryFallback is configured, the queries are issued concurrently
+// as individual Query calls instead.
+func (c *Client) MultiQuery(ctx context.Context, vectors []Vector, opts *QueryOptions) (_ []QueryResponse, err error) {
+	ctx, op := c.startOperation(ctx, "MultiQuery")
+	defer func() { op.end(err) }()
+
+	if len(vectors) == 0 {
+		return nil, fmt.Errorf("%w: no vectors provided", ErrValidation)
+	}
+	if opts != nil && len(opts.Vectors) > 0 {
+		return nil, fmt.Errorf("%w: MultiQuery does not accept the Vectors option", ErrValidation)
+	}
+
+	desiredNamespace := ""
+	if opts != nil {
+		desiredNamespace = opts.Namespace
+	}
+	namespace, err := c.namespaceOrDefault(desiredNamespace)
+	if err != nil {
+		return nil, err
+	}
+	op.setNamespace(namespace)
+
+	endpoint, err := c.queryVectorsEndpoint(namespace)
+	if err != nil {
+		return nil, err
+	}
+	endpoint, err = url.JoinPath(endpoint, "batch")
+	if err != nil {
+		return nil, err
+	}
+
+	if opts != nil {
+		op.setTopK(opts.TopK)
+	}
+
+	queries := make([]*queryRequest, len(vectors))
+	for i, vector := range vectors {
+		if err := c.validateVector(vector, 0); err != nil {
+			return nil, fmt.Errorf("vector %d: %w", i, err)
+		}
+		queries[i], err = c.buildQueryRequest(vector, opts)
+		if err != nil {
+			return nil, fmt.Errorf("vector %d: %w", i, err)
+		}
+		if err := c.checkFilters(namespace, queries[i].Filters); err != nil {
+			return nil, err
+		}
+		c.resolveMetric(namespace, queries[i])
+	}
+	if err := c.checkDimensions(ctx, namespace, vectors, "vector %d"); err != nil {
+		return nil, err
+	}
+
+	req := struct {
+		Queries []*queryRequest `json:"queries"`
+	}{
+		Queries: queries,
+	}
+
+	start := time.Now()
+	body, err := c.doRequest(ctx, "query", http.MethodPost, endpoint, req)
+	if err != nil {
+		if c.config.MultiQueryFallback > 0 && isUnsupportedEndpoint(err) {
+			responses, err := c.multiQueryFallback(ctx, vectors, opts)
+			if err != nil {
+				return nil, err
+			}
+			c.respond("MultiQuery", responses)
+			return responses, nil
+		}
+		return nil, err
+	}
+	roundTrip := time.Since(start)
+
+	responses, err := decodeBatchQueryResponse(body, namespace, c.config.UseNumber)
+	if err != nil {
+		return nil, err
+	}
+	if len(responses) != len(vectors) {
+		return nil, fmt.Errorf("decode batch query response: expected %d results, got %d", len(vectors), len(responses))
+	}
+	for i := range responses {
+		responses[i].RoundTrip = roundTrip
+		responses[i].Results = queries[i].applyMinScore(responses[i].Results)
+		if opts != nil && opts.DedupeBy != "" {
+			responses[i].Results = dedupeResults(responses[i].Results, opts.DedupeBy)
+		}
+		if opts != nil && opts.Rerank != nil && len(responses[i].Results) >= 2 {
+			responses[i].Results, err = opts.Rerank(ctx, queries[i].Text, responses[i].Results)
+			if err != nil {
+				return nil, fmt.Errorf("vector %d: rerank: %w", i, err)
+			}
+		}
+		c.warn("MultiQuery", responses[i].Warnings)
+	}
+	op.setResultCount(len(responses))
+
+	c.respond("MultiQuery", responses)
+	return responses, nil
+}
+
+func (c *Client) multiQueryFallback(ctx context.Context, vectors []Vector, opts *QueryOptions) ([]QueryResponse, error) {
+	var (
+		wg        sync.WaitGroup
+		sem       = make(chan struct{}, c.config.MultiQueryFallback)
+		responses = make([]QueryResponse, len(vectors))
+		errs      = make([]error, len(vectors))
+	)
+	for i, vector := range vectors {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, vector Vector) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			resp, err := c.Query(ctx, vector, opts)
+			if err != nil {
+				errs[i] = fmt.Errorf("vector %d: %w", i, err)
+				return
+			}
+			responses[i] = *resp
+		}(i, vector)
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return responses, nil
+}
+
+// QueryMulti runs the same query against each of namespaces concurrently and
+// merges the results into one list ordered by score, best first, truncated to
+// opts.TopK (all merged results when TopK is zero). Each result's Namespace
+// is set to the namespace it came from, and ties keep the order of
+// namespaces. Text and hybrid relevance scores and dot products sort
+// descending; distances sort ascending. The query's DistanceMetric is the one
+// resolved for the first namespace that exists. Text for an Embedder is
+// embedded once and shared by every namespace.
+//
+// A namespace that does not exist is skipped and reported to the warning
+// handler as a "QueryMulti" warning; its 404 also reaches the Logger. If
+// every namespace is missing the not-found errors are returned,
+// and any other failure fails the whole call. DedupeBy and Rerank apply per
+// namespace. opts.Namespace and opts.Cursor must be empty.
+func (c *Client) QueryMulti(ctx context.Context, vector Vector, namespaces []string, opts *QueryOptions) (_ []VectorResult, err error) {
+	ctx, op := c.startOperation(ctx, "QueryMulti")
+	defer func() { op.end(err) }()
+
+	if len(namespaces) == 0 {
+		return nil, fmt.Errorf("%w: at least one namespace is required", ErrValidation)
+	}
+	var base QueryOptions
+	if opts != nil {
+		base = *opts
+	}
+	if base.Namespace != "" || base.Cursor != "" {
+		return nil, fmt.Errorf("%w: QueryMulti does not accept Namespace or Cursor options", ErrValidation)
+	}
+	resolved := make([]string, len(namespaces))
+	for i, name := range namespaces {
+		ns, err := c.namespaceOrDefault(name)
+		if err != nil {
+			return nil, err
+		}
+		if slices.Contains(resolved[:i], ns) {
+			return nil, fmt.Errorf("%w: duplicate namespace %q", ErrValidation, ns)
+		}
+		resolved[i] = ns
+	}
+	op.setTopK(base.TopK)
+
+	// Embed once rather than in every namespace's query.
+	vector, err = c.embedQueryText(ctx, vector, &base)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		wg       sync.WaitGroup
+		results  = make([][]VectorResult, len(resolved))
+		higher   = make([]bool, len(resolved))
+		errs     = make([]error, len(resolved))
+		notFound = make([]error, len(resolved))
+	)
+	for i, ns := range resolved {
+		wg.Add(1)
+		go func(i int, ns string) {
+			defer wg.Done()
+
+			nsOpts := base
+			nsOpts.Namespace = ns
+			resp, req, err := c.query(ctx, vector, &nsOpts)
+			switch {
+			case IsNotFoundError(err):
+				notFound[i] = fmt.Errorf("namespace %q: %w", ns, err)
+			case err != nil:
+				errs[i] = fmt.Errorf("namespace %q: %w", ns, err)
+			default:
+				for j := range resp.Results {
+					resp.Results[j].Namespace = ns
+				}
+				results[i] = resp.Results
+				higher[i] = req.higherIsBetter()
+			}
+		}(i, ns)
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	if !slices.ContainsFunc(notFound, func(err error) bool { return err == nil }) {
+		return nil, errors.Join(notFound...)
+	}
+	var skipped []string
+	for i, err := range notFound {
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("namespace %q not found, skipped", resolved[i]))
+		}
+	}
+	c.warn("QueryMulti", skipped)
+
+	// Scores sort the way the first answering namespace's request resolved.
+	first := slices.IndexFunc(notFound, func(err error) bool { return err == nil })
+	merged := slices.Concat(results...)
+	sortResults(merged, higher[first])
+	if base.TopK > 0 && len(merged) > base.TopK {
+		merged = merged[:base.TopK]
+	}
+	op.setResultCount(len(merged))
+
+	c.respond("QueryMulti", merged)
+	return merged, nil
+}
+
+// queryRequest is the wire format for a single query.
+type queryRequest struct {
+	Vector            Vector         `json:"vector,omitempty"`
+	Vectors           []Vector       `json:"vectors,omitempty"`
+	SparseVector      *SparseVector  `json:"sparse_vector,omitempty"`
+	Text              string         `json:"text,omitempty"`
+	Mode              string         `json:"mode,omitempty"`
+	Alpha             *float32       `json:"alpha,omitempty"`
+	Fusion            string         `json:"fusion,omitempty"`
+	RRFK              *int           `json:"rrf_k,omitempty"`
+	TopK              int            `json:"top_k,omitempty"`
+	EfSearch          int            `json:"ef_search,omitempty"`
+	NProbe            int            `json:"nprobe,omitempty"`
+	DistanceMetric    DistanceMetric `json:"distance_metric,omitempty"`
+	IncludeVectors    *bool          `json:"include_vectors,omitempty"`
+	IncludeAttributes []string       `json:"include_attributes,omitempty"`
+	Filters           Attributes     `json:"filters,omitempty"`
+	MinScore          *float32       `json:"min_score,omitempty"`
+	Cursor            string         `json:"cursor,omitempty"`
+	Extra             Attributes     `json:"-"`
+}
+
+// queryRequestFields holds the JSON names of the fields queryRequest models,
+// which QueryOptions.Extra may not override.
+var queryRequestFields = jsonFieldNames(reflect.TypeOf(queryRequest{}))
+
+// MarshalJSON encodes the typed fields and merges r.Extra into the object.
+// Typed fields win if a key appears in both.
+func (r queryRequest) MarshalJSON() ([]byte, error) {
+	type wire queryRequest
+	data, err := json.Marshal(wire(r))
+	if err != nil || len(r.Extra) == 0 {
+		return data, err
+	}
+
+	merged := make(map[string]json.RawMessage, len(r.Extra))
+	if err := json.Unmarshal(data, &merged); err != nil {
+		return nil, err
+	}
+	for key, value := range r.Extra {
+		if _, ok := merged[key]; ok {
+			continue
+		}
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("extra field %q: %w", key, err)
+		}
+		merged[key] = raw
+	}
+	return json.Marshal(merged)
+}
+
+// validateExtra rejects extra body fields that collide with fields the
+// client already models.
+func validateExtra(extra Attributes) error {
+	for key := range extra {
+		if _, ok := queryRequestFields[key]; ok {
+			return fmt.Errorf("%w: extra field %q collides with a query option; set it through QueryOptions instead", ErrValidation, key)
+		}
+	}
+	return nil
+}
+
+// validateMinScore rejects a non-finite score threshold.
+func validateMinScore(minScore *float32) error {
+	if minScore != nil && (math.IsNaN(float64(*minScore)) || math.IsInf(float64(*minScore), 0)) {
+		return fmt.Errorf("%w: min_score must be a finite number", ErrValidation)
+	}
+	return nil
+}
+
+// validateVectors checks each of a multi-vector query's vectors and that they
+// all have the dimension of the first.
+func (c *Client) validateVectors(vectors []Vector) error {
+	for i, v := range vectors {
+		if err := c.validateVector(v, len(vectors[0])); err != nil {
+			return fmt.Errorf("vectors[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// higherIsBetter reports whether a higher score is a better match for the
+// request: true for text and hybrid relevance and for dot products, false for
+// distances.
+func (r *queryRequest) higherIsBetter() bool {
+	return r.Mode == string(QueryModeText) || r.Mode == string(QueryModeHybrid) || r.DistanceMetric.HigherIsBetter()
+}
+
+// keepsScore reports whether score meets the request's MinScore: a floor for
+// dot products and text or hybrid relevance, a ceiling for distances.
+func (r *queryRequest) keepsScore(score float32) bool {
+	if r.MinScore == nil {
+		return true
+	}
+	if r.higherIsBetter() {
+		return score >= *r.MinScore
+	}
+	return score <= *r.MinScore
+}
+
+// applyMinScore drops results that do not meet the request's MinScore, for
+// servers that ignore min_score. The slice is filtered in place.
+func (r *queryRequest) applyMinScore(results []VectorResult) []VectorResult {
+	if r.MinScore == nil {
+		return results
+	}
+	return slices.DeleteFunc(results, func(result VectorResult) bool {
+		return !r.keepsScore(result.Score)
+	})
+}
+
+// validateIncludeAttributes rejects empty keys in an attribute projection.
+func validateIncludeAttributes(keys []string) error {
+	for i, key := range keys {
+		if strings.TrimSpace(key) == "" {
+			return fmt.Errorf("%w: include_attributes[%d] cannot be empty", ErrValidation, i)
+		}
+	}
+	return nil
+}
+
+// jsonFieldNames returns the JSON object keys used by struct type t.
+func jsonFieldNames(t reflect.Type) map[string]struct{} {
+	names := make(map[string]struct{}, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		names[name] = struct{}{}
+	}
+	return names
+}
+
+// embedQueryText returns vector, or the embedding of opts.Text when the query
+// has no vector, a vector or hybrid Mode, and an Embedder is configured.
+func (c *Client) embedQueryText(ctx context.Context, vector Vector, opts *QueryOptions) (Vector, error) {
+	if len(vector) > 0 || c.config.Embedder == nil || opts == nil || len(opts.Vectors) > 0 ||
+		(opts.Mode != QueryModeVector && opts.Mode != QueryModeHybrid) || strings.TrimSpace(opts.Text) == "" {
+		return vector, nil
+	}
+	vectors, err := c.embed(ctx, []string{opts.Text})
+	if err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
+// buildQueryRequest validates opts and builds the query payload for vector.
+func (c *Client) buildQueryRequest(vector Vector, opts *QueryOptions) (*queryRequest, error) {
+	var (
+		text   string
+		mode   QueryMode
+		fusion FusionMode
+		alpha  *float32
+		rrfK   *int
+	)
+
+	if opts != nil {
+		text = strings.TrimSpace(opts.Text)
+		mode = opts.Mode
+		fusion = opts.Fusion
+		alpha = opts.Alpha
+		rrfK = opts.RRFK
+
+		if opts.TopK < 0 {
+			return nil, fmt.Errorf("%w: top_k must be a positive integer", ErrValidation)
+		}
+		if opts.EfSearch < 0 {
+			return nil, fmt.Errorf("%w: ef_search must be a positive integer", ErrValidation)
+		}
+		if opts.NProbe < 0 {
+			return nil, fmt.Errorf("%w: nprobe must be a positive integer", ErrValidation)
+		}
+		if err := validateMinScore(opts.MinScore); err != nil {
+			return nil, err
+		}
+		if err := validateMetric(opts.DistanceMetric); err != nil {
+			return nil, err
+		}
+		if err := validateExtra(opts.Extra); err != nil {
+			return nil, err
+		}
+		if err := validateIncludeAttributes(opts.IncludeAttributes); err != nil {
+			return nil, err
+		}
+		if opts.SparseVector != nil {
+			if err := ValidateSparseVector(opts.SparseVector); err != nil {
+				return nil, err
+			}
+		}
+		if len(opts.Vectors) > 0 {
+			if err := c.validateVectors(opts.Vectors); err != nil {
+				return nil, err
+			}
+			vector = nil
+		}
+	}
+
+	hasVector := len(vector) > 0 || (opts != nil && len(opts.Vectors) > 0)
+	if len(vector) > 0 {
+		if err := c.validateVector(vector, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	hasText := text != ""
+
+	if mode == "" {
+		switch {
+		case hasVector && hasText:
+			mode = QueryModeHybrid
+		case hasText:
+			mode = QueryModeText
+		default:
+			mode = QueryModeVector
+		}
+	} else if mode != QueryModeVector && mode != QueryModeText && mode != QueryModeHybrid {
+		return nil, fmt.Errorf("%w: mode must be one of vector, text, hybrid", ErrValidation)
+	}
+
+	if fusion != "" && fusion != FusionBlend && fusion != FusionRRF {
+		return nil, fmt.Errorf("%w: fusion must be one of blend, rrf", ErrValidation)
+	}
+
+	if mode == QueryModeVector && !hasVector {
+		return nil, fmt.Errorf("%w: vector is required", ErrValidation)
+	}
+	if mode == QueryModeText && !hasText {
+		return nil, fmt.Errorf("%w: text is required", ErrValidation)
+	}
+	if mode == QueryModeHybrid && (!hasVector || !hasText) {
+		return nil, fmt.Errorf("%w: vector and text are required for hybrid", ErrValidation)
+	}
+
+	if alpha != nil {
+		if math.IsNaN(float64(*alpha)) || math.IsInf(float64(*alpha), 0) {
+			return nil, fmt.Errorf("%w: alpha must be a finite number", ErrValidation)
+		}
+		if *alpha < 0 || *alpha > 1 {
+			switch {
+			case c.config.StrictAlpha:
+				return nil, fmt.Errorf("%w: alpha must be between 0 and 1, got %v", ErrValidation, *alpha)
+			case !c.config.DisableAlphaClamp:
+				clamped := float32(math.Min(1, math.Max(0, float64(*alpha))))
+				alpha = &clamped
+			}
+		}
+	}
+
+	if rrfK != nil && *rrfK <= 0 {
+		return nil, fmt.Errorf("%w: rrf_k must be a positive integer", ErrValidation)
+	}
+
+	req := &queryRequest{
+		Vector: vector,
+		Text:   text,
+		Mode:   string(mode),
+		Alpha:  alpha,
+		Fusion: string(fusion),
+		RRFK:   rrfK,
+	}
+
+	if opts != nil {
+		if opts.TopK > 0 {
+			req.TopK = opts.TopK
+		}
+		if opts.EfSearch > 0 {
+			req.EfSearch = opts.EfSearch
+		}
+		if opts.NProbe > 0 {
+			req.NProbe = opts.NProbe
+		}
+		if opts.DistanceMetric != "" {
+			req.DistanceMetric = opts.DistanceMetric
+		}
+		req.Filters = opts.Filters
+		req.MinScore = opts.MinScore
+		req.IncludeVectors = opts.IncludeVectors
+		req.IncludeAttributes = opts.IncludeAttributes
+		req.Cursor = opts.Cursor
+		req.SparseVector = opts.SparseVector
+		req.Vectors = opts.Vectors
+		req.Extra = opts.Extra
+	}
+
+	return req, nil
+}
+
+// Fetch retrieves stored vectors by ID without a similarity search.
+// If some ids do not exist, the documents that were found are returned along
+// with an ErrNotFound error listing the missing ids.
+func (c *Client) Fetch(ctx context.Context, ids []string, opts *FetchOptions) (_ []VectorResult, err error) {
+	ctx, op := c.startOperation(ctx, "Fetch")
+	defer func() { op.end(err) }()
+
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("%w: no ids provided", ErrValidation)
+	}
+
+	desiredNamespace := ""
+	if opts != nil {
+		desiredNamespace = opts.Namespace
+	}
+	namespace, err := c.namespaceOrDefault(desiredNamespace)
+	if err != nil {
+		return nil, err
+	}
+	op.setNamespace(namespace)
+
+	endpoint, err := c.queryVectorsEndpoint(namespace)
+	if err != nil {
+		return nil, err
+	}
+	params := url.Values{"ids": ids}
+	endpoint += "?" + params.Encode()
+
+	body, err := c.doRequest(ctx, "query", http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := decodeQueryResponse(body, namespace, c.config.UseNumber)
+	if err != nil {
+		return nil, err
+	}
+	c.warn("Fetch", resp.Warnings)
+
+	op.setResultCount(len(resp.Results))
+
+	found := make(map[string]struct{}, len(resp.Results))
+	for _, result := range resp.Results {
+		found[result.ID] = struct{}{}
+	}
+	var missing []string
+	for _, id := range ids {
+		if _, ok := found[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		return resp.Results, fmt.Errorf("%w: ids %s", ErrNotFound, strings.Join(missing, ", "))
+	}
+
+	c.respond("Fetch", resp.Results)
+	return resp.Results, nil
+}
+
+// Exists reports whether a vector with id is stored, using a HEAD request so
+// the vector itself is not transferred. A missing vector is not an error.
+func (c *Client) Exists(ctx context.Context, id string, opts *FetchOptions) (_ bool, err error) {
+	ctx, op := c.startOperation(ctx, "Exists")
+	defer func() { op.end(err) }()
+
+	if id == "" {
+		return false, fmt.Errorf("%w: id is required", ErrValidation)
+	}
+
+	desiredNamespace := ""
+	if opts != nil {
+		desiredNamespace = opts.Namespace
+	}
+	namespace, err := c.namespaceOrDefault(desiredNamespace)
+	if err != nil {
+		return false, err
+	}
+	op.setNamespace(namespace)
+
+	endpoint, err := c.queryVectorsEndpoint(namespace)
+	if err != nil {
+		return false, err
+	}
+	endpoint, err = url.JoinPath(endpoint, id)
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := c.doRequest(ctx, "query", http.MethodHead, endpoint, nil); err != nil {
+		if IsNotFoundError(err) {
+			c.respond("Exists", false)
+			return false, nil
+		}
+		return false, err
+	}
+	c.respond("Exists", true)
+	return true, nil
+}
+
+// ExistsMany reports which of ids are stored, in a single round trip. Unlike
+// Exists it goes through the fetch endpoint, so stored documents are
+// transferred and discarded.
+func (c *Client) ExistsMany(ctx context.Context, ids []string, opts *FetchOptions) (map[string]bool, error) {
+	results, err := c.Fetch(ctx, ids, opts)
+	if err != nil && !IsNotFoundError(err) {
+		return nil, err
+	}
+
+	exists := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		exists[id] = false
+	}
+	for _, result := range results {
+		exists[result.ID] = true
+	}
+	return exists, nil
+}
+
+// Count returns the exact number of vectors in a namespace, optionally
+// restricted to those matching filters. Pass nil filters for the total count.
+func (c *Client) Count(ctx context.Context, namespace string, filters Attributes) (_ int64, err error) {
+	ctx, op := c.startOperation(ctx, "Count")
+	defer func() { op.end(err) }()
+
+	resolved, err := c.namespaceOrDefault(namespace)
+	if err != nil {
+		return 0, err
+	}
+	op.setNamespace(resolved)
+	if err := c.checkFilters(resolved, filters); err != nil {
+		return 0, err
+	}
+
+	endpoint, err := c.queryVectorsEndpoint(resolved)
+	if err != nil {
+		return 0, err
+	}
+	endpoint, err = url.JoinPath(endpoint, "count")
+	if err != nil {
+		return 0, err
+	}
+
+	req := struct {
+		Filters Attributes `json:"filters,omitempty"`
+	}{
+		Filters: filters,
+	}
+
+	body, err := c.doRequest(ctx, "query", http.MethodPost, endpoint, req)
+	if err != nil {
+		return 0, err
+	}
+
+	var resp struct {
+		Count int64 `json:"count"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return 0, decodeError("count", body, err)
+	}
+
+	c.respond("Count", resp.Count)
+	return resp.Count, nil
+}
+
+// Delete removes vectors by ID. Large id lists are split into sequential
+// requests of the client's batch size (or opts.BatchSize). If a batch fails,
+// the returned *BatchError reports how many ids earlier batches submitted;
+// with opts.ContinueOnError every batch is attempted and the failures are
+// joined.
+func (c *Client) Delete(ctx context.Context, ids []string, opts *DeleteOptions) (err error) {
+	ctx, op := c.startOperation(ctx, "Delete")
+	defer func() { op.end(err) }()
+
+	if len(ids) == 0 {
+		return fmt.Errorf("%w: no ids provided", ErrValidation)
+	}
+
+	desiredNamespace := ""
+	if opts != nil {
+		desiredNamespace = opts.Namespace
+	}
+	namespace, err := c.namespaceOrDefault(desiredNamespace)
+	if err != nil {
+		return err
+	}
+	op.setNamespace(namespace)
+
+	endpoint, err := c.ingestVectorsEndpoint(namespace)
+	if err != nil {
+		return err
+	}
+
+	batchSize := c.config.UpsertBatchSize
+	continueOnError := false
+	if opts != nil {
+		if opts.BatchSize > 0 {
+			batchSize = opts.BatchSize
+		}
+		continueOnError = opts.ContinueOnError
+	}
+
+	batches := chunk(ids, batchSize)
+	var (
+		committed int
+		errs      []error
+	)
+	for i, batch := range batches {
+		req := struct {
+			IDs []string `json:"ids"`
+		}{
+			IDs: batch,
+		}
+		if _, err := c.doRequest(ctx, "ingest", http.MethodDelete, endpoint, req); err != nil {
+			if len(batches) == 1 {
+				return err
+			}
+			errs = append(errs, &BatchError{BatchIndex: i, Committed: committed, Err: err})
+			if !continueOnError || ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		committed += len(batch)
+	}
+
+	return errors.Join(errs...)
+}
+
+// DeleteByFilter removes all vectors matching filters and returns the number
+// deleted. Empty filters are rejected unless opts.AllowDeleteAll is set.
+func (c *Client) DeleteByFilter(ctx context.Context, filters Attributes, opts *DeleteOptions) (_ int64, err error) {
+	ctx, op := c.startOperation(ctx, "DeleteByFilter")
+	defer func() { op.end(err) }()
+
+	if len(filters) == 0 && (opts == nil || !opts.AllowDeleteAll) {
+		return 0, fmt.Errorf("%w: filters are required unless AllowDeleteAll is set", ErrValidation)
+	}
+
+	desiredNamespace := ""
+	if opts != nil {
+		desiredNamespace = opts.Namespace
+	}
+	namespace, err := c.namespaceOrDefault(desiredNamespace)
+	if err != nil {
+		return 0, err
+	}
+	op.setNamespace(namespace)
+	if err := c.checkFilters(namespace, filters); err != nil {
+		return 0, err
+	}
+
+	endpoint, err := c.ingestVectorsEndpoint(namespace)
+	if err != nil {
+		return 0, err
+	}
+
+	req := struct {
+		Filters Attributes `json:"filters"`
+	}{
+		Filters: filters,
+	}
+	if req.Filters == nil {
+		req.Filters = Attributes{}
+	}
+
+	body, err := c.doRequest(ctx, "ingest", http.MethodDelete, endpoint, req)
+	if err != nil {
+		return 0, err
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		c.respond("DeleteByFilter", int64(0))
+		return 0, nil
+	}
+
+	var resp struct {
+		Deleted int64 `json:"deleted"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return 0, decodeError("delete", body, err)
+	}
+
+	c.respond("DeleteByFilter", resp.Deleted)
+	return resp.Deleted, nil
+}
+
+// GetNamespace returns namespace information.
+func (c *Client) GetNamespace(ctx context.Context, namespace string) (_ *NamespaceInfo, err error) {
+	ctx, op := c.startOperation(ctx, "GetNamespace")
+	defer func() { op.end(err) }()
+
+	namespace, err = c.namespaceOrDefault(namespace)
+	if err != nil {
+		return nil, err
+	}
+	op.setNamespace(namespace)
+
+	endpoint, err := c.joinURL(c.config.QueryURL, "v1", "namespaces", namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := c.doRequest(ctx, "query", http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var info NamespaceInfo
+	if err := json.Unmarshal(body, &info); err != nil {
+		return nil, decodeError("namespace", body, err)
+	}
+
+	c.respond("GetNamespace", &info)
+	return &info, nil
+}
+
+// DescribeNamespace returns a namespace's info and compaction status in one
+// struct, fetched from the query and ingest services. If either service does
+// not know the namespace, the returned error wraps ErrNotFound.
+// PendingCompaction comes from the namespace info when the server reports it,
+// and otherwise from the WAL entry count.
+func (c *Client) DescribeNamespace(ctx context.Context, namespace string) (_ *NamespaceDescription, err error) {
+	ctx, op := c.startOperation(ctx, "DescribeNamespace")
+	defer func() { op.end(err) }()
+
+	namespace, err = c.namespaceOrDefault(namespace)
+	if err != nil {
+		return nil, err
+	}
+	op.setNamespace(namespace)
+
+	info, err := c.GetNamespace(ctx, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("describe namespace %q: %w", namespace, err)
+	}
+	status, err := c.GetNamespaceStatus(ctx, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("describe namespace %q: status: %w", namespace, err)
+	}
+
+	desc := &NamespaceDescription{
+		Namespace:         info.Namespace,
+		ApproxCount:       info.ApproxCount,
+		Dimensions:        info.Dimensions,
+		PendingCompaction: status.Pending(),
+		LastRun:           status.LastRun,
+		WALFiles:          status.WALFiles,
+		WALEntries:        status.WALEntries,
+		Segments:          status.Segments,
+		TotalVecs:         status.TotalVecs,
+	}
+	if desc.Namespace == "" {
+		desc.Namespace = namespace
+	}
+	if desc.Dimensions == 0 {
+		desc.Dimensions = status.Dimensions
+	}
+	if info.PendingCompaction != nil {
+		desc.PendingCompaction = *info.PendingCompaction
+	}
+	c.respond("DescribeNamespace", desc)
+	return desc, nil
+}
+
+// CreateNamespace creates a namespace. Creating a namespace that already
+// exists returns ErrConflict.
+func (c *Client) CreateNamespace(ctx context.Context, name string, opts *CreateNamespaceOptions) (err error) {
+	ctx, op := c.startOperation(ctx, "CreateNamespace")
+	defer func() { op.end(err) }()
+
+	if err := c.validateNamespace(name); err != nil {
+		return err
+	}
+	op.setNamespace(name)
+
+	req := struct {
+		Namespace      string         `json:"namespace"`
+		Dimensions     int            `json:"dimensions,omitempty"`
+		DistanceMetric DistanceMetric `json:"distance_metric,omitempty"`
+	}{
+		Namespace:      name,
+		DistanceMetric: c.config.DefaultDistanceMetric,
+	}
+	if opts != nil {
+		if opts.Dimensions < 0 {
+			return fmt.Errorf("%w: dimensions must be a positive integer", ErrValidation)
+		}
+		if err := validateMetric(opts.DistanceMetric); err != nil {
+			return err
+		}
+		req.Dimensions = opts.Dimensions
+		req.DistanceMetric = cmp.Or(opts.DistanceMetric, req.DistanceMetric)
+	}
+
+	endpoint, err := c.joinURL(c.config.IngestURL, "v1", "namespaces")
+	if err != nil {
+		return err
+	}
+
+	_, err = c.doRequest(ctx, "ingest", http.MethodPost, endpoint, req)
+	return err
+}
+
+// DeleteNamespace drops a namespace and all of its vectors. Deleting a
+// namespace that does not exist returns ErrNotFound.
+func (c *Client) DeleteNamespace(ctx context.Context, name string) (err error) {
+	ctx, op := c.startOperation(ctx, "DeleteNamespace")
+	defer func() { op.end(err) }()
+
+	if err := c.validateNamespace(name); err != nil {
+		return err
+	}
+	op.setNamespace(name)
+
+	endpoint, err := c.joinURL(c.config.IngestURL, "v1", "namespaces", name)
+	if err != nil {
+		return err
+	}
+
+	if _, err := c.doRequest(ctx, "ingest", http.MethodDelete, endpoint, nil); err != nil {
+		return err
+	}
+
+	c.dimsMu.Lock()
+	delete(c.dims, name)
+	c.dimsMu.Unlock()
+	c.metricsMu.Lock()
+	delete(c.metrics, name)
+	c.metricsMu.Unlock()
+	return nil
+}
+
+// ListNamespaces returns all namespaces with their counts and dimensions,
+// following page cursors until the listing is exhausted. Use
+// ListNamespacesPage to bound memory on deployments with many namespaces.
+func (c *Client) ListNamespaces(ctx context.Context) (_ NamespaceList, err error) {
+	ctx, op := c.startOperation(ctx, "ListNamespaces")
+	defer func() { op.end(err) }()
+
+	var (
+		all    NamespaceList
+		cursor string
+	)
+	for {
+		page, next, err := c.listNamespacesPage(ctx, ListOptions{Cursor: cursor})
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+		if next == "" {
+			break
+		}
+		if next == cursor {
+			return nil, fmt.Errorf("list namespaces: server repeated cursor %q", next)
+		}
+		cursor = next
+	}
+	op.setResultCount(len(all))
+
+	c.respond("ListNamespaces", all)
+	return all, nil
+}
+
+// ListNamespacesPage returns one page of namespaces and the cursor for the
+// next page, which is empty on the last page.
+func (c *Client) ListNamespacesPage(ctx context.Context, opts ListOptions) (_ []NamespaceInfo, nextCursor string, err error) {
+	ctx, op := c.startOperation(ctx, "ListNamespacesPage")
+	defer func() { op.end(err) }()
+
+	namespaces, nextCursor, err := c.listNamespacesPage(ctx, opts)
+	if err != nil {
+		return nil, "", err
+	}
+	op.setResultCount(len(namespaces))
+
+	c.respond("ListNamespacesPage", namespaces)
+	return namespaces, nextCursor, nil
+}
+
+func (c *Client) listNamespacesPage(ctx context.Context, opts ListOptions) ([]NamespaceInfo, string, error) {
+	if opts.Limit < 0 {
+		return nil, "", fmt.Errorf("%w: limit must be a positive integer", ErrValidation)
+	}
+
+	endpoint, err := c.joinURL(c.config.QueryURL, "v1", "namespaces")
+	if err != nil {
+		return nil, "", err
+	}
+	params := url.Values{}
+	if opts.Limit > 0 {
+		params.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.Cursor != "" {
+		params.Set("cursor", opts.Cursor)
+	}
+	if len(params) > 0 {
+		endpoint += "?" + params.Encode()
+	}
+
+	body, err := c.doRequest(ctx, "query", http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return decodeNamespaces(body)
+}
+
+// Status returns ingest service status.
+func (c *Client) Status(ctx context.Context) (_ *CompactionStatus, err error) {
+	ctx, op := c.startOperation(ctx, "Status")
+	defer func() { op.end(err) }()
+
+	endpoint, err := c.joinURL(c.config.IngestURL, "status")
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := c.doRequest(ctx, "ingest", http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := decodeCompactionStatus("status", body)
+	if err != nil {
+		return nil, err
+	}
+	c.respond("Status", status)
+	return status, nil
+}
+
+// GetNamespaceStatus returns status information for a namespace.
+func (c *Client) GetNamespaceStatus(ctx context.Context, namespace string) (_ *CompactionStatus, err error) {
+	ctx, op := c.startOperation(ctx, "GetNamespaceStatus")
+	defer func() { op.end(err) }()
+
+	resolved, err := c.namespaceOrDefault(namespace)
+	if err != nil {
+		return nil, err
+	}
+	op.setNamespace(resolved)
+
+	endpoint, err := c.joinURL(c.config.IngestURL, "v1", "namespaces", resolved, "status")
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := c.doRequest(ctx, "ingest", http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := decodeCompactionStatus("namespace status", body)
+	if err != nil {
+		return nil, err
+	}
+	c.respond("GetNamespaceStatus", status)
+	return status, nil
+}
+
+// AllNamespaceStatuses returns the status of every namespace from
+// ListNamespaces, fetching up to 8 at a time. Namespaces whose status could
+// not be fetched are left out of the map and reported in the returned error,
+// one "namespace %q: ..." error per failure joined with errors.Join; the map
+// holds the rest. The map is nil only when listing the namespaces fails.
+func (c *Client) AllNamespaceStatuses(ctx context.Context) (_ map[string]*CompactionStatus, err error) {
+	ctx, op := c.startOperation(ctx, "AllNamespaceStatuses")
+	defer func() { op.end(err) }()
+
+	namespaces, err := c.ListNamespaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		sem      = make(chan struct{}, statusConcurrency)
+		statuses = make(map[string]*CompactionStatus, len(namespaces))
+		errs     = make([]error, len(namespaces))
+	)
+	for i, name := range namespaces.Names() {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, name string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			status, err := c.GetNamespaceStatus(ctx, name)
+			if err != nil {
+				errs[i] = fmt.Errorf("namespace %q: %w", name, err)
+				return
+			}
+			mu.Lock()
+			statuses[name] = status
+			mu.Unlock()
+		}(i, name)
+	}
+	wg.Wait()
+	op.setResultCount(len(statuses))
+
+	if err := errors.Join(errs...); err != nil {
+		return statuses, err
+	}
+	c.respond("AllNamespaceStatuses", statuses)
+	return statuses, nil
+}
+
+// Compact triggers manual compaction for a namespace.
+func (c *Client) Compact(ctx context.Context, namespace ...string) (err error) {
+	ctx, op := c.startOperation(ctx, "Compact")
+	defer func() { op.end(err) }()
+
+	ns := ""
+	if len(namespace) > 0 {
+		ns = namespace[0]
+	}
+	resolved, err := c.namespaceOrDefault(ns)
+	if err != nil {
+		return err
+	}
+	op.setNamespace(resolved)
+
+	endpoint, err := c.joinURL(c.config.IngestURL, "v1", "namespaces", resolved, "compact")
+	if err != nil {
+		return err
+	}
+
+	_, err = c.doRequest(ctx, "ingest", http.MethodPost, endpoint, nil)
+	return err
+}
+
+// CompactAndWait triggers compaction of namespace and then polls
+// GetNamespaceStatus until no WAL entries are pending, returning the final
+// status. Polling stops with an error when ctx is done or opts.Timeout
+// elapses; the error then wraps the context error and the last status seen
+// (nil if none) is returned with it. Transient status errors are retried on
+// the next poll.
+func (c *Client) CompactAndWait(ctx context.Context, namespace string, opts CompactWaitOptions) (*CompactionStatus, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	interval := opts.PollInterval
+	if interval <= 0 {
+		interval = c.config.PollInterval
+	}
+
+	if err := c.Compact(ctx, namespace); err != nil {
+		return nil, err
+	}
+
+	var last *CompactionStatus
+	for {
+		status, err := c.GetNamespaceStatus(ctx, namespace)
+		switch {
+		case err == nil:
+			last = status
+			if opts.OnStatus != nil {
+				opts.OnStatus(status)
+			}
+			if !status.Pending() {
+				return status, nil
+			}
+		case ctx.Err() == nil && !isRetryable(err):
+			return last, err
+		}
+
+		if waitErr := sleepContext(ctx, interval); waitErr != nil {
+			if last == nil {
+				return nil, fmt.Errorf("waiting for compaction: %w", waitErr)
+			}
+			return last, fmt.Errorf("compaction still pending with %d WAL entries: %w", last.WALEntries, waitErr)
+		}
+	}
+}
+
+// Flush asks the ingest service to flush the namespace's write-ahead log so
+// recently upserted vectors become visible to queries, e.g. for
+// read-after-write checks in tests. Unlike Compact, it does not merge
+// segments. Servers without a flush endpoint are sent a compaction instead,
+// which also flushes the WAL but does more work.
+func (c *Client) Flush(ctx context.Context, namespace string) (err error) {
+	ctx, op := c.startOperation(ctx, "Flush")
+	defer func() { op.end(err) }()
+
+	resolved, err := c.namespaceOrDefault(namespace)
+	if err != nil {
+		return err
+	}
+	op.setNamespace(resolved)
+
+	endpoint, err := c.joinURL(c.config.IngestURL, "v1", "namespaces", resolved, "flush")
+	if err != nil {
+		return err
+	}
+
+	_, err = c.doRequest(ctx, "ingest", http.MethodPost, endpoint, nil)
+	if isUnsupportedEndpoint(err) {
+		return c.Compact(ctx, resolved)
+	}
+	return err
+}
+
+func (c *Client) ingestVectorsEndpoint(namespace string) (string, error) {
+	if namespace == "" {
+		return "", fmt.Errorf("%w: namespace is required", ErrValidation)
+	}
+	return c.joinURL(c.config.IngestURL, "v1", "vectors", namespace)
+}
+
+func (c *Client) queryVectorsEndpoint(namespace string) (string, error) {
+	if namespace == "" {
+		return "", fmt.Errorf("%w: namespace is required", ErrValidation)
+	}
+	return c.joinURL(c.config.QueryURL, "v1", "vectors", namespace)
+}
+
+func (c *Client) serviceBaseURL(service string) (string, error) {
+	switch strings.ToLower(service) {
+	case "query":
+		return c.config.QueryURL, nil
+	case "ingest":
+		return c.config.IngestURL, nil
+	default:
+		return "", fmt.Errorf("%w: unknown service %q", ErrValidation, service)
+	}
+}
+
+// rememberMetric records the distance metric last upserted to namespace when
+// metric inference is enabled.
+func (c *Client) rememberMetric(namespace string, metric DistanceMetric) {
+	if !c.config.MetricInference || metric == "" {
+		return
+	}
+	c.metricsMu.Lock()
+	c.metrics[namespace] = metric
+	c.metricsMu.Unlock()
+}
+
+// resolveMetric fills in req.DistanceMetric when the query does not specify
+// one: from the metric remembered for namespace when metric inference is
+// enabled, and otherwise from the client's default metric.
+func (c *Client) resolveMetric(namespace string, req *queryRequest) {
+	if req.DistanceMetric != "" {
+		return
+	}
+	if c.config.MetricInference {
+		c.metricsMu.Lock()
+		req.DistanceMetric = c.metrics[namespace]
+		c.metricsMu.Unlock()
+	}
+	req.DistanceMetric = cmp.Or(req.DistanceMetric, c.config.DefaultDistanceMetric)
+}
+
+// namespaceDimensions returns the cached dimensions for namespace, fetching
+// them on first use. It returns 0 when the namespace does not exist yet or has
+// no dimensions, in which case no check is performed.
+func (c *Client) namespaceDimensions(ctx context.Context, namespace string) (int, error) {
+	c.dimsMu.Lock()
+	dims, ok := c.dims[namespace]
+	c.dimsMu.Unlock()
+	if ok {
+		return dims, nil
+	}
+
+	info, err := c.GetNamespace(ctx, namespace)
+	if err != nil {
+		if IsNotFoundError(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("fetch namespace dimensions: %w", err)
+	}
+	if info.Dimensions > 0 {
+		c.dimsMu.Lock()
+		c.dims[namespace] = info.Dimensions
+		c.dimsMu.Unlock()
+	}
+	return info.Dimensions, nil
+}
+
+// checkDimensions validates vectors against the namespace dimensions when
+// dimension checking is enabled. The dimensions are fetched at most once per
+// call. When label is non-empty, it is a format with one %d verb that
+// prefixes the error with the index of the offending vector.
+func (c *Client) checkDimensions(ctx context.Context, namespace string, vectors []Vector, label string) error {
+	if !c.config.DimensionCheck || len(vectors) == 0 {
+		return nil
+	}
+	dims, err := c.namespaceDimensions(ctx, namespace)
 	if err != nil {
 		return err
 	}
+	for i, v := range vectors {
+		if err := c.validateVector(v, dims); err != nil {
+			if label == "" {
+				return err
+			}
+			return fmt.Errorf(label+": %w", i, err)
+		}
+	}
+	return nil
+}
 
-	endpoint, err := c.ingestVectorsEndpoint(namespace)
+// checkDocumentDimensions validates the dense vectors of docs against the
+// namespace dimensions when dimension checking is enabled, fetching the
+// dimensions at most once.
+func (c *Client) checkDocumentDimensions(ctx context.Context, namespace string, docs []Document) error {
+	if !c.config.DimensionCheck || !slices.ContainsFunc(docs, func(doc Document) bool { return len(doc.Vector) > 0 }) {
+		return nil
+	}
+	dims, err := c.namespaceDimensions(ctx, namespace)
 	if err != nil {
 		return err
 	}
-
-	req := struct {
-		IDs []string `json:"ids"`
-	}{
-		IDs: ids,
+	for i, doc := range docs {
+		if len(doc.Vector) == 0 {
+			continue
+		}
+		if err := c.validateVector(doc.Vector, dims); err != nil {
+			return fmt.Errorf("document %d: %w", i, err)
+		}
 	}
+	return nil
+}
 
-	_, err = c.doRequest(ctx, http.MethodDelete, endpoint, req)
-	return err
+// namespaceOrDefault resolves namespace, falling back to DefaultNamespace and
+// then to the deprecated Namespace, and validates the result.
+func (c *Client) namespaceOrDefault(namespace string) (string, error) {
+	switch {
+	case namespace != "":
+	case c.config.DefaultNamespace != "":
+		namespace = c.config.DefaultNamespace
+	case c.config.Namespace != "":
+		namespace = c.config.Namespace
+	}
+	if err := c.validateNamespace(namespace); err != nil {
+		return "", err
+	}
+	return namespace, nil
 }
 
-// GetNamespace returns namespace information.
-func (c *Client) GetNamespace(ctx context.Context, namespace string) (*NamespaceInfo, error) {
+// validateNamespace rejects names that the server would refuse or that would
+// change the request path once joined into the URL: empty names, "." and
+// "..", and names containing whitespace, control characters, or path
+// separators. When a NamespacePattern is configured the name must also match
+// it.
+func (c *Client) validateNamespace(namespace string) error {
 	if namespace == "" {
-		var err error
-		namespace, err = c.namespaceOrDefault(namespace)
-		if err != nil {
-			return nil, err
+		return fmt.Errorf("%w: namespace is required", ErrValidation)
+	}
+	if namespace == "." || namespace == ".." {
+		return fmt.Errorf("%w: invalid namespace %q", ErrValidation, namespace)
+	}
+	for _, r := range namespace {
+		if r == '/' || r == '\\' || unicode.IsSpace(r) || unicode.IsControl(r) {
+			return fmt.Errorf("%w: namespace %q contains illegal character %q", ErrValidation, namespace, r)
 		}
 	}
-
-	endpoint, err := joinURL(c.config.QueryURL, "v1", "namespaces", namespace)
-	if err != nil {
-		return nil, err
+	if c.config.NamespacePattern != nil && !c.config.NamespacePattern.MatchString(namespace) {
+		return fmt.Errorf("%w: namespace %q does not match %s", ErrValidation, namespace, c.config.NamespacePattern)
 	}
+	return nil
+}
 
-	body, err := c.doRequest(ctx, http.MethodGet, endpoint, nil)
+// doRequest sends body as JSON to endpoint on service and returns the
+// response body.
+func (c *Client) doRequest(ctx context.Context, service, method, endpoint string, body any) ([]byte, error) {
+	resp, err := c.do(ctx, service, method, endpoint, body)
 	if err != nil {
 		return nil, err
 	}
-
-	var info NamespaceInfo
-	if err := json.Unmarshal(body, &info); err != nil {
-		return nil, fmt.Errorf("decode namespace response: %w", err)
-	}
-
-	return &info, nil
+	return resp.Body, nil
 }
 
-// ListNamespaces returns namespace info entries.
-func (c *Client) ListNamespaces(ctx context.Context) ([]NamespaceInfo, error) {
-	endpoint, err := joinURL(c.config.QueryURL, "v1", "namespaces")
-	if err != nil {
-		return nil, err
+// do sends body as JSON to endpoint on service ("query" or "ingest") with
+// retries and returns the successful response. It underlies Do and every
+// typed method except the streaming ones.
+func (c *Client) do(ctx context.Context, service, method, endpoint string, body any) (*RawResponse, error) {
+	if ctx == nil {
+		ctx = context.Background()
 	}
 
-	body, err := c.doRequest(ctx, http.MethodGet, endpoint, nil)
+	data, payload, compressed, err := c.encodeBody(body)
 	if err != nil {
 		return nil, err
 	}
 
-	namespaces, err := decodeNamespaces(body)
+	var resp *RawResponse
+	err = c.withRetries(ctx, service, method, func() error {
+		var err error
+		resp, err = c.send(ctx, method, endpoint, data, payload, compressed)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
-
-	return namespaces, nil
+	return resp, nil
 }
 
-// Status returns ingest service status.
-func (c *Client) Status(ctx context.Context) (*IngestStatus, error) {
-	endpoint, err := joinURL(c.config.IngestURL, "status")
-	if err != nil {
-		return nil, err
+// encodeBody marshals body to JSON and, when compression is enabled and the
+// body is large enough, gzips it. data is the uncompressed JSON and payload
+// is what goes on the wire.
+func (c *Client) encodeBody(body any) (data, payload []byte, compressed bool, err error) {
+	if body == nil {
+		return nil, nil, false, nil
+	}
+	if c.config.RequestSigner != nil {
+		data, err = canonicalJSON(body)
+	} else {
+		data, err = json.Marshal(body)
 	}
-
-	body, err := c.doRequest(ctx, http.MethodGet, endpoint, nil)
 	if err != nil {
-		return nil, err
+		return nil, nil, false, fmt.Errorf("marshal request: %w", err)
 	}
-
-	var status IngestStatus
-	if err := json.Unmarshal(body, &status); err != nil {
-		return nil, fmt.Errorf("decode status response: %w", err)
+	payload = data
+	if c.config.Compression && len(data) >= compressionThreshold {
+		payload, err = gzipBytes(data)
+		if err != nil {
+			return nil, nil, false, fmt.Errorf("compress request: %w", err)
+		}
+		compressed = true
 	}
-
-	return &status, nil
+	return data, payload, compressed, nil
 }
 
-// GetNamespaceStatus returns status information for a namespace.
-func (c *Client) GetNamespaceStatus(ctx context.Context, namespace string) (*NamespaceStatus, error) {
-	resolved, err := c.namespaceOrDefault(namespace)
-	if err != nil {
-		return nil, err
-	}
-
-	endpoint, err := joinURL(c.config.IngestURL, "v1", "namespaces", resolved, "status")
-	if err != nil {
-		return nil, err
+// withRetries calls attempt until it succeeds, fails with a non-retryable
+// error, or the retry budget is spent, consulting service's circuit breaker
+// before each attempt. Transport failures are retried only when resending
+// the request is safe; see canResend.
+func (c *Client) withRetries(ctx context.Context, service, method string, attempt func() error) error {
+	breaker := c.breakerFor(service)
+	for n := 0; ; n++ {
+		if c.closed.Load() {
+			return ErrClientClosed
+		}
+		if breaker != nil && !breaker.allow(time.Now()) {
+			return ErrCircuitOpen
+		}
+		start := time.Now()
+		err := attempt()
+		if breaker != nil {
+			breaker.record(ctx, err, time.Now())
+		}
+		if err == nil || n >= c.config.Retry.MaxRetries || !isRetryable(err) || !canResend(service, method, err) {
+			return err
+		}
+		delay := c.config.Retry.delay(n, err)
+		if budgetErr := retryBudget(ctx, delay, time.Since(start)); budgetErr != nil {
+			return errors.Join(err, budgetErr)
+		}
+		if waitErr := sleepContext(ctx, delay); waitErr != nil {
+			return errors.Join(err, waitErr)
+		}
 	}
+}
 
-	body, err := c.doRequest(ctx, http.MethodGet, endpoint, nil)
+// send performs a single HTTP attempt. data is the uncompressed JSON body
+// (used for logging) and payload is what goes on the wire.
+func (c *Client) send(ctx context.Context, method, endpoint string, data, payload []byte, compressed bool) (_ *RawResponse, err error) {
+	req, err := c.newRequest(ctx, method, endpoint, data, payload, compressed)
 	if err != nil {
 		return nil, err
 	}
 
-	var status NamespaceStatus
-	if err := json.Unmarshal(body, &status); err != nil {
-		return nil, fmt.Errorf("decode namespace status response: %w", err)
+	var (
+		statusCode int
+		respBody   []byte
+	)
+	if c.config.Logger != nil {
+		start := time.Now()
+		defer func() {
+			c.logRequest(ctx, req, data, respBody, statusCode, time.Since(start), err)
+		}()
 	}
 
-	return &status, nil
-}
-
-// Compact triggers manual compaction for a namespace.
-func (c *Client) Compact(ctx context.Context, namespace ...string) error {
-	ns := ""
-	if len(namespace) > 0 {
-		ns = namespace[0]
-	}
-	resolved, err := c.namespaceOrDefault(ns)
+	resp, err := c.http.Do(req)
 	if err != nil {
-		return err
+		return nil, wrapTransportError(ctx, err)
 	}
+	defer resp.Body.Close()
+	statusCode = resp.StatusCode
+	recordStatus(ctx, statusCode)
 
-	endpoint, err := joinURL(c.config.IngestURL, "v1", "namespaces", resolved, "compact")
+	respBody, err = readResponseBody(resp, c.config.MaxResponseBytes)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("read response: %w", err)
 	}
 
-	_, err = c.doRequest(ctx, http.MethodPost, endpoint, nil)
-	return err
-}
-
-func (c *Client) ingestVectorsEndpoint(namespace string) (string, error) {
-	if namespace == "" {
-		return "", fmt.Errorf("%w: namespace is required", ErrValidation)
+	if resp.StatusCode >= 400 {
+		return nil, c.handleError(resp.StatusCode, resp.Header, respBody)
+	}
+	if err := checkContentType(resp.Header.Get("Content-Type"), respBody); err != nil {
+		return nil, err
 	}
-	return joinURL(c.config.IngestURL, "v1", "vectors", namespace)
+
+	return &RawResponse{StatusCode: resp.StatusCode, Header: resp.Header, Body: respBody}, nil
 }
 
-func (c *Client) queryVectorsEndpoint(namespace string) (string, error) {
-	if namespace == "" {
-		return "", fmt.Errorf("%w: namespace is required", ErrValidation)
+// wrapTransportError wraps a failure from http.Client.Do. Failures meaning
+// the service could not be reached or did not answer in time (refused or
+// reset connections, DNS errors, timeouts) also wrap ErrServiceUnavailable.
+// Cancellation or expiry of ctx reflects the caller and is not classified.
+func wrapTransportError(ctx context.Context, err error) error {
+	if ctx.Err() == nil && isUnreachable(err) {
+		return fmt.Errorf("%w: do request: %w", ErrServiceUnavailable, err)
 	}
-	return joinURL(c.config.QueryURL, "v1", "vectors", namespace)
+	return fmt.Errorf("do request: %w", err)
 }
 
-func (c *Client) serviceBaseURL(service string) (string, error) {
-	switch strings.ToLower(service) {
-	case "query":
-		return c.config.QueryURL, nil
-	case "ingest":
-		return c.config.IngestURL, nil
+// isUnreachable reports whether err is a network-level failure to reach or
+// hear back from the server.
+func isUnreachable(err error) bool {
+	var (
+		opErr  *net.OpError
+		dnsErr *net.DNSError
+		netErr net.Error
+	)
+	switch {
+	case errors.As(err, &opErr), errors.As(err, &dnsErr):
+		return true
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return true
 	default:
-		return "", fmt.Errorf("%w: unknown service %q", ErrValidation, service)
+		return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+			errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
 	}
 }
 
-func (c *Client) namespaceOrDefault(namespace string) (string, error) {
-	if namespace != "" {
-		return namespace, nil
-	}
-	if c.config.DefaultNamespace != "" {
-		return c.config.DefaultNamespace, nil
-	}
-	if c.config.Namespace != "" {
-		return c.config.Namespace, nil
-	}
-	return "", fmt.Errorf("%w: namespace is required", ErrValidation)
+// canResend reports whether a request that failed with err may be sent
+// again. An error response or a failure to connect means the server did not
+// act on the request. Any other transport failure may come after the server
+// received it, so only reads are resent: GET, HEAD, and OPTIONS requests and
+// requests to the query service, which does not write. Resending an upsert
+// or delete could apply it twice.
+func canResend(service, method string, err error) bool {
+	var tideErr *TidepoolError
+	if errors.As(err, &tideErr) || isConnectError(err) {
+		return true
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return service == "query"
 }
 
-func (c *Client) doRequest(ctx context.Context, method, endpoint string, body any) ([]byte, error) {
-	if ctx == nil {
-		ctx = context.Background()
+// isConnectError reports whether err is a failure to establish a connection,
+// before any of the request was sent.
+func isConnectError(err error) bool {
+	var (
+		opErr  *net.OpError
+		dnsErr *net.DNSError
+	)
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
 	}
+	return errors.As(err, &dnsErr) || errors.Is(err, syscall.ECONNREFUSED)
+}
 
+// newRequest builds an HTTP request carrying payload with the client's
+// standard headers and authentication applied.
+func (c *Client) newRequest(ctx context.Context, method, endpoint string, data, payload []byte, compressed bool) (*http.Request, error) {
 	var reqBody io.Reader
-	if body != nil {
-		data, err := json.Marshal(body)
-		if err != nil {
-			return nil, fmt.Errorf("marshal request: %w", err)
-		}
-		reqBody = bytes.NewReader(data)
+	if payload != nil {
+		reqBody = bytes.NewReader(payload)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, method, endpoint, reqBody)
@@ -453,32 +2633,83 @@ func (c *Client) doRequest(ctx context.Context, method, endpoint string, body an
 		return nil, fmt.Errorf("create request: %w", err)
 	}
 
+	for key, values := range requestHeaders(ctx) {
+		req.Header[key] = append([]string(nil), values...)
+	}
 	req.Header.Set("Accept", "application/json")
-	if body != nil {
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("User-Agent", c.userAgent())
+	if payload != nil {
 		req.Header.Set("Content-Type", "application/json")
 	}
+	if compressed {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if err := c.applyAuth(ctx, req); err != nil {
+		return nil, err
+	}
+	if c.config.NamespaceHeader != "" {
+		if namespace, ok := pathNamespace(req.URL); ok {
+			req.Header.Set(c.config.NamespaceHeader, namespace)
+		}
+	}
+	if c.config.RequestSigner != nil {
+		for key, values := range c.config.RequestSigner(data) {
+			req.Header[http.CanonicalHeaderKey(key)] = append([]string(nil), values...)
+		}
+	}
+	return req, nil
+}
 
-	resp, err := c.http.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("do request: %w", err)
+// checkContentType rejects a successful response whose body is not JSON,
+// such as an HTML error page served with 200 by a misconfigured proxy. An
+// empty body or missing Content-Type is accepted, as is text/plain: Go
+// servers that don't set a Content-Type sniff JSON bodies as text/plain.
+func checkContentType(contentType string, body []byte) error {
+	if len(bytes.TrimSpace(body)) == 0 || isJSONContentType(contentType) {
+		return nil
 	}
-	defer resp.Body.Close()
+	return fmt.Errorf("unexpected response content type %q (body: %q)", contentType, bodySnippet(body))
+}
 
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("read response: %w", err)
+// isJSONContentType reports whether contentType may carry a JSON body, as
+// described on checkContentType.
+func isJSONContentType(contentType string) bool {
+	if contentType == "" {
+		return true
 	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") || mediaType == "text/plain")
+}
 
-	if resp.StatusCode >= 400 {
-		return nil, c.handleError(resp.StatusCode, respBody)
+// userAgent returns the User-Agent header value: the configured UserAgent, if
+// any, followed by the library's own product token.
+func (c *Client) userAgent() string {
+	if c.config.UserAgent == "" {
+		return defaultUserAgent
 	}
+	return c.config.UserAgent + " " + defaultUserAgent
+}
 
-	return respBody, nil
+func (c *Client) applyAuth(ctx context.Context, req *http.Request) error {
+	if c.config.AuthHeader != "" {
+		req.Header.Set(c.config.AuthHeader, c.config.AuthValue)
+	}
+	if c.config.TokenProvider != nil {
+		token, err := c.config.TokenProvider(ctx)
+		if err != nil {
+			return fmt.Errorf("get auth token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return nil
 }
 
-func (c *Client) handleError(statusCode int, body []byte) error {
+func (c *Client) handleError(statusCode int, header http.Header, body []byte) error {
 	var errResp struct {
-		Error string `json:"error"`
+		Error   string         `json:"error"`
+		Code    string         `json:"code"`
+		Details map[string]any `json:"details"`
 	}
 	_ = json.Unmarshal(body, &errResp)
 
@@ -490,52 +2721,216 @@ func (c *Client) handleError(statusCode int, body []byte) error {
 	tideErr := &TidepoolError{
 		Message:    msg,
 		StatusCode: statusCode,
+		Code:       errResp.Code,
+		Details:    errResp.Details,
+		RetryAfter: parseRetryAfter(header.Get("Retry-After"), time.Now()),
 		Response:   body,
 	}
 
 	switch statusCode {
 	case http.StatusBadRequest, http.StatusRequestEntityTooLarge:
+		if slices.ContainsFunc(dimensionMismatchCodes, func(code string) bool { return strings.EqualFold(code, tideErr.Code) }) {
+			return errors.Join(ErrDimensionMismatch, tideErr)
+		}
 		return errors.Join(ErrValidation, tideErr)
 	case http.StatusNotFound:
 		return errors.Join(ErrNotFound, tideErr)
+	case http.StatusConflict:
+		return errors.Join(ErrConflict, tideErr)
 	case http.StatusServiceUnavailable:
 		return errors.Join(ErrServiceUnavailable, tideErr)
+	case http.StatusTooManyRequests:
+		return errors.Join(ErrRateLimited, tideErr)
 	default:
+		if statusCode >= 500 {
+			return errors.Join(ErrServer, tideErr)
+		}
 		return tideErr
 	}
 }
 
-func joinURL(base string, parts ...string) (string, error) {
+// parseRetryAfter parses a Retry-After header given in seconds or as an
+// HTTP date. It returns zero for missing or invalid values.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if d := date.Sub(now); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// readResponseBody reads resp.Body, decompressing it when the server sent
+// Content-Encoding: gzip. Because doRequest sets Accept-Encoding itself, the
+// transport never decompresses transparently, regardless of which
+// http.Client is in use. It fails with ErrResponseTooLarge once the
+// decompressed body exceeds limit bytes; a negative limit reads the whole
+// body.
+func readResponseBody(resp *http.Response, limit int64) ([]byte, error) {
+	var body io.Reader = resp.Body
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		zr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("decompress: %w", err)
+		}
+		defer zr.Close()
+		body = zr
+	}
+	if limit < 0 {
+		return io.ReadAll(body)
+	}
+	data, err := io.ReadAll(io.LimitReader(body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%w: exceeds %d bytes", ErrResponseTooLarge, limit)
+	}
+	return data, nil
+}
+
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// joinURL builds an endpoint from a service base URL and path segments,
+// inserting the configured PathPrefix between them.
+func (c *Client) joinURL(base string, parts ...string) (string, error) {
 	if base == "" {
 		return "", fmt.Errorf("%w: base URL is required", ErrValidation)
 	}
+	if prefix := strings.Trim(c.config.PathPrefix, "/"); prefix != "" {
+		parts = append([]string{prefix}, parts...)
+	}
 	return url.JoinPath(base, parts...)
 }
 
-func decodeQueryResponse(data []byte, fallbackNamespace string) (*QueryResponse, error) {
-	var direct []VectorResult
+// pathNamespace extracts the namespace from a request URL of the form
+// .../v1/vectors/{namespace}/... or .../v1/namespaces/{namespace}/..., as
+// built by joinURL. Namespaces cannot contain "/", so the segment is the
+// whole name.
+func pathNamespace(u *url.URL) (string, bool) {
+	segments := strings.Split(u.EscapedPath(), "/")
+	for i := 0; i+2 < len(segments); i++ {
+		if segments[i] != "v1" || (segments[i+1] != "vectors" && segments[i+1] != "namespaces") {
+			continue
+		}
+		namespace, err := url.PathUnescape(segments[i+2])
+		if err != nil || namespace == "" {
+			return "", false
+		}
+		return namespace, true
+	}
+	return "", false
+}
+
+// dedupeResults drops results whose Attributes[key] value was already seen
+// on an earlier, better-ranked result, preserving the order of the rest.
+// Results are in server rank order, so the first occurrence is the best
+// scoring one regardless of metric.
+func dedupeResults(results []VectorResult, key string) []VectorResult {
+	seen := make(map[string]struct{}, len(results))
+	out := results[:0:0]
+	for _, result := range results {
+		value, ok := result.Attributes[key]
+		if ok {
+			encoded, err := json.Marshal(value)
+			if err == nil {
+				if _, dup := seen[string(encoded)]; dup {
+					continue
+				}
+				seen[string(encoded)] = struct{}{}
+			}
+		}
+		out = append(out, result)
+	}
+	return out
+}
+
+// decodeError wraps a response decoding failure with a truncated snippet of
+// the body, so that e.g. a proxy's HTML error page is recognizable.
+func decodeError(what string, body []byte, err error) error {
+	return fmt.Errorf("decode %s response: %w (body: %q)", what, err, bodySnippet(body))
+}
+
+// bodySnippet returns at most decodeSnippetLimit bytes of body for use in
+// error messages.
+func bodySnippet(body []byte) string {
+	body = bytes.TrimSpace(body)
+	if len(body) > decodeSnippetLimit {
+		return string(body[:decodeSnippetLimit]) + "..."
+	}
+	return string(body)
+}
+
+// decodeQueryResponse decodes a query response in any of the shapes the
+// server has used. With useNumber, attribute numbers are kept as json.Number.
+func decodeQueryResponse(data []byte, fallbackNamespace string, useNumber bool) (*QueryResponse, error) {
+	if useNumber {
+		return decodeQueryResponseAs[preciseResult](data, fallbackNamespace)
+	}
+	return decodeQueryResponseAs[VectorResult](data, fallbackNamespace)
+}
+
+// preciseResult decodes like VectorResult, but keeps attribute numbers as
+// json.Number.
+type preciseResult VectorResult
+
+func (r *preciseResult) UnmarshalJSON(data []byte) error {
+	return (*VectorResult)(r).decode(data, true)
+}
+
+func decodeQueryResponseAs[T VectorResult | preciseResult](data []byte, fallbackNamespace string) (*QueryResponse, error) {
+	var direct []T
 	if err := json.Unmarshal(data, &direct); err == nil {
 		return &QueryResponse{
-			Results:   direct,
+			Results:   toVectorResults(direct),
 			Namespace: fallbackNamespace,
 		}, nil
 	}
 
+	// Results and Vectors stay raw so that a present but empty or null list
+	// (a no-match query) can be told apart from a missing one.
 	var wrapped struct {
-		Namespace string         `json:"namespace"`
-		Results   []VectorResult `json:"results"`
-		Vectors   []VectorResult `json:"vectors"`
+		Namespace  string          `json:"namespace"`
+		Results    json.RawMessage `json:"results"`
+		Vectors    json.RawMessage `json:"vectors"`
+		NextCursor string          `json:"next_cursor"`
+		TookMS     float64         `json:"took_ms"`
+		Warnings   []string        `json:"warnings"`
 	}
 	if err := json.Unmarshal(data, &wrapped); err != nil {
-		return nil, fmt.Errorf("decode query response: %w", err)
+		return nil, decodeError("query", data, err)
 	}
 
-	results := wrapped.Results
-	if results == nil {
-		results = wrapped.Vectors
+	raw := wrapped.Results
+	if raw == nil {
+		raw = wrapped.Vectors
+	}
+	if raw == nil {
+		return nil, decodeError("query", data, errors.New("missing results"))
 	}
-	if results == nil {
-		return nil, fmt.Errorf("decode query response: missing results")
+	var results []T
+	if err := json.Unmarshal(raw, &results); err != nil {
+		return nil, decodeError("query", data, err)
 	}
 
 	namespace := wrapped.Namespace
@@ -544,43 +2939,234 @@ func decodeQueryResponse(data []byte, fallbackNamespace string) (*QueryResponse,
 	}
 
 	return &QueryResponse{
-		Results:   results,
-		Namespace: namespace,
+		Results:    toVectorResults(results),
+		Namespace:  namespace,
+		NextCursor: wrapped.NextCursor,
+		TookMS:     wrapped.TookMS,
+		Warnings:   wrapped.Warnings,
 	}, nil
 }
 
-func decodeNamespaces(data []byte) ([]NamespaceInfo, error) {
+// toVectorResults converts items to VectorResults. The result is never nil,
+// so an empty response encodes as [] rather than null.
+func toVectorResults[T VectorResult | preciseResult](items []T) []VectorResult {
+	results := make([]VectorResult, len(items))
+	for i, item := range items {
+		results[i] = VectorResult(item)
+	}
+	return results
+}
+
+func decodeBatchQueryResponse(data []byte, fallbackNamespace string, useNumber bool) ([]QueryResponse, error) {
+	var items []json.RawMessage
+	var wrapped struct {
+		Results []json.RawMessage `json:"results"`
+	}
+	if err := json.Unmarshal(data, &wrapped); err == nil && wrapped.Results != nil {
+		items = wrapped.Results
+	} else if err := json.Unmarshal(data, &items); err != nil {
+		return nil, decodeError("batch query", data, err)
+	}
+
+	responses := make([]QueryResponse, 0, len(items))
+	for _, item := range items {
+		resp, err := decodeQueryResponse(item, fallbackNamespace, useNumber)
+		if err != nil {
+			return nil, err
+		}
+		responses = append(responses, *resp)
+	}
+	return responses, nil
+}
+
+// decodeCompactionStatus decodes a service or namespace status response.
+func decodeCompactionStatus(what string, data []byte) (*CompactionStatus, error) {
+	var status CompactionStatus
+	if err := json.Unmarshal(data, &status); err != nil {
+		return nil, decodeError(what, data, err)
+	}
+	return &status, nil
+}
+
+// decodeNamespaces decodes a namespace listing in any of the shapes the
+// server has used, returning the page cursor when one is present.
+func decodeNamespaces(data []byte) ([]NamespaceInfo, string, error) {
 	var wrapped struct {
 		Namespaces []NamespaceInfo `json:"namespaces"`
+		NextCursor string          `json:"next_cursor"`
 	}
 	if err := json.Unmarshal(data, &wrapped); err == nil && wrapped.Namespaces != nil {
-		return wrapped.Namespaces, nil
+		return wrapped.Namespaces, wrapped.NextCursor, nil
 	}
 
 	var direct []NamespaceInfo
 	if err := json.Unmarshal(data, &direct); err == nil {
-		return direct, nil
+		return direct, "", nil
 	}
 
 	var legacyDirect []string
 	if err := json.Unmarshal(data, &legacyDirect); err == nil {
-		return namesToNamespaceInfo(legacyDirect), nil
+		return namesToNamespaceInfo(legacyDirect), "", nil
 	}
 
 	var legacyWrapped struct {
 		Namespaces    []string `json:"namespaces"`
 		NamespaceList []string `json:"namespace_list"`
+		NextCursor    string   `json:"next_cursor"`
 	}
 	if err := json.Unmarshal(data, &legacyWrapped); err != nil {
-		return nil, fmt.Errorf("decode namespaces response: %w", err)
+		return nil, "", decodeError("namespaces", data, err)
 	}
 	if legacyWrapped.Namespaces != nil {
-		return namesToNamespaceInfo(legacyWrapped.Namespaces), nil
+		return namesToNamespaceInfo(legacyWrapped.Namespaces), legacyWrapped.NextCursor, nil
 	}
 	if legacyWrapped.NamespaceList != nil {
-		return namesToNamespaceInfo(legacyWrapped.NamespaceList), nil
+		return namesToNamespaceInfo(legacyWrapped.NamespaceList), legacyWrapped.NextCursor, nil
+	}
+	return nil, "", decodeError("namespaces", data, errors.New("missing namespaces"))
+}
+
+// prepareDocuments rejects duplicate IDs and applies client and upsert
+// options that rewrite documents: it embeds text-only documents when an
+// Embedder is configured, then normalizes vectors if requested. The input
+// slice is never modified.
+func (c *Client) prepareDocuments(ctx context.Context, docs []Document, opts *UpsertOptions) ([]Document, error) {
+	if opts != nil {
+		if err := validateMetric(opts.DistanceMetric); err != nil {
+			return nil, err
+		}
+		if opts.TTL < 0 {
+			return nil, fmt.Errorf("%w: TTL must be non-negative", ErrValidation)
+		}
+	}
+	if err := checkDuplicateIDs(docs); err != nil {
+		return nil, err
+	}
+	for i, doc := range docs {
+		if err := c.validateDocument(doc); err != nil {
+			return nil, fmt.Errorf("document %d: %w", i, err)
+		}
+	}
+	docs, err := c.embedDocuments(ctx, docs)
+	if err != nil {
+		return nil, err
+	}
+	if opts == nil {
+		return docs, nil
+	}
+	normalize := opts.Normalize && cmp.Or(opts.DistanceMetric, c.config.DefaultDistanceMetric) == DistanceCosine
+	ttl := ttlSeconds(opts.TTL)
+	if !normalize && ttl == 0 {
+		return docs, nil
+	}
+	prepared := make([]Document, len(docs))
+	for i, doc := range docs {
+		if doc.TTLSeconds == 0 {
+			doc.TTLSeconds = ttl
+		}
+		if normalize && len(doc.Vector) > 0 {
+			doc.Vector = doc.Vector.Normalize()
+		}
+		prepared[i] = doc
+	}
+	return prepared, nil
+}
+
+// ttlSeconds converts a batch TTL to whole seconds, rounding up so that a
+// positive TTL never becomes "no expiry".
+func ttlSeconds(ttl time.Duration) int {
+	if ttl <= 0 {
+		return 0
+	}
+	return int((ttl + time.Second - 1) / time.Second)
+}
+
+// embedDocuments fills in vectors for documents that have text but no vector
+// using the configured Embedder, in a single Embed call. Documents that
+// already carry a vector are left alone.
+func (c *Client) embedDocuments(ctx context.Context, docs []Document) ([]Document, error) {
+	if c.config.Embedder == nil {
+		return docs, nil
+	}
+
+	var (
+		texts   []string
+		indexes []int
+	)
+	for i, doc := range docs {
+		if len(doc.Vector) == 0 && strings.TrimSpace(doc.Text) != "" {
+			texts = append(texts, doc.Text)
+			indexes = append(indexes, i)
+		}
+	}
+	if len(texts) == 0 {
+		return docs, nil
+	}
+
+	vectors, err := c.embed(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+
+	embedded := make([]Document, len(docs))
+	copy(embedded, docs)
+	for j, i := range indexes {
+		embedded[i].Vector = vectors[j]
+	}
+	return embedded, nil
+}
+
+// embed calls the configured Embedder and checks it returned one vector per
+// text.
+func (c *Client) embed(ctx context.Context, texts []string) ([]Vector, error) {
+	vectors, err := c.config.Embedder.Embed(ctx, texts)
+	if err != nil {
+		return nil, fmt.Errorf("embed: %w", err)
+	}
+	if len(vectors) != len(texts) {
+		return nil, fmt.Errorf("embed: got %d vectors for %d texts", len(vectors), len(texts))
+	}
+	return vectors, nil
+}
+
+// isHealthy reports whether a health status string means the service is up.
+func isHealthy(status string) bool {
+	switch strings.ToLower(status) {
+	case "ok", "healthy":
+		return true
+	default:
+		return false
+	}
+}
+
+// isUnsupportedEndpoint reports whether err indicates the server does not
+// implement the requested endpoint.
+func isUnsupportedEndpoint(err error) bool {
+	var tideErr *TidepoolError
+	if !errors.As(err, &tideErr) {
+		return false
+	}
+	return tideErr.StatusCode == http.StatusNotFound || tideErr.StatusCode == http.StatusMethodNotAllowed
+}
+
+// isRetryable reports whether err is a transient failure that does not
+// warrant aborting the remaining work.
+func isRetryable(err error) bool {
+	return IsServiceUnavailableError(err) || IsRateLimitError(err)
+}
+
+// chunk splits items into consecutive slices of at most size elements. A
+// non-positive size returns items as a single chunk.
+func chunk[T any](items []T, size int) [][]T {
+	if size <= 0 || len(items) <= size {
+		return [][]T{items}
+	}
+	chunks := make([][]T, 0, (len(items)+size-1)/size)
+	for start := 0; start < len(items); start += size {
+		end := min(start+size, len(items))
+		chunks = append(chunks, items[start:end])
 	}
-	return nil, fmt.Errorf("decode namespaces response: missing namespaces")
+	return chunks
 }
 
 func namesToNamespaceInfo(names []string) []NamespaceInfo {
@@ -0,0 +1,599 @@
+package tidepool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type batchRecorder struct {
+	mu    sync.Mutex
+	sizes []int
+}
+
+func (r *batchRecorder) record(size int) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sizes = append(r.sizes, size)
+	return len(r.sizes)
+}
+
+func (r *batchRecorder) snapshot() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]int(nil), r.sizes...)
+}
+
+// newBatchServer records the number of vectors per upsert (or ids per
+// delete) and fails the request with the given 1-based sequence number (0
+// disables failures).
+func newBatchServer(recorder *batchRecorder, failOn int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var body struct {
+			Vectors []Document `json:"vectors"`
+			IDs     []string   `json:"ids"`
+		}
+		_ = json.NewDecoder(req.Body).Decode(&body)
+		if n := recorder.record(len(body.Vectors) + len(body.IDs)); n == failOn {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			_, _ = w.Write([]byte(`{"error":"too large"}`))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+}
+
+func makeDocs(n int) []Document {
+	docs := make([]Document, n)
+	for i := range docs {
+		docs[i] = Document{ID: string(rune('a' + i%26)), Vector: Vector{float32(i)}}
+	}
+	return docs
+}
+
+func TestUpsertBatching(t *testing.T) {
+	recorder := &batchRecorder{}
+	srv := newBatchServer(recorder, 0)
+	defer srv.Close()
+
+	client := New(WithIngestURL(srv.URL), WithUpsertBatchSize(2))
+	if err := client.Upsert(context.Background(), makeDocs(5), nil); err != nil {
+		t.Fatalf("upsert failed: %v", err)
+	}
+	sizes := recorder.snapshot()
+	if len(sizes) != 3 || sizes[0] != 2 || sizes[1] != 2 || sizes[2] != 1 {
+		t.Fatalf("unexpected batch sizes: %v", sizes)
+	}
+
+	override := &batchRecorder{}
+	srv2 := newBatchServer(override, 0)
+	defer srv2.Close()
+	client = New(WithIngestURL(srv2.URL), WithUpsertBatchSize(2))
+	if err := client.Upsert(context.Background(), makeDocs(5), &UpsertOptions{BatchSize: 10}); err != nil {
+		t.Fatalf("upsert failed: %v", err)
+	}
+	if sizes := override.snapshot(); len(sizes) != 1 || sizes[0] != 5 {
+		t.Fatalf("expected per-call batch size to override, got %v", sizes)
+	}
+}
+
+func TestUpsertBatchError(t *testing.T) {
+	recorder := &batchRecorder{}
+	srv := newBatchServer(recorder, 2)
+	defer srv.Close()
+
+	client := New(WithIngestURL(srv.URL), WithUpsertBatchSize(2))
+	err := client.Upsert(context.Background(), makeDocs(5), nil)
+
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("expected BatchError, got %v", err)
+	}
+	if batchErr.BatchIndex != 1 || batchErr.Committed != 2 {
+		t.Fatalf("unexpected batch error: %+v", batchErr)
+	}
+	if !IsValidationError(err) {
+		t.Fatalf("expected underlying validation error to be preserved")
+	}
+	if len(recorder.snapshot()) != 2 {
+		t.Fatalf("expected upsert to stop after failing batch")
+	}
+}
+
+func TestUpsertWithResult(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch calls {
+		case 1:
+			_, _ = w.Write([]byte(`{"upserted":1,"namespace":"products-v2"}`))
+		case 2:
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			_, _ = w.Write([]byte(`{}`))
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	client := New(WithIngestURL(srv.URL), WithUpsertBatchSize(2))
+	result, err := client.UpsertWithResult(ctx, makeDocs(5), &UpsertOptions{Namespace: "products"})
+	if err != nil {
+		t.Fatalf("upsert failed: %v", err)
+	}
+	if result.Upserted != 4 || result.Namespace != "products-v2" {
+		t.Fatalf("expected the server counts (1+2+1) in products-v2, got %+v", result)
+	}
+
+	calls = 1
+	result, err = client.UpsertWithResult(ctx, makeDocs(1), &UpsertOptions{Namespace: "products"})
+	if err != nil || result.Upserted != 1 || result.Namespace != "products" {
+		t.Fatalf("expected 204 to count the batch, got %+v: %v", result, err)
+	}
+}
+
+func TestUpsertConcurrent(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		inFlight int
+		peak     int
+		total    int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var body struct {
+			Vectors []Document `json:"vectors"`
+		}
+		_ = json.NewDecoder(req.Body).Decode(&body)
+		mu.Lock()
+		inFlight++
+		peak = max(peak, inFlight)
+		total += len(body.Vectors)
+		mu.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	client := New(WithIngestURL(srv.URL), WithUpsertBatchSize(3))
+	if err := client.UpsertConcurrent(context.Background(), makeDocs(20), nil, 2); err != nil {
+		t.Fatalf("concurrent upsert failed: %v", err)
+	}
+	if total != 20 {
+		t.Fatalf("expected 20 documents written, got %d", total)
+	}
+	if peak > 2 {
+		t.Fatalf("expected at most 2 concurrent requests, got %d", peak)
+	}
+}
+
+func TestUpsertProgress(t *testing.T) {
+	recorder := &batchRecorder{}
+	srv := newBatchServer(recorder, 0)
+	defer srv.Close()
+
+	ctx := context.Background()
+	client := New(WithIngestURL(srv.URL), WithUpsertBatchSize(3))
+
+	var (
+		calls      [][2]int
+		concurrent bool
+		inCallback bool
+	)
+	opts := &UpsertOptions{OnProgress: func(done, total int) {
+		if inCallback {
+			concurrent = true
+		}
+		inCallback = true
+		calls = append(calls, [2]int{done, total})
+		time.Sleep(time.Millisecond)
+		inCallback = false
+	}}
+	check := func(name string, total int, want []int) {
+		t.Helper()
+		if concurrent {
+			t.Fatalf("%s: progress callbacks overlapped", name)
+		}
+		if len(calls) != len(want) {
+			t.Fatalf("%s: expected %d progress calls, got %v", name, len(want), calls)
+		}
+		for i, call := range calls {
+			if call[0] != want[i] || call[1] != total {
+				t.Fatalf("%s: expected progress %v of %d, got %v", name, want, total, calls)
+			}
+		}
+		calls = nil
+	}
+
+	if err := client.Upsert(ctx, makeDocs(7), opts); err != nil {
+		t.Fatalf("upsert failed: %v", err)
+	}
+	check("upsert", 7, []int{3, 6, 7})
+
+	if err := client.UpsertConcurrent(ctx, makeDocs(20), opts, 4); err != nil {
+		t.Fatalf("concurrent upsert failed: %v", err)
+	}
+	// Batches finish in any order, but done only grows by batch sizes.
+	last := calls[len(calls)-1]
+	for i := 1; i < len(calls); i++ {
+		if step := calls[i][0] - calls[i-1][0]; step != 3 && step != 2 {
+			t.Fatalf("expected done to grow by one batch at a time, got %v", calls)
+		}
+	}
+	if len(calls) != 7 || last != [2]int{20, 20} || concurrent {
+		t.Fatalf("unexpected concurrent progress %v", calls)
+	}
+	calls = nil
+
+	docs := make(chan Document)
+	go func() {
+		for _, doc := range makeDocs(4) {
+			docs <- doc
+		}
+		close(docs)
+	}()
+	if err := client.UpsertStream(ctx, docs, opts); err != nil {
+		t.Fatalf("stream upsert failed: %v", err)
+	}
+	check("stream", -1, []int{3, 4})
+}
+
+func TestUpsertTTL(t *testing.T) {
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		data, _ := io.ReadAll(req.Body)
+		bodies = append(bodies, string(data))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	client := New(WithIngestURL(srv.URL), WithDefaultNamespace("sessions"))
+
+	if err := client.Upsert(ctx, makeDocs(1), nil); err != nil {
+		t.Fatalf("upsert failed: %v", err)
+	}
+	if strings.Contains(bodies[0], "ttl_seconds") {
+		t.Fatalf("expected ttl_seconds to be omitted, got %s", bodies[0])
+	}
+
+	docs := []Document{
+		{ID: "a", Vector: Vector{1}},
+		{ID: "b", Vector: Vector{2}, TTLSeconds: 60},
+	}
+	if err := client.Upsert(ctx, docs, &UpsertOptions{TTL: 90*time.Minute + time.Millisecond}); err != nil {
+		t.Fatalf("upsert failed: %v", err)
+	}
+	var body struct {
+		Vectors []Document `json:"vectors"`
+	}
+	if err := json.Unmarshal([]byte(bodies[1]), &body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if body.Vectors[0].TTLSeconds != 5401 || body.Vectors[1].TTLSeconds != 60 {
+		t.Fatalf("expected batch TTL rounded up and per-document TTL kept, got %+v", body.Vectors)
+	}
+	if docs[0].TTLSeconds != 0 {
+		t.Fatalf("expected caller documents to be unchanged")
+	}
+
+	if err := client.Upsert(ctx, makeDocs(1), &UpsertOptions{TTL: -time.Second}); !IsValidationError(err) {
+		t.Fatalf("expected validation error for negative TTL, got %v", err)
+	}
+	if err := client.Upsert(ctx, []Document{{ID: "a", Vector: Vector{1}, TTLSeconds: -1}}, nil); !IsValidationError(err) {
+		t.Fatalf("expected validation error for negative TTLSeconds, got %v", err)
+	}
+	if len(bodies) != 2 {
+		t.Fatalf("expected invalid TTLs to send nothing, got %d requests", len(bodies))
+	}
+}
+
+func TestUpsertValidatesDocuments(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	client := New(WithIngestURL(srv.URL))
+	cases := map[string]Document{
+		"missing id":       {Vector: Vector{1}},
+		"nothing to index": {ID: "b"},
+		"non-finite value": {ID: "b", Vector: Vector{1, float32(math.NaN())}},
+	}
+	for name, bad := range cases {
+		docs := []Document{{ID: "a", Vector: Vector{1}}, bad}
+		err := client.Upsert(ctx, docs, nil)
+		if !IsValidationError(err) || !strings.Contains(err.Error(), "document 1:") {
+			t.Fatalf("%s: expected validation error naming document 1, got %v", name, err)
+		}
+	}
+	if calls.Load() != 0 {
+		t.Fatalf("expected invalid documents to send nothing, got %d requests", calls.Load())
+	}
+
+	valid := []Document{
+		{ID: "text", Text: "only text"},
+		{ID: "sparse", SparseVector: &SparseVector{Indices: []uint32{1}, Values: []float32{0.5}}},
+	}
+	if err := client.Upsert(ctx, valid, nil); err != nil {
+		t.Fatalf("expected text-only and sparse-only documents to upsert, got %v", err)
+	}
+}
+
+func TestSkipVectorValidation(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	client := New(WithIngestURL(srv.URL), WithQueryURL(srv.URL), WithSkipVectorValidation())
+	nan := Vector{1, float32(math.NaN())}
+	err := client.Upsert(ctx, []Document{{ID: "a", Vector: nan}}, nil)
+	var unsupported *json.UnsupportedValueError
+	if IsValidationError(err) || !errors.As(err, &unsupported) {
+		t.Fatalf("expected NaN to fail JSON encoding instead of validation, got %v", err)
+	}
+	if _, err := client.Query(ctx, nan, nil); IsValidationError(err) || err == nil {
+		t.Fatalf("expected NaN query to skip validation and fail encoding, got %v", err)
+	}
+	if calls.Load() != 0 {
+		t.Fatalf("expected nothing to be sent, got %d requests", calls.Load())
+	}
+
+	if err := client.Upsert(ctx, []Document{{ID: "a", Vector: Vector{}}}, nil); !IsValidationError(err) {
+		t.Fatalf("expected empty vector to still fail validation, got %v", err)
+	}
+	if _, err := client.Query(ctx, Vector{}, nil); !IsValidationError(err) {
+		t.Fatalf("expected empty query vector to still fail validation, got %v", err)
+	}
+}
+
+func BenchmarkPrepareDocuments(b *testing.B) {
+	docs := make([]Document, 1000)
+	for i := range docs {
+		v := make(Vector, 1536)
+		for j := range v {
+			v[j] = float32(i+j) / 1536
+		}
+		docs[i] = Document{ID: fmt.Sprintf("doc-%d", i), Vector: v}
+	}
+	ctx := context.Background()
+	for _, bc := range []struct {
+		name string
+		opts []Option
+	}{
+		{"validate", nil},
+		{"skip", []Option{WithSkipVectorValidation()}},
+	} {
+		client := New(bc.opts...)
+		b.Run(bc.name, func(b *testing.B) {
+			for b.Loop() {
+				if _, err := client.prepareDocuments(ctx, docs, nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestUpsertConcurrentStopsOnError(t *testing.T) {
+	recorder := &batchRecorder{}
+	srv := newBatchServer(recorder, 1)
+	defer srv.Close()
+
+	client := New(WithIngestURL(srv.URL))
+	err := client.UpsertConcurrent(context.Background(), makeDocs(10), &UpsertOptions{BatchSize: 1}, 1)
+
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || batchErr.BatchIndex != 0 {
+		t.Fatalf("expected BatchError for first batch, got %v", err)
+	}
+	if !IsValidationError(err) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+	if n := len(recorder.snapshot()); n >= 10 {
+		t.Fatalf("expected scheduling to stop after failure, got %d requests", n)
+	}
+}
+
+func TestUpsertConcurrentReportsSucceededBatches(t *testing.T) {
+	recorder := &batchRecorder{}
+	srv := newBatchServer(recorder, 3)
+	defer srv.Close()
+
+	client := New(WithIngestURL(srv.URL))
+	err := client.UpsertConcurrent(context.Background(), makeDocs(10), &UpsertOptions{BatchSize: 2}, 1)
+
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || batchErr.BatchIndex != 2 {
+		t.Fatalf("expected BatchError for the third batch, got %v", err)
+	}
+	if !slices.Equal(batchErr.Succeeded, []int{0, 1}) {
+		t.Fatalf("expected batches 0 and 1 to be reported as written, got %v", batchErr.Succeeded)
+	}
+}
+
+func TestUpsertConcurrentValidation(t *testing.T) {
+	client := New()
+	if err := client.UpsertConcurrent(context.Background(), nil, nil, 1); !IsValidationError(err) {
+		t.Fatalf("expected validation error for empty docs, got %v", err)
+	}
+	if err := client.UpsertConcurrent(context.Background(), makeDocs(1), nil, 0); !IsValidationError(err) {
+		t.Fatalf("expected validation error for zero concurrency, got %v", err)
+	}
+}
+
+func TestUpsertStream(t *testing.T) {
+	recorder := &batchRecorder{}
+	srv := newBatchServer(recorder, 0)
+	defer srv.Close()
+
+	client := New(WithIngestURL(srv.URL), WithUpsertBatchSize(4))
+	docs := make(chan Document)
+	go func() {
+		defer close(docs)
+		for _, doc := range makeDocs(10) {
+			docs <- doc
+		}
+	}()
+
+	if err := client.UpsertStream(context.Background(), docs, nil); err != nil {
+		t.Fatalf("upsert stream failed: %v", err)
+	}
+	sizes := recorder.snapshot()
+	if len(sizes) != 3 || sizes[0] != 4 || sizes[1] != 4 || sizes[2] != 2 {
+		t.Fatalf("unexpected batch sizes: %v", sizes)
+	}
+}
+
+func TestUpsertStreamErrors(t *testing.T) {
+	recorder := &batchRecorder{}
+	srv := newBatchServer(recorder, 2)
+	defer srv.Close()
+
+	client := New(WithIngestURL(srv.URL))
+	docs := make(chan Document, 10)
+	for _, doc := range makeDocs(10) {
+		docs <- doc
+	}
+	close(docs)
+
+	err := client.UpsertStream(context.Background(), docs, &UpsertOptions{BatchSize: 3})
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || batchErr.BatchIndex != 1 || batchErr.Committed != 3 {
+		t.Fatalf("expected failure on second batch after 3 committed, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pending := make(chan Document)
+	go func() {
+		pending <- Document{ID: "a", Vector: Vector{0.1}}
+		cancel()
+	}()
+	err = client.UpsertStream(ctx, pending, &UpsertOptions{BatchSize: 3})
+	if !errors.As(err, &batchErr) || !errors.Is(err, context.Canceled) || batchErr.Committed != 0 {
+		t.Fatalf("expected cancellation error with 0 committed, got %v", err)
+	}
+}
+
+func makeIDs(n int) []string {
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("id-%d", i)
+	}
+	return ids
+}
+
+func TestDeleteBatching(t *testing.T) {
+	recorder := &batchRecorder{}
+	srv := newBatchServer(recorder, 0)
+	defer srv.Close()
+
+	client := New(WithIngestURL(srv.URL), WithUpsertBatchSize(2))
+	if err := client.Delete(context.Background(), makeIDs(5), nil); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	sizes := recorder.snapshot()
+	if len(sizes) != 3 || sizes[0] != 2 || sizes[1] != 2 || sizes[2] != 1 {
+		t.Fatalf("unexpected batch sizes: %v", sizes)
+	}
+}
+
+func TestDeleteBatchFailure(t *testing.T) {
+	recorder := &batchRecorder{}
+	srv := newBatchServer(recorder, 2)
+	defer srv.Close()
+
+	client := New(WithIngestURL(srv.URL))
+	err := client.Delete(context.Background(), makeIDs(5), &DeleteOptions{BatchSize: 2})
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || batchErr.BatchIndex != 1 || batchErr.Committed != 2 {
+		t.Fatalf("expected batch error after 2 ids, got %v", err)
+	}
+	if len(recorder.snapshot()) != 2 {
+		t.Fatalf("expected delete to stop after the failed batch, got %v", recorder.snapshot())
+	}
+}
+
+func TestDeleteContinueOnError(t *testing.T) {
+	recorder := &batchRecorder{}
+	srv := newBatchServer(recorder, 1)
+	defer srv.Close()
+
+	client := New(WithIngestURL(srv.URL))
+	err := client.Delete(context.Background(), makeIDs(5), &DeleteOptions{BatchSize: 2, ContinueOnError: true})
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || batchErr.BatchIndex != 0 || !IsValidationError(err) {
+		t.Fatalf("expected joined batch error for the first batch, got %v", err)
+	}
+	if sizes := recorder.snapshot(); len(sizes) != 3 {
+		t.Fatalf("expected every batch to be attempted, got %v", sizes)
+	}
+}
+
+func TestUpsertAndWait(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		fetches int
+	)
+	// "a" is visible on the first fetch, "b" from the third, "never" never.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		mu.Lock()
+		fetches++
+		n := fetches
+		mu.Unlock()
+		var results []VectorResult
+		for _, id := range r.URL.Query()["ids"] {
+			if id == "a" || (id == "b" && n >= 3) {
+				results = append(results, VectorResult{ID: id})
+			}
+		}
+		_ = json.NewEncoder(w).Encode(results)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	client := New(WithQueryURL(srv.URL), WithIngestURL(srv.URL), WithPollInterval(time.Millisecond))
+
+	docs := []Document{{ID: "a", Vector: Vector{1}}, {ID: "b", Vector: Vector{1}}}
+	if err := client.UpsertAndWait(ctx, docs, nil, 5*time.Second); err != nil {
+		t.Fatalf("upsert and wait failed: %v", err)
+	}
+	if fetches != 3 {
+		t.Fatalf("expected 3 fetches, got %d", fetches)
+	}
+
+	docs = append(docs, Document{ID: "never", Vector: Vector{1}})
+	err := client.UpsertAndWait(ctx, docs, nil, 20*time.Millisecond)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if msg := err.Error(); !strings.Contains(msg, "documents not visible: never") {
+		t.Fatalf("expected error naming the invisible id, got %v", err)
+	}
+}
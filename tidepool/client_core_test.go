@@ -1,12 +1,23 @@
 package tidepool
 
 import (
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"math"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -48,6 +59,138 @@ func TestNewDefaultsAndOptions(t *testing.T) {
 	if customHTTP2.Timeout != 12*time.Second {
 		t.Fatalf("expected timeout to be overridden, got %s", customHTTP2.Timeout)
 	}
+
+	contextOnly := New(WithTimeout(12*time.Second), WithContextTimeoutOnly())
+	if contextOnly.http.Timeout != 0 {
+		t.Fatalf("expected no client timeout, got %s", contextOnly.http.Timeout)
+	}
+	customHTTP3 := &http.Client{Timeout: 5 * time.Second}
+	_ = New(WithHTTPClient(customHTTP3), WithContextTimeoutOnly())
+	if customHTTP3.Timeout != 5*time.Second {
+		t.Fatalf("expected custom client timeout to be left alone, got %s", customHTTP3.Timeout)
+	}
+
+	if client.http.Transport != nil {
+		t.Fatalf("expected default transport when no transport options are set")
+	}
+	pooled := New(WithTransport(TransportOptions{MaxIdleConnsPerHost: 64, MaxConnsPerHost: 128, IdleConnTimeout: time.Minute}))
+	transport, ok := pooled.http.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("expected *http.Transport, got %T", pooled.http.Transport)
+	}
+	if transport.MaxIdleConnsPerHost != 64 || transport.MaxConnsPerHost != 128 || transport.IdleConnTimeout != time.Minute {
+		t.Fatalf("unexpected transport settings: %+v", transport)
+	}
+	if transport.MaxIdleConns != http.DefaultTransport.(*http.Transport).MaxIdleConns {
+		t.Fatalf("expected unset fields to keep defaults, got MaxIdleConns %d", transport.MaxIdleConns)
+	}
+	customHTTP4 := &http.Client{}
+	_ = New(WithHTTPClient(customHTTP4), WithTransport(TransportOptions{MaxConnsPerHost: 1}))
+	if customHTTP4.Transport != nil {
+		t.Fatalf("expected WithTransport to leave a custom client alone")
+	}
+}
+
+func TestClientConfig(t *testing.T) {
+	client := New(
+		WithBaseURL("http://tidepool.internal"),
+		WithIngestURL("http://ingest.internal"),
+		WithNamespace("tenant-a"),
+		WithDefaultNamespace("tenant-b"),
+		WithTimeout(5*time.Second),
+		WithCircuitBreaker(CircuitBreakerSettings{FailureThreshold: 3}),
+		WithAttributeSchema("tenant-b", map[string]AttrKind{"tier": AttrString}),
+	)
+
+	cfg := client.Config()
+	if cfg.QueryURL != "http://tidepool.internal" || cfg.IngestURL != "http://ingest.internal" {
+		t.Fatalf("expected resolved urls, got %q and %q", cfg.QueryURL, cfg.IngestURL)
+	}
+	if cfg.DefaultNamespace != "tenant-b" || cfg.Namespace != "tenant-b" {
+		t.Fatalf("expected reconciled namespace tenant-b, got %q and %q", cfg.DefaultNamespace, cfg.Namespace)
+	}
+	if cfg.Timeout != 5*time.Second || cfg.PollInterval != defaultPollInterval || cfg.MaxResponseBytes != defaultMaxResponseBytes {
+		t.Fatalf("expected resolved defaults, got %+v", cfg)
+	}
+
+	cfg.QueryURL = "http://elsewhere"
+	cfg.CircuitBreaker.FailureThreshold = 100
+	cfg.AttributeSchemas["tenant-b"]["tier"] = AttrNumber
+	again := client.Config()
+	if again.QueryURL != "http://tidepool.internal" || again.CircuitBreaker.FailureThreshold != 3 || again.AttributeSchemas["tenant-b"]["tier"] != AttrString {
+		t.Fatalf("expected Config to return a copy, got %+v", again)
+	}
+}
+
+func TestBaseURLRoutesBothServices(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		paths []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+		switch {
+		case r.URL.Path == "/health":
+			_ = json.NewEncoder(w).Encode(HealthResponse{Status: "ok"})
+		case strings.HasSuffix(r.URL.Path, "/status"), r.URL.Path == "/v1/namespaces/default":
+			_, _ = w.Write([]byte(`{}`))
+		case r.URL.Path == "/v1/namespaces":
+			_, _ = w.Write([]byte(`{"namespaces":[]}`))
+		default:
+			_ = json.NewEncoder(w).Encode([]VectorResult{{ID: "a"}})
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	client := New(WithBaseURL(srv.URL))
+	if client.config.QueryURL != srv.URL || client.config.IngestURL != srv.URL {
+		t.Fatalf("expected both services at %s, got %q and %q", srv.URL, client.config.QueryURL, client.config.IngestURL)
+	}
+	if err := client.Ping(ctx); err != nil {
+		t.Fatalf("ping failed: %v", err)
+	}
+	if err := client.Upsert(ctx, []Document{{ID: "a", Vector: Vector{1}}}, nil); err != nil {
+		t.Fatalf("upsert failed: %v", err)
+	}
+	if _, err := client.Query(ctx, Vector{1}, nil); err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if _, err := client.Status(ctx); err != nil {
+		t.Fatalf("status failed: %v", err)
+	}
+	if _, err := client.DescribeNamespace(ctx, ""); err != nil {
+		t.Fatalf("describe namespace failed: %v", err)
+	}
+	if err := client.Compact(ctx); err != nil {
+		t.Fatalf("compact failed: %v", err)
+	}
+	if _, err := client.ListNamespaces(ctx); err != nil {
+		t.Fatalf("list namespaces failed: %v", err)
+	}
+
+	sort.Strings(paths)
+	want := []string{
+		"GET /health",
+		"GET /health",
+		"GET /status",
+		"GET /v1/namespaces",
+		"GET /v1/namespaces/default",
+		"GET /v1/namespaces/default/status",
+		"POST /v1/namespaces/default/compact",
+		"POST /v1/vectors/default",
+		"POST /v1/vectors/default",
+	}
+	if !reflect.DeepEqual(paths, want) {
+		t.Fatalf("expected requests %v, got %v", want, paths)
+	}
+
+	client = New(WithQueryURL("http://query.example"), WithBaseURL(srv.URL))
+	if client.config.QueryURL != "http://query.example" || client.config.IngestURL != srv.URL {
+		t.Fatalf("expected specific query URL to win, got %q and %q", client.config.QueryURL, client.config.IngestURL)
+	}
 }
 
 func TestNamespaceOrDefaultErrorsWhenMissing(t *testing.T) {
@@ -58,6 +201,35 @@ func TestNamespaceOrDefaultErrorsWhenMissing(t *testing.T) {
 	}
 }
 
+func TestNamespaceValidation(t *testing.T) {
+	client := New()
+	for _, ns := range []string{"a/b", "../x", "..", "has space", "tab\t", "back\\slash", "nul\x00"} {
+		if _, err := client.namespaceOrDefault(ns); !IsValidationError(err) {
+			t.Fatalf("expected validation error for %q, got %v", ns, err)
+		}
+	}
+	for _, ns := range []string{"tenant_a", "tenant-a.v2", "Ünïcode"} {
+		if _, err := client.namespaceOrDefault(ns); err != nil {
+			t.Fatalf("expected %q to be accepted, got %v", ns, err)
+		}
+	}
+
+	strict := New(WithNamespacePattern(regexp.MustCompile(`^[a-z0-9_]+$`)))
+	if _, err := strict.namespaceOrDefault("Tenant-A"); !IsValidationError(err) {
+		t.Fatalf("expected pattern mismatch to be rejected, got %v", err)
+	}
+	if _, err := strict.namespaceOrDefault("tenant_a"); err != nil {
+		t.Fatalf("expected pattern match to be accepted, got %v", err)
+	}
+
+	if err := client.Upsert(context.Background(), []Document{{ID: "a", Vector: Vector{1}}}, &UpsertOptions{Namespace: "a/../b"}); !IsValidationError(err) {
+		t.Fatalf("expected upsert to reject namespace before sending, got %v", err)
+	}
+	if err := client.DeleteNamespace(context.Background(), "a b"); !IsValidationError(err) {
+		t.Fatalf("expected delete namespace to reject name, got %v", err)
+	}
+}
+
 func TestValidateVector(t *testing.T) {
 	if err := ValidateVector(Vector{}, 0); err == nil {
 		t.Fatalf("expected error for empty vector")
@@ -100,7 +272,7 @@ func TestVectorResultUnmarshalJSON(t *testing.T) {
 
 func TestDecodeQueryResponse(t *testing.T) {
 	direct := `[{"id":"a","score":0.1}]`
-	resp, err := decodeQueryResponse([]byte(direct), "fallback")
+	resp, err := decodeQueryResponse([]byte(direct), "fallback", false)
 	if err != nil {
 		t.Fatalf("direct decode failed: %v", err)
 	}
@@ -109,7 +281,7 @@ func TestDecodeQueryResponse(t *testing.T) {
 	}
 
 	wrapped := `{"namespace":"ns","results":[{"id":"b","score":0.2}]}`
-	resp, err = decodeQueryResponse([]byte(wrapped), "fallback")
+	resp, err = decodeQueryResponse([]byte(wrapped), "fallback", false)
 	if err != nil {
 		t.Fatalf("wrapped decode failed: %v", err)
 	}
@@ -118,7 +290,7 @@ func TestDecodeQueryResponse(t *testing.T) {
 	}
 
 	vectors := `{"vectors":[{"id":"c","score":0.3}]}`
-	resp, err = decodeQueryResponse([]byte(vectors), "fallback")
+	resp, err = decodeQueryResponse([]byte(vectors), "fallback", false)
 	if err != nil {
 		t.Fatalf("vectors decode failed: %v", err)
 	}
@@ -126,65 +298,151 @@ func TestDecodeQueryResponse(t *testing.T) {
 		t.Fatalf("unexpected vectors response: %+v", resp)
 	}
 
+	paged := `{"results":[{"id":"d","score":0.4}],"next_cursor":"page-2"}`
+	resp, err = decodeQueryResponse([]byte(paged), "fallback", false)
+	if err != nil {
+		t.Fatalf("paged decode failed: %v", err)
+	}
+	if resp.NextCursor != "page-2" {
+		t.Fatalf("expected next cursor page-2, got %q", resp.NextCursor)
+	}
+
+	timed := `{"results":[],"took_ms":12.3}`
+	resp, err = decodeQueryResponse([]byte(timed), "fallback", false)
+	if err != nil || resp.TookMS != 12.3 {
+		t.Fatalf("expected took_ms 12.3, got %+v: %v", resp, err)
+	}
+
+	for _, empty := range []string{`[]`, `{"results":[]}`, `{"namespace":"x","results":[]}`, `{"namespace":"x","results":null}`, `{"vectors":[]}`} {
+		resp, err := decodeQueryResponse([]byte(empty), "fallback", false)
+		if err != nil {
+			t.Fatalf("%s: expected a valid empty response, got %v", empty, err)
+		}
+		if !resp.IsEmpty() || resp.Results == nil || resp.NextCursor != "" {
+			t.Fatalf("%s: unexpected empty response %+v", empty, resp)
+		}
+		if strings.Contains(empty, `"x"`) != (resp.Namespace == "x") {
+			t.Fatalf("%s: unexpected namespace %q", empty, resp.Namespace)
+		}
+	}
+	if resp, _ := decodeQueryResponse([]byte(direct), "fallback", false); resp.IsEmpty() {
+		t.Fatalf("expected a response with results not to be empty")
+	}
+	if !(*QueryResponse)(nil).IsEmpty() {
+		t.Fatalf("expected a nil response to be empty")
+	}
+
 	invalid := `{"namespace":"ns"}`
-	if _, err := decodeQueryResponse([]byte(invalid), "fallback"); err == nil {
-		t.Fatalf("expected error for missing results")
+	if _, err := decodeQueryResponse([]byte(invalid), "fallback", false); err == nil || !strings.Contains(err.Error(), "(body: ") {
+		t.Fatalf("expected error for missing results with body snippet, got %v", err)
+	}
+
+	html := "<html><body>502 Bad Gateway</body></html>" + strings.Repeat(" padding", 100)
+	_, err = decodeQueryResponse([]byte(html), "fallback", false)
+	if err == nil || !strings.Contains(err.Error(), "502 Bad Gateway") || strings.Contains(err.Error(), strings.Repeat(" padding", 30)) {
+		t.Fatalf("expected truncated HTML snippet in error, got %v", err)
 	}
 }
 
 func TestDecodeNamespaces(t *testing.T) {
 	wrapped := `{"namespaces":[{"namespace":"a"},{"namespace":"b"}]}`
-	infos, err := decodeNamespaces([]byte(wrapped))
+	infos, _, err := decodeNamespaces([]byte(wrapped))
 	if err != nil || len(infos) != 2 {
 		t.Fatalf("wrapped decode failed: %v", err)
 	}
 
 	direct := `[{"namespace":"c"}]`
-	infos, err = decodeNamespaces([]byte(direct))
+	infos, _, err = decodeNamespaces([]byte(direct))
 	if err != nil || len(infos) != 1 || infos[0].Namespace != "c" {
 		t.Fatalf("direct decode failed: %v", err)
 	}
 
 	legacy := `["d","e"]`
-	infos, err = decodeNamespaces([]byte(legacy))
+	infos, _, err = decodeNamespaces([]byte(legacy))
 	if err != nil || len(infos) != 2 || infos[1].Namespace != "e" {
 		t.Fatalf("legacy decode failed: %v", err)
 	}
 
 	legacyWrapped := `{"namespace_list":["f","g"]}`
-	infos, err = decodeNamespaces([]byte(legacyWrapped))
+	infos, _, err = decodeNamespaces([]byte(legacyWrapped))
 	if err != nil || len(infos) != 2 || infos[0].Namespace != "f" {
 		t.Fatalf("legacy wrapped decode failed: %v", err)
 	}
 
 	invalid := `{"namespaces":null}`
-	if _, err := decodeNamespaces([]byte(invalid)); err == nil {
-		t.Fatalf("expected error for missing namespaces")
+	if _, _, err := decodeNamespaces([]byte(invalid)); err == nil || !strings.Contains(err.Error(), "(body: ") {
+		t.Fatalf("expected error for missing namespaces with body snippet, got %v", err)
 	}
 }
 
 func TestHandleErrorMapping(t *testing.T) {
 	client := New()
 
-	validation := client.handleError(http.StatusBadRequest, []byte(`{"error":"bad"}`))
+	validation := client.handleError(http.StatusBadRequest, nil, []byte(`{"error":"bad"}`))
 	if !IsValidationError(validation) {
 		t.Fatalf("expected validation error, got %v", validation)
 	}
 
-	notFound := client.handleError(http.StatusNotFound, []byte(`{"error":"missing"}`))
+	notFound := client.handleError(http.StatusNotFound, nil, []byte(`{"error":"missing"}`))
 	if !IsNotFoundError(notFound) {
 		t.Fatalf("expected not found error, got %v", notFound)
 	}
 
-	unavailable := client.handleError(http.StatusServiceUnavailable, []byte(`{"error":"down"}`))
+	unavailable := client.handleError(http.StatusServiceUnavailable, nil, []byte(`{"error":"down"}`))
 	if !IsServiceUnavailableError(unavailable) {
 		t.Fatalf("expected service unavailable error, got %v", unavailable)
 	}
 
-	generic := client.handleError(http.StatusInternalServerError, []byte(`{"error":"boom"}`))
+	generic := client.handleError(http.StatusInternalServerError, nil, []byte(`{"error":"boom"}`))
 	if !strings.Contains(generic.Error(), "boom") {
 		t.Fatalf("expected error message to include boom")
 	}
+
+	if !IsServerError(generic) {
+		t.Fatalf("expected server error, got %v", generic)
+	}
+	if IsServerError(unavailable) {
+		t.Fatalf("expected 503 not to be classified as a server error")
+	}
+
+	header := http.Header{}
+	header.Set("Retry-After", "3")
+	limited := client.handleError(http.StatusTooManyRequests, header, []byte(`{"error":"slow down"}`))
+	if !IsRateLimitError(limited) {
+		t.Fatalf("expected rate limit error, got %v", limited)
+	}
+	var limitedErr *TidepoolError
+	if !errors.As(limited, &limitedErr) || limitedErr.RetryAfter != 3*time.Second {
+		t.Fatalf("expected retry after 3s, got %+v", limitedErr)
+	}
+
+	detailed := client.handleError(http.StatusBadRequest, nil, []byte(`{"error":"bad dims","code":"DIM_MISMATCH","details":{"expected":768,"got":512}}`))
+	if !IsValidationError(detailed) {
+		t.Fatalf("expected validation error, got %v", detailed)
+	}
+	var tideErr *TidepoolError
+	if !errors.As(detailed, &tideErr) {
+		t.Fatalf("expected TidepoolError in chain")
+	}
+	if tideErr.Code != "DIM_MISMATCH" || tideErr.Details["expected"] != float64(768) || tideErr.Details["got"] != float64(512) {
+		t.Fatalf("unexpected error details: %+v", tideErr)
+	}
+	if expected, actual, ok := IsDimensionMismatch(detailed); !ok || expected != 768 || actual != 512 {
+		t.Fatalf("expected dimension mismatch 768 vs 512, got %d, %d, %v", expected, actual, ok)
+	}
+	if _, _, ok := IsDimensionMismatch(validation); ok {
+		t.Fatalf("expected plain validation error not to be a dimension mismatch")
+	}
+
+	alt := client.handleError(http.StatusBadRequest, nil, []byte(`{"error":"bad dims","code":"dimension_mismatch","details":{"expected":3,"actual":4}}`))
+	if expected, actual, ok := IsDimensionMismatch(alt); !ok || expected != 3 || actual != 4 {
+		t.Fatalf("expected dimension mismatch 3 vs 4, got %d, %d, %v", expected, actual, ok)
+	}
+
+	local := ValidateVector(Vector{1, 2}, 3)
+	if expected, actual, ok := IsDimensionMismatch(local); !ok || expected != 3 || actual != 2 || !IsValidationError(local) {
+		t.Fatalf("expected local dimension mismatch 3 vs 2, got %v", local)
+	}
 }
 
 func TestDoRequestHeaders(t *testing.T) {
@@ -201,7 +459,7 @@ func TestDoRequestHeaders(t *testing.T) {
 		defer srv.Close()
 
 		client := New(WithHTTPClient(srv.Client()))
-		if _, err := client.doRequest(context.Background(), http.MethodGet, srv.URL, nil); err != nil {
+		if _, err := client.doRequest(context.Background(), "query", http.MethodGet, srv.URL, nil); err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
 	})
@@ -219,12 +477,46 @@ func TestDoRequestHeaders(t *testing.T) {
 		defer srv.Close()
 
 		client := New(WithHTTPClient(srv.Client()))
-		if _, err := client.doRequest(context.Background(), http.MethodPost, srv.URL, map[string]any{"a": 1}); err != nil {
+		if _, err := client.doRequest(context.Background(), "query", http.MethodPost, srv.URL, map[string]any{"a": 1}); err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
 	})
 }
 
+func TestRequestHeadersFromContext(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	ctx := WithRequestHeaders(context.Background(), http.Header{
+		"x-tenant-id":   {"t1"},
+		"Accept":        {"text/html"},
+		"Authorization": {"Bearer ctx"},
+	})
+	ctx = WithRequestHeaders(ctx, http.Header{"Traceparent": {"00-abc-def-01"}, "X-Tenant-Id": {"t2"}})
+
+	client := New(WithAPIKey("secret"))
+	if _, err := client.doRequest(ctx, "query", http.MethodPost, srv.URL, map[string]any{"a": 1}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Get("X-Tenant-Id") != "t2" || got.Get("Traceparent") != "00-abc-def-01" {
+		t.Fatalf("expected context headers to be sent, got %v", got)
+	}
+	if got.Get("Accept") != "application/json" || got.Get("Authorization") != "Bearer secret" {
+		t.Fatalf("expected client headers to take precedence, got %v", got)
+	}
+
+	if _, err := client.doRequest(context.Background(), "query", http.MethodGet, srv.URL, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Get("X-Tenant-Id") != "" {
+		t.Fatalf("expected no tenant header without context headers, got %v", got)
+	}
+}
+
 func TestQueryValidation(t *testing.T) {
 	client := New(WithDefaultNamespace("default"))
 	ctx := context.Background()
@@ -284,7 +576,7 @@ func TestQueryRequestPayload(t *testing.T) {
 	_, err := client.Query(context.Background(), Vector{0.1, 0.2}, &QueryOptions{
 		Text:           "hello",
 		TopK:           7,
-		IncludeVectors: false,
+		IncludeVectors: Bool(false),
 		DistanceMetric: DistanceCosine,
 		Filters:        Attributes{"tag": "a"},
 		Alpha:          &alpha,
@@ -343,6 +635,9 @@ func TestTextOnlyQueryPayload(t *testing.T) {
 	if captured["mode"] != "text" {
 		t.Fatalf("expected mode text, got %v", captured["mode"])
 	}
+	if _, ok := captured["include_vectors"]; ok {
+		t.Fatalf("expected include_vectors omitted when unset")
+	}
 }
 
 func TestUpsertDeleteValidation(t *testing.T) {
@@ -354,3 +649,1388 @@ func TestUpsertDeleteValidation(t *testing.T) {
 		t.Fatalf("expected error for empty delete")
 	}
 }
+
+func TestDistanceMetricValidation(t *testing.T) {
+	for _, m := range []DistanceMetric{DistanceCosine, DistanceEuclidean, DistanceDotProduct} {
+		if !m.Valid() {
+			t.Fatalf("expected %q to be valid", m)
+		}
+	}
+	if DistanceMetric("cosine").Valid() || DistanceMetric("").Valid() {
+		t.Fatalf("expected unknown and empty metrics to be invalid")
+	}
+
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_ = json.NewEncoder(w).Encode([]VectorResult{{ID: "a"}})
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	client := New(WithQueryURL(srv.URL), WithIngestURL(srv.URL))
+	docs := []Document{{ID: "a", Vector: Vector{1}}}
+	typo := DistanceMetric("cosine")
+
+	err := client.Upsert(ctx, docs, &UpsertOptions{DistanceMetric: typo})
+	if !IsValidationError(err) || !strings.Contains(err.Error(), `"cosine"`) || !strings.Contains(err.Error(), string(DistanceDotProduct)) {
+		t.Fatalf("expected validation error naming the metric and accepted values, got %v", err)
+	}
+	if _, err := client.Query(ctx, Vector{1}, &QueryOptions{DistanceMetric: typo}); !IsValidationError(err) {
+		t.Fatalf("expected query validation error, got %v", err)
+	}
+	if err := client.CreateNamespace(ctx, "ns", &CreateNamespaceOptions{DistanceMetric: typo}); !IsValidationError(err) {
+		t.Fatalf("expected create namespace validation error, got %v", err)
+	}
+	if requests != 0 {
+		t.Fatalf("expected no requests for invalid metrics, got %d", requests)
+	}
+
+	if err := client.Upsert(ctx, docs, nil); err != nil {
+		t.Fatalf("expected omitted metric to be accepted, got %v", err)
+	}
+	if _, err := client.Query(ctx, Vector{1}, &QueryOptions{DistanceMetric: DistanceDotProduct}); err != nil {
+		t.Fatalf("expected known metric to be accepted, got %v", err)
+	}
+}
+
+func TestDoRequestAuthHeaders(t *testing.T) {
+	t.Run("api key", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer secret" {
+				t.Fatalf("expected bearer authorization, got %q", r.Header.Get("Authorization"))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer srv.Close()
+
+		client := New(WithAPIKey("secret"))
+		if _, err := client.doRequest(context.Background(), "query", http.MethodGet, srv.URL, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("custom header", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-Tidepool-Token") != "secret" {
+				t.Fatalf("expected custom auth header, got %q", r.Header.Get("X-Tidepool-Token"))
+			}
+			if r.Header.Get("Authorization") != "" {
+				t.Fatalf("expected Authorization header to be empty")
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer srv.Close()
+
+		client := New(WithAuthHeader("X-Tidepool-Token", "secret"))
+		if _, err := client.doRequest(context.Background(), "query", http.MethodGet, srv.URL, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("token provider", func(t *testing.T) {
+		calls := 0
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != fmt.Sprintf("Bearer token-%d", calls) {
+				t.Fatalf("unexpected authorization %q", r.Header.Get("Authorization"))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer srv.Close()
+
+		client := New(WithTokenProvider(func(ctx context.Context) (string, error) {
+			calls++
+			return fmt.Sprintf("token-%d", calls), nil
+		}))
+		for i := 0; i < 2; i++ {
+			if _, err := client.doRequest(context.Background(), "query", http.MethodGet, srv.URL, nil); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		if calls != 2 {
+			t.Fatalf("expected token provider to be called per request, got %d", calls)
+		}
+	})
+
+	t.Run("token provider error", func(t *testing.T) {
+		client := New(WithTokenProvider(func(ctx context.Context) (string, error) {
+			return "", errors.New("expired")
+		}))
+		if _, err := client.doRequest(context.Background(), "query", http.MethodGet, "http://127.0.0.1:0", nil); err == nil || !strings.Contains(err.Error(), "expired") {
+			t.Fatalf("expected token provider error, got %v", err)
+		}
+	})
+}
+
+func TestQueryCursorPagination(t *testing.T) {
+	var cursors []any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var captured map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&captured); err != nil {
+			t.Fatalf("decode body: %v", err)
+		}
+		cursors = append(cursors, captured["cursor"])
+		if captured["cursor"] == nil {
+			_ = json.NewEncoder(w).Encode(QueryResponse{Results: []VectorResult{{ID: "a"}}, NextCursor: "next"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(QueryResponse{Results: []VectorResult{{ID: "b"}}})
+	}))
+	defer srv.Close()
+
+	client := New(WithQueryURL(srv.URL))
+	opts := &QueryOptions{TopK: 1}
+	var ids []string
+	for {
+		resp, err := client.Query(context.Background(), Vector{0.1}, opts)
+		if err != nil {
+			t.Fatalf("query failed: %v", err)
+		}
+		for _, r := range resp.Results {
+			ids = append(ids, r.ID)
+		}
+		if resp.NextCursor == "" {
+			break
+		}
+		opts.Cursor = resp.NextCursor
+	}
+
+	if len(ids) != 2 || ids[0] != "a" || ids[1] != "b" {
+		t.Fatalf("unexpected paged ids: %v", ids)
+	}
+	if len(cursors) != 2 || cursors[0] != nil || cursors[1] != "next" {
+		t.Fatalf("unexpected cursors sent: %v", cursors)
+	}
+}
+
+func TestMultiQuery(t *testing.T) {
+	var captured struct {
+		Queries []map[string]any `json:"queries"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/vectors/default/batch" {
+			t.Fatalf("unexpected path %q", r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&captured); err != nil {
+			t.Fatalf("decode body: %v", err)
+		}
+		_, _ = w.Write([]byte(`{"results":[[{"id":"a","score":0.1}],{"namespace":"default","results":[{"id":"b","score":0.2}]}]}`))
+	}))
+	defer srv.Close()
+
+	client := New(WithQueryURL(srv.URL))
+	responses, err := client.MultiQuery(context.Background(), []Vector{{0.1}, {0.2}}, &QueryOptions{TopK: 3})
+	if err != nil {
+		t.Fatalf("multi query failed: %v", err)
+	}
+	if len(captured.Queries) != 2 || captured.Queries[1]["top_k"] != float64(3) {
+		t.Fatalf("unexpected batch payload: %+v", captured.Queries)
+	}
+	if len(responses) != 2 || responses[0].Results[0].ID != "a" || responses[1].Results[0].ID != "b" {
+		t.Fatalf("unexpected responses: %+v", responses)
+	}
+	if responses[0].Namespace != "default" {
+		t.Fatalf("expected fallback namespace, got %q", responses[0].Namespace)
+	}
+}
+
+func TestMultiQueryResultOptions(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"results":[
+			[{"id":"a","score":0.1,"attributes":{"doc":"x"}},{"id":"b","score":0.2,"attributes":{"doc":"x"}},{"id":"c","score":0.3}],
+			[{"id":"d","score":0.1,"attributes":{"doc":"y"}},{"id":"e","score":0.2,"attributes":{"doc":"y"}}]
+		]}`))
+	}))
+	defer srv.Close()
+
+	client := New(WithQueryURL(srv.URL))
+	responses, err := client.MultiQuery(context.Background(), []Vector{{0.1}, {0.2}}, &QueryOptions{DedupeBy: "doc"})
+	if err != nil {
+		t.Fatalf("multi query failed: %v", err)
+	}
+	if len(responses[0].Results) != 2 || responses[0].Results[1].ID != "c" || len(responses[1].Results) != 1 {
+		t.Fatalf("expected each batch response to be deduplicated, got %+v", responses)
+	}
+
+	var reranked int
+	reverse := func(_ context.Context, _ string, results []VectorResult) ([]VectorResult, error) {
+		reranked++
+		slices.Reverse(results)
+		return results, nil
+	}
+	responses, err = client.MultiQuery(context.Background(), []Vector{{0.1}, {0.2}}, &QueryOptions{Rerank: reverse})
+	if err != nil {
+		t.Fatalf("multi query failed: %v", err)
+	}
+	if reranked != 2 || responses[0].Results[0].ID != "c" || responses[1].Results[0].ID != "e" {
+		t.Fatalf("expected each batch response to be reranked, got %d calls and %+v", reranked, responses)
+	}
+	failing := func(context.Context, string, []VectorResult) ([]VectorResult, error) {
+		return nil, errors.New("reranker down")
+	}
+	if _, err := client.MultiQuery(context.Background(), []Vector{{0.1}, {0.2}}, &QueryOptions{Rerank: failing}); err == nil || !strings.Contains(err.Error(), "rerank: reranker down") {
+		t.Fatalf("expected the rerank error, got %v", err)
+	}
+}
+
+func TestMultiQueryValidation(t *testing.T) {
+	client := New()
+	_, err := client.MultiQuery(context.Background(), []Vector{{0.1}, {}, {float32(math.NaN())}}, nil)
+	if !IsValidationError(err) || !strings.Contains(err.Error(), "vector 1") {
+		t.Fatalf("expected validation error for vector 1, got %v", err)
+	}
+	if _, err := client.MultiQuery(context.Background(), nil, nil); !IsValidationError(err) {
+		t.Fatalf("expected validation error for no vectors, got %v", err)
+	}
+}
+
+func TestMultiQueryFallback(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/batch") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var captured map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&captured)
+		vec, _ := captured["vector"].([]any)
+		id := fmt.Sprintf("%v", vec[0])
+		_ = json.NewEncoder(w).Encode([]VectorResult{{ID: id}})
+	}))
+	defer srv.Close()
+
+	withoutFallback := New(WithQueryURL(srv.URL))
+	if _, err := withoutFallback.MultiQuery(context.Background(), []Vector{{1}}, nil); !IsNotFoundError(err) {
+		t.Fatalf("expected not found without fallback, got %v", err)
+	}
+
+	client := New(WithQueryURL(srv.URL), WithMultiQueryFallback(2))
+	responses, err := client.MultiQuery(context.Background(), []Vector{{1}, {2}, {3}}, nil)
+	if err != nil {
+		t.Fatalf("multi query fallback failed: %v", err)
+	}
+	for i, resp := range responses {
+		if resp.Results[0].ID != fmt.Sprintf("%d", i+1) {
+			t.Fatalf("expected results in input order, got %+v", responses)
+		}
+	}
+}
+
+func TestLoggerHook(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":"bad"}`))
+	}))
+	defer srv.Close()
+
+	var infos []RequestInfo
+	logger := func(ctx context.Context, info RequestInfo) {
+		infos = append(infos, info)
+	}
+
+	client := New(WithAPIKey("secret"), WithLogger(logger))
+	_, err := client.doRequest(context.Background(), "query", http.MethodPost, srv.URL, map[string]any{"a": 1})
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+	if len(infos) != 1 {
+		t.Fatalf("expected logger to be called once, got %d", len(infos))
+	}
+	info := infos[0]
+	if info.Method != http.MethodPost || info.URL != srv.URL || info.StatusCode != http.StatusBadRequest {
+		t.Fatalf("unexpected request info: %+v", info)
+	}
+	if info.Err == nil || !IsValidationError(info.Err) {
+		t.Fatalf("expected logged error, got %v", info.Err)
+	}
+	if info.Header.Get("Authorization") != "REDACTED" {
+		t.Fatalf("expected Authorization to be redacted, got %q", info.Header.Get("Authorization"))
+	}
+	if info.RequestBody != nil || info.ResponseBody != nil {
+		t.Fatalf("expected bodies to be omitted by default")
+	}
+
+	infos = nil
+	client = New(WithLogger(logger), WithLogBodies(true))
+	_, _ = client.doRequest(context.Background(), "query", http.MethodPost, srv.URL, map[string]any{"a": 1})
+	if string(infos[0].RequestBody) != `{"a":1}` || string(infos[0].ResponseBody) != `{"error":"bad"}` {
+		t.Fatalf("unexpected captured bodies: %q %q", infos[0].RequestBody, infos[0].ResponseBody)
+	}
+
+	infos = nil
+	_, _ = client.doRequest(context.Background(), "query", http.MethodGet, "http://127.0.0.1:1", nil)
+	if len(infos) != 1 || infos[0].Err == nil || infos[0].StatusCode != 0 {
+		t.Fatalf("expected transport error to be logged, got %+v", infos)
+	}
+}
+
+func TestResponseHook(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/vectors/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"results":[{"id":"a","score":0.5,"vector":[1,2],"attributes":{"tags":["x"]}}]}`))
+	}))
+	defer srv.Close()
+
+	type call struct {
+		op string
+		v  any
+	}
+	var calls []call
+	hook := func(op string, v any) {
+		calls = append(calls, call{op, v})
+		if resp, ok := v.(*QueryResponse); ok {
+			resp.Results[0].ID = "mutated"
+			resp.Results[0].Vector[0] = 99
+			resp.Results[0].Attributes["tags"].([]any)[0] = "mutated"
+		}
+	}
+	client := New(WithQueryURL(srv.URL), WithResponseHook(hook))
+
+	resp, err := client.Query(context.Background(), Vector{1, 2}, nil)
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if len(calls) != 1 || calls[0].op != "Query" {
+		t.Fatalf("expected one Query call, got %+v", calls)
+	}
+	observed, ok := calls[0].v.(*QueryResponse)
+	if !ok || observed == resp {
+		t.Fatalf("expected a copy of the *QueryResponse, got %T", calls[0].v)
+	}
+	got := resp.Results[0]
+	if got.ID != "a" || got.Vector[0] != 1 || got.Attributes["tags"].([]any)[0] != "x" {
+		t.Fatalf("expected hook mutations not to reach the caller, got %+v", got)
+	}
+
+	if _, err := client.Query(context.Background(), Vector{1, 2}, &QueryOptions{Namespace: "missing"}); err == nil {
+		t.Fatalf("expected error for missing namespace")
+	}
+	if len(calls) != 1 {
+		t.Fatalf("expected failed calls not to be reported, got %d calls", len(calls))
+	}
+}
+
+func TestWarningHandler(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v1/vectors/quiet":
+			_, _ = w.Write([]byte(`{"results":[]}`))
+		case r.URL.Path == "/v1/vectors/plain":
+			_, _ = w.Write([]byte("OK"))
+		case r.URL.Path == "/v1/vectors/default/batch":
+			_, _ = w.Write([]byte(`{"results":[{"results":[],"warnings":["slow segment"]},{"results":[]}]}`))
+		default:
+			var body map[string]json.RawMessage
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if _, ok := body["vectors"]; ok {
+				_, _ = w.Write([]byte(`{"upserted":1,"warnings":["attribute \"x\" truncated"]}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"results":[{"id":"a","score":0.5}],"warnings":["topK exceeds segment size","ef_search ignored"]}`))
+		}
+	}))
+	defer srv.Close()
+
+	type report struct {
+		op       string
+		warnings []string
+	}
+	var reports []report
+	handler := func(op string, warnings []string) {
+		reports = append(reports, report{op, warnings})
+	}
+	ctx := context.Background()
+	client := New(WithBaseURL(srv.URL), WithWarningHandler(handler))
+
+	resp, err := client.Query(ctx, Vector{1}, &QueryOptions{TopK: 500})
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	want := []string{"topK exceeds segment size", "ef_search ignored"}
+	if !slices.Equal(resp.Warnings, want) {
+		t.Fatalf("expected QueryResponse.Warnings %v, got %v", want, resp.Warnings)
+	}
+	if len(reports) != 1 || reports[0].op != "Query" || !slices.Equal(reports[0].warnings, want) {
+		t.Fatalf("expected one Query report, got %+v", reports)
+	}
+	reports[0].warnings[0] = "mutated"
+	if resp.Warnings[0] != want[0] {
+		t.Fatalf("expected handler mutations not to reach the caller")
+	}
+
+	result, err := client.UpsertWithResult(ctx, []Document{{ID: "a", Vector: Vector{1}}, {ID: "b", Vector: Vector{1}}}, &UpsertOptions{BatchSize: 1})
+	if err != nil {
+		t.Fatalf("upsert failed: %v", err)
+	}
+	if len(result.Warnings) != 2 || len(reports) != 2 || reports[1].op != "UpsertWithResult" || len(reports[1].warnings) != 2 {
+		t.Fatalf("expected warnings from both batches, got %v and %+v", result.Warnings, reports)
+	}
+	if err := client.Upsert(ctx, []Document{{ID: "a", Vector: Vector{1}}}, nil); err != nil {
+		t.Fatalf("upsert failed: %v", err)
+	}
+	if len(reports) != 3 || reports[2].op != "Upsert" {
+		t.Fatalf("expected an Upsert report, got %+v", reports)
+	}
+	if err := client.Upsert(ctx, []Document{{ID: "a", Vector: Vector{1}}}, &UpsertOptions{Namespace: "plain"}); err != nil {
+		t.Fatalf("expected a non-JSON upsert response to succeed, got %v", err)
+	}
+	if len(reports) != 3 {
+		t.Fatalf("expected a non-JSON upsert response to carry no warnings, got %+v", reports)
+	}
+
+	if _, err := client.MultiQuery(ctx, []Vector{{1}, {2}}, nil); err != nil {
+		t.Fatalf("multi query failed: %v", err)
+	}
+	if len(reports) != 4 || reports[3].op != "MultiQuery" || reports[3].warnings[0] != "slow segment" {
+		t.Fatalf("expected one MultiQuery report, got %+v", reports)
+	}
+	if _, err := client.Query(ctx, Vector{1}, &QueryOptions{Namespace: "quiet"}); err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if len(reports) != 4 {
+		t.Fatalf("expected responses without warnings not to be reported, got %+v", reports)
+	}
+
+	reports = nil
+	if err := client.UpsertConcurrent(ctx, []Document{{ID: "a", Vector: Vector{1}}, {ID: "b", Vector: Vector{1}}}, &UpsertOptions{BatchSize: 1}, 1); err != nil {
+		t.Fatalf("upsert concurrent failed: %v", err)
+	}
+	stream := make(chan Document, 1)
+	stream <- Document{ID: "a", Vector: Vector{1}}
+	close(stream)
+	if err := client.UpsertStream(ctx, stream, nil); err != nil {
+		t.Fatalf("upsert stream failed: %v", err)
+	}
+	if len(reports) != 3 || reports[0].op != "UpsertConcurrent" || reports[1].op != "UpsertConcurrent" || reports[2].op != "UpsertStream" {
+		t.Fatalf("expected one report per written batch, got %+v", reports)
+	}
+}
+
+type recordingInstrumenter struct {
+	started []string
+	infos   []OperationInfo
+}
+
+func (r *recordingInstrumenter) StartOperation(ctx context.Context, name string) (context.Context, func(OperationInfo)) {
+	r.started = append(r.started, name)
+	return ctx, func(info OperationInfo) {
+		r.infos = append(r.infos, info)
+	}
+}
+
+func TestInstrumenterReceivesOperationInfo(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode([]VectorResult{{ID: "a"}})
+	}))
+	defer srv.Close()
+
+	inst := &recordingInstrumenter{}
+	client := New(WithQueryURL(srv.URL), WithInstrumenter(inst))
+	if _, err := client.Query(context.Background(), Vector{0.1}, &QueryOptions{Namespace: "docs", TopK: 3}); err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if _, err := client.Query(context.Background(), nil, &QueryOptions{Namespace: "docs"}); err == nil {
+		t.Fatalf("expected validation error")
+	}
+
+	if len(inst.infos) != 2 || inst.started[0] != "Query" {
+		t.Fatalf("expected two Query operations, got %v", inst.started)
+	}
+	info := inst.infos[0]
+	if info.Name != "Query" || info.Namespace != "docs" || info.TopK != 3 || info.ResultCount != 1 || info.StatusCode != http.StatusOK || info.Err != nil {
+		t.Fatalf("unexpected operation info: %+v", info)
+	}
+	if !IsValidationError(inst.infos[1].Err) || inst.infos[1].StatusCode != 0 {
+		t.Fatalf("expected validation error without status, got %+v", inst.infos[1])
+	}
+}
+
+func TestRequestCompression(t *testing.T) {
+	var (
+		encoding string
+		docs     int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding = r.Header.Get("Content-Encoding")
+		var reader io.Reader = r.Body
+		if encoding == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Fatalf("gzip reader: %v", err)
+			}
+			reader = zr
+		}
+		var body struct {
+			Vectors []Document `json:"vectors"`
+		}
+		if err := json.NewDecoder(reader).Decode(&body); err != nil {
+			t.Fatalf("decode body: %v", err)
+		}
+		docs = len(body.Vectors)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	large := make([]Document, 500)
+	for i := range large {
+		large[i] = Document{ID: fmt.Sprintf("doc-%d", i), Vector: Vector{0.1, 0.2, 0.3}}
+	}
+
+	client := New(WithIngestURL(srv.URL), WithCompression(true))
+	if err := client.Upsert(context.Background(), large, nil); err != nil {
+		t.Fatalf("upsert failed: %v", err)
+	}
+	if encoding != "gzip" || docs != len(large) {
+		t.Fatalf("expected gzip body with %d docs, got encoding %q and %d docs", len(large), encoding, docs)
+	}
+
+	if err := client.Upsert(context.Background(), large[:1], nil); err != nil {
+		t.Fatalf("upsert failed: %v", err)
+	}
+	if encoding != "" {
+		t.Fatalf("expected small body to be sent uncompressed")
+	}
+
+	uncompressed := New(WithIngestURL(srv.URL))
+	if err := uncompressed.Upsert(context.Background(), large, nil); err != nil {
+		t.Fatalf("upsert failed: %v", err)
+	}
+	if encoding != "" {
+		t.Fatalf("expected compression to be opt-in")
+	}
+}
+
+func TestResponseDecompression(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept-Encoding") != "gzip" {
+			t.Fatalf("expected Accept-Encoding gzip, got %q", r.Header.Get("Accept-Encoding"))
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		_ = json.NewEncoder(zw).Encode([]VectorResult{{ID: "a", Score: 0.5}})
+		_ = zw.Close()
+	}))
+	defer srv.Close()
+
+	transports := map[string]*http.Client{
+		"default":            nil,
+		"custom transport":   {Transport: &http.Transport{}},
+		"compression off":    {Transport: &http.Transport{DisableCompression: true}},
+		"wrapped round trip": {Transport: roundTripperFunc(http.DefaultTransport.RoundTrip)},
+	}
+	for name, httpClient := range transports {
+		t.Run(name, func(t *testing.T) {
+			opts := []Option{WithQueryURL(srv.URL)}
+			if httpClient != nil {
+				opts = append(opts, WithHTTPClient(httpClient))
+			}
+			resp, err := New(opts...).Query(context.Background(), Vector{0.1}, nil)
+			if err != nil {
+				t.Fatalf("query failed: %v", err)
+			}
+			if len(resp.Results) != 1 || resp.Results[0].ID != "a" {
+				t.Fatalf("unexpected results: %+v", resp.Results)
+			}
+		})
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"5", 5 * time.Second},
+		{"-1", 0},
+		{"soon", 0},
+		{now.Add(10 * time.Second).Format(http.TimeFormat), 10 * time.Second},
+		{now.Add(-10 * time.Second).Format(http.TimeFormat), 0},
+	}
+	for _, tc := range cases {
+		if got := parseRetryAfter(tc.value, now); got != tc.want {
+			t.Fatalf("parseRetryAfter(%q): expected %s, got %s", tc.value, tc.want, got)
+		}
+	}
+}
+
+func TestQueryRerank(t *testing.T) {
+	results := []VectorResult{{ID: "a", Score: 0.1}, {ID: "b", Score: 0.2}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(results)
+	}))
+	defer srv.Close()
+
+	client := New(WithQueryURL(srv.URL))
+	var gotQuery string
+	rerank := func(ctx context.Context, query string, in []VectorResult) ([]VectorResult, error) {
+		gotQuery = query
+		return []VectorResult{{ID: in[1].ID, Score: 1}}, nil
+	}
+
+	resp, err := client.Query(context.Background(), Vector{0.1}, &QueryOptions{Text: " shoes ", Rerank: rerank})
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if gotQuery != "shoes" {
+		t.Fatalf("expected rerank query text, got %q", gotQuery)
+	}
+	if len(resp.Results) != 1 || resp.Results[0].ID != "b" || resp.Results[0].Score != 1 {
+		t.Fatalf("expected reranked results, got %+v", resp.Results)
+	}
+
+	results = results[:1]
+	called := false
+	_, err = client.Query(context.Background(), Vector{0.1}, &QueryOptions{Rerank: func(ctx context.Context, query string, in []VectorResult) ([]VectorResult, error) {
+		called = true
+		return in, nil
+	}})
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if called {
+		t.Fatalf("expected rerank to be skipped for fewer than two results")
+	}
+
+	results = append(results, VectorResult{ID: "c"})
+	_, err = client.Query(context.Background(), Vector{0.1}, &QueryOptions{Rerank: func(ctx context.Context, query string, in []VectorResult) ([]VectorResult, error) {
+		return nil, errors.New("model offline")
+	}})
+	if err == nil || !strings.Contains(err.Error(), "model offline") {
+		t.Fatalf("expected rerank error, got %v", err)
+	}
+}
+
+type fakeEmbedder struct {
+	calls [][]string
+}
+
+func (e *fakeEmbedder) Embed(_ context.Context, texts []string) ([]Vector, error) {
+	e.calls = append(e.calls, texts)
+	vectors := make([]Vector, len(texts))
+	for i, text := range texts {
+		vectors[i] = Vector{float32(len(text)), 1}
+	}
+	return vectors, nil
+}
+
+func TestEmbedderFillsMissingVectors(t *testing.T) {
+	var upserted struct {
+		Vectors []Document `json:"vectors"`
+	}
+	var queried map[string]any
+	ingest := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_ = json.NewDecoder(req.Body).Decode(&upserted)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ingest.Close()
+	query := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_ = json.NewDecoder(req.Body).Decode(&queried)
+		_ = json.NewEncoder(w).Encode([]VectorResult{{ID: "a"}})
+	}))
+	defer query.Close()
+
+	embedder := &fakeEmbedder{}
+	client := New(WithIngestURL(ingest.URL), WithQueryURL(query.URL), WithEmbedder(embedder))
+	ctx := context.Background()
+
+	docs := []Document{
+		{ID: "a", Text: "abc"},
+		{ID: "b", Vector: Vector{9, 9}, Text: "has vector"},
+	}
+	if err := client.Upsert(ctx, docs, nil); err != nil {
+		t.Fatalf("upsert failed: %v", err)
+	}
+	if len(embedder.calls) != 1 || len(embedder.calls[0]) != 1 || embedder.calls[0][0] != "abc" {
+		t.Fatalf("expected only text-only document to be embedded, got %v", embedder.calls)
+	}
+	if len(upserted.Vectors) != 2 || upserted.Vectors[0].Vector[0] != 3 || upserted.Vectors[1].Vector[0] != 9 {
+		t.Fatalf("unexpected upserted documents: %+v", upserted.Vectors)
+	}
+	if docs[0].Vector != nil {
+		t.Fatalf("expected input documents to be left unmodified")
+	}
+
+	if _, err := client.Query(ctx, nil, &QueryOptions{Text: "hello", Mode: QueryModeVector}); err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	vec, _ := queried["vector"].([]any)
+	if len(vec) != 2 || vec[0] != float64(5) {
+		t.Fatalf("expected embedded query vector, got %v", queried["vector"])
+	}
+
+	if _, err := client.Query(ctx, Vector{1, 2}, &QueryOptions{Text: "hello", Mode: QueryModeVector}); err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if len(embedder.calls) != 2 {
+		t.Fatalf("expected embedder to be skipped when a vector is given, got %d calls", len(embedder.calls))
+	}
+}
+
+func TestRejectsNonJSONContentType(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write([]byte("<html><body>upstream unavailable</body></html>"))
+	}))
+	defer srv.Close()
+
+	client := New(WithQueryURL(srv.URL))
+	_, err := client.Query(context.Background(), Vector{0.1}, nil)
+	if err == nil || !strings.Contains(err.Error(), "text/html") || !strings.Contains(err.Error(), "upstream unavailable") {
+		t.Fatalf("expected content type error with body snippet, got %v", err)
+	}
+
+	if err := checkContentType("application/vnd.tidepool+json", []byte(`{}`)); err != nil {
+		t.Fatalf("expected +json media type to be accepted, got %v", err)
+	}
+	if err := checkContentType("text/html", nil); err != nil {
+		t.Fatalf("expected empty body to be accepted, got %v", err)
+	}
+}
+
+func TestUserAgent(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get("User-Agent"))
+		_ = json.NewEncoder(w).Encode(HealthResponse{Status: "ok"})
+	}))
+	defer srv.Close()
+
+	if _, err := New(WithQueryURL(srv.URL)).Health(context.Background(), "query"); err != nil {
+		t.Fatalf("health failed: %v", err)
+	}
+	if _, err := New(WithQueryURL(srv.URL), WithUserAgent("search-api/2.3")).Health(context.Background(), "query"); err != nil {
+		t.Fatalf("health failed: %v", err)
+	}
+
+	if got[0] != "tidepool-go/"+Version {
+		t.Fatalf("unexpected default user agent %q", got[0])
+	}
+	if got[1] != "search-api/2.3 tidepool-go/"+Version {
+		t.Fatalf("unexpected custom user agent %q", got[1])
+	}
+}
+
+func TestQueryDedupeBy(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode([]VectorResult{
+			{ID: "a1", Score: 0.1, Attributes: Attributes{"source": "a"}},
+			{ID: "b1", Score: 0.2, Attributes: Attributes{"source": "b"}},
+			{ID: "a2", Score: 0.3, Attributes: Attributes{"source": "a"}},
+			{ID: "x", Score: 0.4},
+			{ID: "y", Score: 0.5},
+			{ID: "b2", Score: 0.6, Attributes: Attributes{"source": "b"}},
+		})
+	}))
+	defer srv.Close()
+
+	client := New(WithQueryURL(srv.URL))
+	resp, err := client.Query(context.Background(), Vector{0.1}, &QueryOptions{DedupeBy: "source"})
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	var ids []string
+	for _, result := range resp.Results {
+		ids = append(ids, result.ID)
+	}
+	if strings.Join(ids, ",") != "a1,b1,x,y" {
+		t.Fatalf("unexpected deduplicated results %v", ids)
+	}
+}
+
+func TestAlphaClampOptions(t *testing.T) {
+	var captured map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		captured = nil
+		_ = json.NewDecoder(r.Body).Decode(&captured)
+		_ = json.NewEncoder(w).Encode([]VectorResult{})
+	}))
+	defer srv.Close()
+
+	alpha := float32(1.5)
+	opts := &QueryOptions{Text: "hello", Mode: QueryModeHybrid, Alpha: &alpha}
+
+	client := New(WithQueryURL(srv.URL), WithAlphaClamp(false))
+	if _, err := client.Query(context.Background(), Vector{0.1}, opts); err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if captured["alpha"] != 1.5 {
+		t.Fatalf("expected alpha passed through unclamped, got %v", captured["alpha"])
+	}
+
+	client = New(WithQueryURL(srv.URL), WithAlphaClamp(false), WithStrictAlpha(true))
+	if _, err := client.Query(context.Background(), Vector{0.1}, opts); !IsValidationError(err) {
+		t.Fatalf("expected validation error for out-of-range alpha, got %v", err)
+	}
+
+	inRange := float32(0.4)
+	if _, err := client.Query(context.Background(), Vector{0.1}, &QueryOptions{Text: "hello", Mode: QueryModeHybrid, Alpha: &inRange}); err != nil {
+		t.Fatalf("expected in-range alpha to be accepted in strict mode, got %v", err)
+	}
+}
+
+func TestQueryReportsRoundTrip(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		_ = json.NewEncoder(w).Encode([]VectorResult{{ID: "a"}})
+	}))
+	defer srv.Close()
+
+	resp, err := New(WithQueryURL(srv.URL)).Query(context.Background(), Vector{0.1}, nil)
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if resp.TookMS != 0 {
+		t.Fatalf("expected zero took_ms for direct array response, got %v", resp.TookMS)
+	}
+	if resp.RoundTrip < 10*time.Millisecond {
+		t.Fatalf("expected round trip of at least 10ms, got %s", resp.RoundTrip)
+	}
+}
+
+func TestQueryExtraFields(t *testing.T) {
+	var captured map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&captured); err != nil {
+			t.Fatalf("decode body: %v", err)
+		}
+		_ = json.NewEncoder(w).Encode([]VectorResult{{ID: "a"}})
+	}))
+	defer srv.Close()
+
+	client := New(WithQueryURL(srv.URL))
+	_, err := client.Query(context.Background(), Vector{0.1}, &QueryOptions{
+		TopK:  3,
+		Extra: Attributes{"rescore_depth": 50},
+	})
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if captured["rescore_depth"] != float64(50) || captured["top_k"] != float64(3) {
+		t.Fatalf("expected extra fields merged with typed fields, got %v", captured)
+	}
+
+	_, err = client.Query(context.Background(), Vector{0.1}, &QueryOptions{Extra: Attributes{"top_k": 5}})
+	if !IsValidationError(err) {
+		t.Fatalf("expected validation error for colliding extra field, got %v", err)
+	}
+}
+
+func TestQueryIncludeAttributes(t *testing.T) {
+	var captured map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		captured = nil
+		if err := json.NewDecoder(r.Body).Decode(&captured); err != nil {
+			t.Fatalf("decode body: %v", err)
+		}
+		_, _ = w.Write([]byte(`[{"id":"a","attributes":{"title":"Hello"}}]`))
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	client := New(WithQueryURL(srv.URL))
+	resp, err := client.Query(ctx, Vector{0.1}, &QueryOptions{IncludeAttributes: []string{"title"}})
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if !reflect.DeepEqual(captured["include_attributes"], []any{"title"}) {
+		t.Fatalf("expected include_attributes in request, got %v", captured)
+	}
+	if title, ok := resp.Results[0].GetString("title"); !ok || title != "Hello" || len(resp.Results[0].Attributes) != 1 {
+		t.Fatalf("unexpected attributes: %v", resp.Results[0].Attributes)
+	}
+
+	if _, err := client.Query(ctx, Vector{0.1}, nil); err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if _, ok := captured["include_attributes"]; ok {
+		t.Fatalf("expected include_attributes to be omitted by default, got %v", captured)
+	}
+
+	if _, err := client.Query(ctx, Vector{0.1}, &QueryOptions{IncludeAttributes: []string{"title", " "}}); !IsValidationError(err) {
+		t.Fatalf("expected validation error for empty attribute key, got %v", err)
+	}
+}
+
+func TestQueryMinScore(t *testing.T) {
+	var captured map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		captured = nil
+		_ = json.NewDecoder(r.Body).Decode(&captured)
+		_, _ = w.Write([]byte(`{"results":[{"id":"a","score":0.1},{"id":"b","score":0.5},{"id":"c","score":0.9}]}`))
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	client := New(WithQueryURL(srv.URL))
+	ids := func(results []VectorResult) string {
+		var out []string
+		for _, r := range results {
+			out = append(out, r.ID)
+		}
+		return strings.Join(out, ",")
+	}
+
+	tests := []struct {
+		name   string
+		vector Vector
+		opts   *QueryOptions
+		want   string
+	}{
+		{name: "unset", vector: Vector{1}, opts: &QueryOptions{}, want: "a,b,c"},
+		{name: "distance ceiling", vector: Vector{1}, opts: &QueryOptions{MinScore: Float32(0.5), DistanceMetric: DistanceCosine}, want: "a,b"},
+		{name: "default metric is a distance", vector: Vector{1}, opts: &QueryOptions{MinScore: Float32(0.5)}, want: "a,b"},
+		{name: "dot product floor", vector: Vector{1}, opts: &QueryOptions{MinScore: Float32(0.5), DistanceMetric: DistanceDotProduct}, want: "b,c"},
+		{name: "text floor", opts: &QueryOptions{MinScore: Float32(0.5), Text: "shoes"}, want: "b,c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.Query(ctx, tt.vector, tt.opts)
+			if err != nil {
+				t.Fatalf("query failed: %v", err)
+			}
+			if got := ids(resp.Results); got != tt.want {
+				t.Fatalf("expected %s, got %s", tt.want, got)
+			}
+			minScore, sent := captured["min_score"]
+			if sent != (tt.opts.MinScore != nil) || (sent && minScore != 0.5) {
+				t.Fatalf("expected min_score to be sent only when set, got %v", captured)
+			}
+		})
+	}
+
+	stream, err := client.QueryStream(ctx, Vector{1}, &QueryOptions{MinScore: Float32(0.5), DistanceMetric: DistanceDotProduct})
+	if err != nil {
+		t.Fatalf("query stream failed: %v", err)
+	}
+	var streamed []VectorResult
+	for result, err := range stream {
+		if err != nil {
+			t.Fatalf("stream error: %v", err)
+		}
+		streamed = append(streamed, result)
+	}
+	if got := ids(streamed); got != "b,c" {
+		t.Fatalf("expected streamed results b,c, got %s", got)
+	}
+
+	for _, bad := range []float32{float32(math.NaN()), float32(math.Inf(-1))} {
+		if _, err := client.Query(ctx, Vector{1}, &QueryOptions{MinScore: Float32(bad)}); !IsValidationError(err) {
+			t.Fatalf("expected validation error for min score %v, got %v", bad, err)
+		}
+	}
+}
+
+func TestMultiVectorQuery(t *testing.T) {
+	var captured map[string]json.RawMessage
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		captured = nil
+		_ = json.NewDecoder(r.Body).Decode(&captured)
+		_, _ = w.Write([]byte(`{"results":[{"id":"a","score":0.1}]}`))
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	client := New(WithQueryURL(srv.URL))
+	vectors := []Vector{{1, 0}, {0, 1}, {0.5, 0.5}}
+	resp, err := client.Query(ctx, Vector{9, 9}, &QueryOptions{Vectors: vectors})
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if len(resp.Results) != 1 {
+		t.Fatalf("expected decoded results, got %+v", resp.Results)
+	}
+	if string(captured["vectors"]) != `[[1,0],[0,1],[0.5,0.5]]` {
+		t.Fatalf("expected vectors array, got %s", captured["vectors"])
+	}
+	if _, ok := captured["vector"]; ok {
+		t.Fatalf("expected Vectors to take precedence over the single vector, got %s", captured["vector"])
+	}
+	if string(captured["mode"]) != `"vector"` {
+		t.Fatalf("expected vector mode, got %s", captured["mode"])
+	}
+
+	tests := []struct {
+		name    string
+		vectors []Vector
+		want    string
+	}{
+		{name: "dimension mismatch", vectors: []Vector{{1, 0}, {1, 0}, {1}}, want: "vectors[2]"},
+		{name: "empty vector", vectors: []Vector{{1, 0}, {}}, want: "vectors[1]"},
+		{name: "non-finite value", vectors: []Vector{{float32(math.Inf(1)), 0}}, want: "vectors[0]"},
+	}
+	for _, tt := range tests {
+		_, err := client.Query(ctx, nil, &QueryOptions{Vectors: tt.vectors})
+		if !IsValidationError(err) || !strings.Contains(err.Error(), tt.want) {
+			t.Fatalf("%s: expected validation error naming %s, got %v", tt.name, tt.want, err)
+		}
+	}
+	if _, err := client.Query(ctx, nil, &QueryOptions{Vectors: []Vector{{1}, {1, 2}}}); !errors.Is(err, ErrDimensionMismatch) {
+		t.Fatalf("expected ErrDimensionMismatch, got %v", err)
+	}
+	if _, err := client.MultiQuery(ctx, []Vector{{1}}, &QueryOptions{Vectors: vectors}); !IsValidationError(err) {
+		t.Fatalf("expected MultiQuery to reject Vectors, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected invalid queries to send nothing, got %d requests", calls)
+	}
+}
+
+func TestDefaultDistanceMetric(t *testing.T) {
+	var metrics []any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("decode body: %v", err)
+		}
+		metrics = append(metrics, body["distance_metric"])
+		if strings.HasPrefix(r.URL.Path, "/v1/vectors/") && body["vectors"] == nil {
+			_ = json.NewEncoder(w).Encode([]VectorResult{{ID: "a"}})
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	client := New(WithBaseURL(srv.URL), WithDefaultDistanceMetric(DistanceDotProduct))
+	docs := []Document{{ID: "a", Vector: Vector{1}}}
+	if err := client.Upsert(ctx, docs, nil); err != nil {
+		t.Fatalf("upsert failed: %v", err)
+	}
+	if err := client.Upsert(ctx, docs, &UpsertOptions{DistanceMetric: DistanceCosine}); err != nil {
+		t.Fatalf("upsert failed: %v", err)
+	}
+	if _, err := client.Query(ctx, Vector{1}, nil); err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if _, err := client.Query(ctx, Vector{1}, &QueryOptions{DistanceMetric: DistanceEuclidean}); err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if err := client.CreateNamespace(ctx, "products", nil); err != nil {
+		t.Fatalf("create namespace failed: %v", err)
+	}
+	want := []any{"dot_product", "cosine_distance", "dot_product", "euclidean_squared", "dot_product"}
+	if !reflect.DeepEqual(metrics, want) {
+		t.Fatalf("expected metrics %v, got %v", want, metrics)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected an unknown default metric to panic")
+		}
+	}()
+	New(WithDefaultDistanceMetric("dot"))
+}
+
+func TestMetricInference(t *testing.T) {
+	var queryMetrics []any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("decode body: %v", err)
+		}
+		if _, ok := body["vectors"]; ok {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		queryMetrics = append(queryMetrics, body["distance_metric"])
+		_ = json.NewEncoder(w).Encode([]VectorResult{{ID: "a"}})
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	for _, enabled := range []bool{true, false} {
+		queryMetrics = nil
+		client := New(WithQueryURL(srv.URL), WithIngestURL(srv.URL), WithMetricInference(enabled))
+
+		if _, err := client.Query(ctx, Vector{1}, &QueryOptions{Namespace: "a"}); err != nil {
+			t.Fatalf("query failed: %v", err)
+		}
+		err := client.Upsert(ctx, []Document{{ID: "1", Vector: Vector{1}}}, &UpsertOptions{Namespace: "a", DistanceMetric: DistanceEuclidean})
+		if err != nil {
+			t.Fatalf("upsert failed: %v", err)
+		}
+		for _, opts := range []*QueryOptions{
+			{Namespace: "a"},
+			{Namespace: "a", DistanceMetric: DistanceCosine},
+			{Namespace: "b"},
+		} {
+			if _, err := client.Query(ctx, Vector{1}, opts); err != nil {
+				t.Fatalf("query failed: %v", err)
+			}
+		}
+
+		want := []any{nil, nil, string(DistanceCosine), nil}
+		if enabled {
+			want[1] = string(DistanceEuclidean)
+		}
+		if !reflect.DeepEqual(queryMetrics, want) {
+			t.Fatalf("inference %v: expected query metrics %v, got %v", enabled, want, queryMetrics)
+		}
+	}
+}
+
+func TestTransportErrorClassification(t *testing.T) {
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	_, err := New(WithQueryURL(down.URL)).Health(context.Background(), "query")
+	if !IsServiceUnavailableError(err) {
+		t.Fatalf("expected connection refused to be service unavailable, got %v", err)
+	}
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) {
+		t.Fatalf("expected *net.OpError in chain, got %v", err)
+	}
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer slow.Close()
+
+	_, err = New(WithQueryURL(slow.URL), WithTimeout(20*time.Millisecond)).Health(context.Background(), "query")
+	if !IsServiceUnavailableError(err) {
+		t.Fatalf("expected client timeout to be service unavailable, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = New(WithQueryURL(slow.URL)).Health(ctx, "query")
+	if IsServiceUnavailableError(err) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected caller deadline to stay unclassified, got %v", err)
+	}
+}
+
+func TestRetryOnlyResendsSafeRequests(t *testing.T) {
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		// Drop the connection after reading the request, as a crashing
+		// server would.
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			_ = conn.Close()
+		}
+	}))
+	defer srv.Close()
+
+	client := New(WithBaseURL(srv.URL), WithRetry(RetryPolicy{MaxRetries: 2, InitialBackoff: time.Millisecond}))
+	ctx := context.Background()
+
+	err := client.Upsert(ctx, []Document{{ID: "a", Vector: Vector{1}}}, nil)
+	if !IsServiceUnavailableError(err) || attempts.Load() != 1 {
+		t.Fatalf("expected a dropped upsert to be sent once, got %d attempts: %v", attempts.Load(), err)
+	}
+
+	attempts.Store(0)
+	if _, err := client.Query(ctx, Vector{1}, nil); !IsServiceUnavailableError(err) || attempts.Load() != 3 {
+		t.Fatalf("expected a dropped query to be retried, got %d attempts: %v", attempts.Load(), err)
+	}
+
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	if err := New(WithBaseURL(down.URL)).Upsert(ctx, []Document{{ID: "a", Vector: Vector{1}}}, nil); !canResend("ingest", http.MethodPost, err) {
+		t.Fatalf("expected a refused upsert to be safe to resend, got %v", err)
+	}
+}
+
+func TestRequestSigner(t *testing.T) {
+	var (
+		signed   [][]byte
+		received [][]byte
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, body)
+		if got := r.Header.Get("X-Signature"); got != fmt.Sprintf("len=%d", len(body)) {
+			t.Errorf("expected signature header for %q, got %q", body, got)
+		}
+		_, _ = w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer srv.Close()
+
+	client := New(WithBaseURL(srv.URL), WithRequestSigner(func(body []byte) http.Header {
+		signed = append(signed, body)
+		return http.Header{"x-signature": {fmt.Sprintf("len=%d", len(body))}}
+	}))
+	ctx := context.Background()
+	docs := []Document{{ID: "a", Vector: Vector{1.5, 2}, Attributes: Attributes{"z": 1, "a": Attributes{"y": true, "b": 1e21}}}}
+	if err := client.Upsert(ctx, docs, nil); err != nil {
+		t.Fatalf("upsert failed: %v", err)
+	}
+	if _, err := client.Health(ctx, "query"); err != nil {
+		t.Fatalf("health failed: %v", err)
+	}
+
+	want := `{"vectors":[{"attributes":{"a":{"b":1e+21,"y":true},"z":1},"id":"a","vector":[1.5,2]}]}`
+	if len(signed) != 2 || string(signed[0]) != want || signed[1] != nil {
+		t.Fatalf("expected canonical body then nil, got %q", signed)
+	}
+	if string(received[0]) != want {
+		t.Fatalf("expected the signed bytes to be sent, got %s", received[0])
+	}
+}
+
+func TestQueryMulti(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/vectors/docs":
+			_, _ = w.Write([]byte(`[{"id":"a","score":0.3},{"id":"b","score":0.1}]`))
+		case "/v1/vectors/faqs":
+			_, _ = w.Write([]byte(`{"results":[{"id":"c","score":0.2},{"id":"d","score":0.3}]}`))
+		case "/v1/vectors/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":"namespace not found"}`))
+		}
+	}))
+	defer srv.Close()
+
+	var (
+		mu       sync.Mutex
+		logged   []int
+		warnings []string
+	)
+	client := New(WithQueryURL(srv.URL), WithLogger(func(_ context.Context, info RequestInfo) {
+		mu.Lock()
+		logged = append(logged, info.StatusCode)
+		mu.Unlock()
+	}), WithWarningHandler(func(op string, w []string) {
+		if op == "QueryMulti" {
+			warnings = append(warnings, w...)
+		}
+	}))
+	ctx := context.Background()
+
+	results, err := client.QueryMulti(ctx, Vector{1}, []string{"docs", "gone", "faqs"}, &QueryOptions{TopK: 3})
+	if err != nil {
+		t.Fatalf("query multi failed: %v", err)
+	}
+	var got []string
+	for _, r := range results {
+		got = append(got, r.Namespace+"/"+r.ID)
+	}
+	if strings.Join(got, ",") != "docs/b,faqs/c,docs/a" {
+		t.Fatalf("expected merged top 3 by ascending distance, got %v", got)
+	}
+	if !slices.Contains(logged, http.StatusNotFound) {
+		t.Fatalf("expected the skipped 404 to be logged, got %v", logged)
+	}
+	if len(warnings) != 1 || warnings[0] != `namespace "gone" not found, skipped` {
+		t.Fatalf("expected the skipped namespace to be reported as a warning, got %q", warnings)
+	}
+
+	results, err = client.QueryMulti(ctx, Vector{1}, []string{"docs", "faqs"}, &QueryOptions{DistanceMetric: DistanceDotProduct})
+	if err != nil || len(results) != 4 || results[0].Score != 0.3 || results[0].Namespace != "docs" || results[1].Namespace != "faqs" {
+		t.Fatalf("expected all results by descending dot product with ties in namespace order, got %+v: %v", results, err)
+	}
+
+	results, err = client.QueryMulti(ctx, nil, []string{"docs", "faqs"}, &QueryOptions{Text: "refunds", TopK: 2})
+	got = nil
+	for _, r := range results {
+		got = append(got, r.Namespace+"/"+r.ID)
+	}
+	if err != nil || strings.Join(got, ",") != "docs/a,faqs/d" {
+		t.Fatalf("expected the top 2 text matches by descending relevance, got %v: %v", got, err)
+	}
+
+	if _, err := client.QueryMulti(ctx, Vector{1}, []string{"gone", "missing"}, nil); !IsNotFoundError(err) {
+		t.Fatalf("expected not found when every namespace is missing, got %v", err)
+	}
+	if _, err := client.QueryMulti(ctx, Vector{1}, []string{"docs", "broken"}, nil); !IsServerError(err) || !strings.Contains(err.Error(), `namespace "broken"`) {
+		t.Fatalf("expected server error naming broken, got %v", err)
+	}
+	for _, namespaces := range [][]string{nil, {"docs", "docs"}} {
+		if _, err := client.QueryMulti(ctx, Vector{1}, namespaces, nil); !IsValidationError(err) {
+			t.Fatalf("expected validation error for %v, got %v", namespaces, err)
+		}
+	}
+	if _, err := client.QueryMulti(ctx, Vector{1}, []string{"docs"}, &QueryOptions{Namespace: "faqs"}); !IsValidationError(err) {
+		t.Fatalf("expected validation error for opts.Namespace, got %v", err)
+	}
+}
+
+func TestQueryMultiEmbedsOnce(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		vectors int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req queryRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		if len(req.Vector) > 0 {
+			vectors++
+		}
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/v1/vectors/docs":
+			_, _ = w.Write([]byte(`[{"id":"a","score":0.3},{"id":"b","score":0.1}]`))
+		default:
+			_, _ = w.Write([]byte(`[{"id":"c","score":0.2},{"id":"d","score":0.3}]`))
+		}
+	}))
+	defer srv.Close()
+
+	embedder := &fakeEmbedder{}
+	client := New(WithQueryURL(srv.URL), WithEmbedder(embedder))
+	results, err := client.QueryMulti(context.Background(), nil, []string{"docs", "faqs"}, &QueryOptions{Text: "refunds", Mode: QueryModeHybrid, TopK: 2})
+	if err != nil {
+		t.Fatalf("query multi failed: %v", err)
+	}
+	if len(embedder.calls) != 1 || vectors != 2 {
+		t.Fatalf("expected one embedding shared by both namespaces, got %d embed calls and %d vectors sent", len(embedder.calls), vectors)
+	}
+	if len(results) != 2 || results[0].ID != "a" || results[1].ID != "d" {
+		t.Fatalf("expected the top 2 hybrid matches by descending relevance, got %+v", results)
+	}
+}
+
+func TestMaxResponseBytes(t *testing.T) {
+	payload := `{"status":"ok","padding":"` + strings.Repeat("x", 2000) + `"}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("gzip") != "" {
+			w.Header().Set("Content-Encoding", "gzip")
+			zw := gzip.NewWriter(w)
+			_, _ = zw.Write([]byte(payload))
+			_ = zw.Close()
+			return
+		}
+		_, _ = w.Write([]byte(payload))
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	for _, url := range []string{srv.URL, srv.URL + "/?gzip=1"} {
+		small := New(WithQueryURL(url), WithMaxResponseBytes(1024))
+		if _, err := small.Health(ctx, "query"); !errors.Is(err, ErrResponseTooLarge) {
+			t.Fatalf("%s: expected ErrResponseTooLarge, got %v", url, err)
+		}
+		for _, limit := range []int64{int64(len(payload)), -1, 0} {
+			client := New(WithQueryURL(url), WithMaxResponseBytes(limit))
+			if health, err := client.Health(ctx, "query"); err != nil || health.Status != "ok" {
+				t.Fatalf("%s: expected limit %d to allow the response, got %+v: %v", url, limit, health, err)
+			}
+		}
+	}
+	if New().config.MaxResponseBytes != 64<<20 {
+		t.Fatalf("expected a 64 MiB default, got %d", New().config.MaxResponseBytes)
+	}
+}
@@ -3,11 +3,15 @@ package tidepool
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 type requestRecorder struct {
@@ -163,6 +167,158 @@ func TestDefaultNamespaceFallback(t *testing.T) {
 	}
 }
 
+func TestDefaultNamespaceRouting(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{name: "default only", opts: []Option{WithDefaultNamespace("x")}, want: "x"},
+		{name: "deprecated only", opts: []Option{WithNamespace("legacy")}, want: "legacy"},
+		{name: "default after deprecated", opts: []Option{WithNamespace("legacy"), WithDefaultNamespace("x")}, want: "x"},
+		{name: "deprecated after default", opts: []Option{WithDefaultNamespace("x"), WithNamespace("legacy")}, want: "legacy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ingestRecorder := &requestRecorder{}
+			queryRecorder := &requestRecorder{}
+			ingestServer := newIngestServer(ingestRecorder)
+			queryServer := newQueryServer(queryRecorder)
+			defer ingestServer.Close()
+			defer queryServer.Close()
+
+			client := New(append([]Option{WithIngestURL(ingestServer.URL), WithQueryURL(queryServer.URL)}, tt.opts...)...)
+			docs := []Document{{ID: "doc-1", Vector: Vector{0.1, 0.2, 0.3}}}
+			if err := client.Upsert(ctx, docs, nil); err != nil {
+				t.Fatalf("upsert failed: %v", err)
+			}
+			if _, err := client.Query(ctx, Vector{0.1, 0.2, 0.3}, nil); err != nil {
+				t.Fatalf("query failed: %v", err)
+			}
+			path := "/v1/vectors/" + tt.want
+			if !ingestRecorder.contains(path) || !queryRecorder.contains(path) {
+				t.Fatalf("expected upsert and query to %s", path)
+			}
+		})
+	}
+}
+
+func TestNamespaceHeader(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		seen = map[string]string{}
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		seen[req.Method+" "+req.URL.Path] = req.Header.Get("X-Namespace")
+		mu.Unlock()
+		if req.URL.Path == "/v1/namespaces" {
+			_ = json.NewEncoder(w).Encode(map[string]any{"namespaces": []string{}})
+			return
+		}
+		if req.Method == http.MethodPost && strings.HasPrefix(req.URL.Path, "/v1/vectors/") {
+			_ = json.NewEncoder(w).Encode(map[string]any{"results": []any{}})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	client := New(WithBaseURL(srv.URL), WithDefaultNamespace("tenant-a"), WithNamespaceHeader("X-Namespace"))
+	if err := client.Upsert(ctx, []Document{{ID: "a", Vector: Vector{1}}}, &UpsertOptions{Namespace: "tenant-b"}); err != nil {
+		t.Fatalf("upsert failed: %v", err)
+	}
+	if _, err := client.Query(ctx, Vector{1}, nil); err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if err := client.Delete(ctx, []string{"a"}, &DeleteOptions{Namespace: "tenant-c"}); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if _, err := client.ListNamespaces(ctx); err != nil {
+		t.Fatalf("list namespaces failed: %v", err)
+	}
+
+	want := map[string]string{
+		"POST /v1/vectors/tenant-b":   "tenant-b",
+		"POST /v1/vectors/tenant-a":   "tenant-a",
+		"DELETE /v1/vectors/tenant-c": "tenant-c",
+		"GET /v1/namespaces":          "",
+	}
+	for request, header := range want {
+		got, ok := seen[request]
+		if !ok {
+			t.Fatalf("expected request %s, got %v", request, seen)
+		}
+		if got != header {
+			t.Fatalf("%s: expected X-Namespace %q, got %q", request, header, got)
+		}
+	}
+
+	for path, want := range map[string]string{
+		"/gateway/v1/v1/vectors/v1/batch": "v1",
+		"/v1/namespaces/a%3Ab/status":     "a:b",
+		"/v1/namespaces":                  "",
+		"/health":                         "",
+	} {
+		u, _ := url.Parse("http://host" + path)
+		if got, _ := pathNamespace(u); got != want {
+			t.Fatalf("%s: expected namespace %q, got %q", path, want, got)
+		}
+	}
+}
+
+func TestPathPrefix(t *testing.T) {
+	recorder := &requestRecorder{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		recorder.record(req.URL.Path)
+		_, _ = w.Write([]byte(`{"results":[],"namespaces":[]}`))
+	}))
+	defer srv.Close()
+
+	for _, prefix := range []string{"tidepool", "/tidepool/", "//tidepool//"} {
+		client := New(WithBaseURL(srv.URL+"/"), WithDefaultNamespace("ns"), WithPathPrefix(prefix))
+		ingest, err := client.ingestVectorsEndpoint("ns")
+		if err != nil || ingest != srv.URL+"/tidepool/v1/vectors/ns" {
+			t.Fatalf("prefix %q: unexpected ingest endpoint %q, %v", prefix, ingest, err)
+		}
+		query, err := client.queryVectorsEndpoint("ns")
+		if err != nil || query != srv.URL+"/tidepool/v1/vectors/ns" {
+			t.Fatalf("prefix %q: unexpected query endpoint %q, %v", prefix, query, err)
+		}
+	}
+
+	nested := New(WithQueryURL(srv.URL+"/gateway"), WithIngestURL(srv.URL), WithPathPrefix("/api//tidepool/"))
+	if got, _ := nested.queryVectorsEndpoint("ns"); got != srv.URL+"/gateway/api/tidepool/v1/vectors/ns" {
+		t.Fatalf("expected prefix after the base path, got %q", got)
+	}
+	if got, _ := New(WithBaseURL(srv.URL)).queryVectorsEndpoint("ns"); got != srv.URL+"/v1/vectors/ns" {
+		t.Fatalf("expected no prefix by default, got %q", got)
+	}
+
+	ctx := context.Background()
+	client := New(WithBaseURL(srv.URL), WithDefaultNamespace("ns"), WithPathPrefix("tidepool"))
+	calls := map[string]func() error{
+		"/tidepool/health":                   func() error { _, err := client.Health(ctx, "query"); return err },
+		"/tidepool/v1/vectors/ns":            func() error { return client.Upsert(ctx, []Document{{ID: "a", Vector: Vector{1}}}, nil) },
+		"/tidepool/v1/namespaces":            func() error { _, err := client.ListNamespaces(ctx); return err },
+		"/tidepool/v1/namespaces/ns":         func() error { _, err := client.GetNamespace(ctx, "ns"); return err },
+		"/tidepool/status":                   func() error { _, err := client.Status(ctx); return err },
+		"/tidepool/v1/namespaces/ns/status":  func() error { _, err := client.GetNamespaceStatus(ctx, "ns"); return err },
+		"/tidepool/v1/namespaces/ns/compact": func() error { return client.Compact(ctx, "ns") },
+		"/tidepool/v1/namespaces/ns/flush":   func() error { return client.Flush(ctx, "ns") },
+	}
+	for path, call := range calls {
+		if err := call(); err != nil {
+			t.Fatalf("%s: request failed: %v", path, err)
+		}
+		if !recorder.contains(path) {
+			t.Fatalf("expected a request to %s, got %v", path, recorder.paths)
+		}
+	}
+}
+
 func TestNamespaceStatusAndCompact(t *testing.T) {
 	ctx := context.Background()
 	ingestRecorder := &requestRecorder{}
@@ -182,8 +338,11 @@ func TestNamespaceStatusAndCompact(t *testing.T) {
 	if err != nil {
 		t.Fatalf("get namespace status failed: %v", err)
 	}
-	if status.WALEntries != 2 {
-		t.Fatalf("expected wal entries 2, got %d", status.WALEntries)
+	if status.WALEntries != 2 || !status.Pending() {
+		t.Fatalf("expected 2 pending wal entries, got %d", status.WALEntries)
+	}
+	if (CompactionStatus{}).Pending() {
+		t.Fatalf("expected empty status not to be pending")
 	}
 
 	if err := client.Compact(ctx, "products"); err != nil {
@@ -198,6 +357,68 @@ func TestNamespaceStatusAndCompact(t *testing.T) {
 	}
 }
 
+func TestCompactAndWait(t *testing.T) {
+	var (
+		mu        sync.Mutex
+		compacted bool
+		remaining = []int{5, 2, 0}
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/v1/namespaces/big/compact":
+			compacted = true
+			w.WriteHeader(http.StatusAccepted)
+		case "/v1/namespaces/big/status":
+			if !compacted {
+				t.Errorf("expected compaction to be triggered before polling")
+			}
+			entries := remaining[0]
+			if len(remaining) > 1 {
+				remaining = remaining[1:]
+			}
+			_ = json.NewEncoder(w).Encode(CompactionStatus{WALEntries: entries, Segments: 1})
+		case "/v1/namespaces/stuck/compact":
+			w.WriteHeader(http.StatusAccepted)
+		case "/v1/namespaces/stuck/status":
+			_ = json.NewEncoder(w).Encode(CompactionStatus{WALEntries: 7})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	client := New(WithIngestURL(srv.URL))
+	var seen []int
+	status, err := client.CompactAndWait(ctx, "big", CompactWaitOptions{
+		PollInterval: time.Millisecond,
+		OnStatus:     func(s *CompactionStatus) { seen = append(seen, s.WALEntries) },
+	})
+	if err != nil {
+		t.Fatalf("compact and wait failed: %v", err)
+	}
+	if status.Pending() || status.Segments != 1 {
+		t.Fatalf("expected final compacted status, got %+v", status)
+	}
+	if fmt.Sprint(seen) != "[5 2 0]" {
+		t.Fatalf("expected every polled status to be reported, got %v", seen)
+	}
+
+	status, err = client.CompactAndWait(ctx, "stuck", CompactWaitOptions{PollInterval: time.Millisecond, Timeout: 20 * time.Millisecond})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if status == nil || status.WALEntries != 7 {
+		t.Fatalf("expected last status with the error, got %+v", status)
+	}
+
+	if _, err := client.CompactAndWait(ctx, "missing", CompactWaitOptions{}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected not found from the compaction request, got %v", err)
+	}
+}
+
 func TestCrossNamespaceIsolation(t *testing.T) {
 	ctx := context.Background()
 	ingestRecorder := &requestRecorder{}
@@ -258,6 +479,9 @@ func TestListNamespacesReturnsInfo(t *testing.T) {
 	if infos[0].PendingCompaction == nil || *infos[0].PendingCompaction != true {
 		t.Fatalf("expected pending_compaction true, got %+v", infos[0].PendingCompaction)
 	}
+	if names := infos.Names(); len(names) != 2 || names[0] != "default" {
+		t.Fatalf("unexpected names %v", names)
+	}
 }
 
 func TestTextOnlyQuery(t *testing.T) {
@@ -289,3 +513,680 @@ func TestTextOnlyQuery(t *testing.T) {
 		t.Fatalf("expected text query against default namespace")
 	}
 }
+
+func TestFetchByID(t *testing.T) {
+	ctx := context.Background()
+	var rawQuery string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet || req.URL.Path != "/v1/vectors/products" {
+			t.Fatalf("unexpected request %s %s", req.Method, req.URL.Path)
+		}
+		rawQuery = req.URL.RawQuery
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"vectors": []VectorResult{
+				{ID: "a", Vector: Vector{0.1, 0.2}, Attributes: Attributes{"tag": "x"}},
+			},
+		})
+	}))
+	defer srv.Close()
+
+	client := New(WithQueryURL(srv.URL), WithDefaultNamespace("default"))
+
+	results, err := client.Fetch(ctx, []string{"a"}, &FetchOptions{Namespace: "products"})
+	if err != nil {
+		t.Fatalf("fetch failed: %v", err)
+	}
+	if rawQuery != "ids=a" {
+		t.Fatalf("expected ids query parameter, got %q", rawQuery)
+	}
+	if len(results) != 1 || results[0].ID != "a" || len(results[0].Vector) != 2 {
+		t.Fatalf("unexpected fetch results: %+v", results)
+	}
+
+	results, err = client.Fetch(ctx, []string{"a", "b", "c"}, &FetchOptions{Namespace: "products"})
+	if !IsNotFoundError(err) {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "b, c") {
+		t.Fatalf("expected missing ids in error, got %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected found results to be returned, got %+v", results)
+	}
+
+	if _, err := client.Fetch(ctx, nil, nil); !IsValidationError(err) {
+		t.Fatalf("expected validation error for empty ids, got %v", err)
+	}
+}
+
+func TestCount(t *testing.T) {
+	ctx := context.Background()
+	var captured map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost || req.URL.Path != "/v1/vectors/tenant_a/count" {
+			t.Fatalf("unexpected request %s %s", req.Method, req.URL.Path)
+		}
+		captured = nil
+		if err := json.NewDecoder(req.Body).Decode(&captured); err != nil {
+			t.Fatalf("decode body: %v", err)
+		}
+		count := 42
+		if captured["filters"] != nil {
+			count = 7
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"count": count})
+	}))
+	defer srv.Close()
+
+	client := New(WithQueryURL(srv.URL), WithDefaultNamespace("tenant_a"))
+
+	total, err := client.Count(ctx, "", nil)
+	if err != nil {
+		t.Fatalf("count failed: %v", err)
+	}
+	if total != 42 {
+		t.Fatalf("expected total 42, got %d", total)
+	}
+	if _, ok := captured["filters"]; ok {
+		t.Fatalf("expected filters omitted for total count")
+	}
+
+	filtered, err := client.Count(ctx, "tenant_a", Attributes{"tag": "a"})
+	if err != nil {
+		t.Fatalf("filtered count failed: %v", err)
+	}
+	if filtered != 7 {
+		t.Fatalf("expected filtered count 7, got %d", filtered)
+	}
+}
+
+func TestDeleteByFilter(t *testing.T) {
+	ctx := context.Background()
+	var captured map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodDelete || req.URL.Path != "/v1/vectors/products" {
+			t.Fatalf("unexpected request %s %s", req.Method, req.URL.Path)
+		}
+		captured = nil
+		if err := json.NewDecoder(req.Body).Decode(&captured); err != nil {
+			t.Fatalf("decode body: %v", err)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"deleted": 3})
+	}))
+	defer srv.Close()
+
+	client := New(WithIngestURL(srv.URL), WithDefaultNamespace("default"))
+
+	deleted, err := client.DeleteByFilter(ctx, Attributes{"tenant": "x"}, &DeleteOptions{Namespace: "products"})
+	if err != nil {
+		t.Fatalf("delete by filter failed: %v", err)
+	}
+	if deleted != 3 {
+		t.Fatalf("expected 3 deleted, got %d", deleted)
+	}
+	filters, _ := captured["filters"].(map[string]any)
+	if filters["tenant"] != "x" {
+		t.Fatalf("unexpected filters payload: %+v", captured)
+	}
+
+	if _, err := client.DeleteByFilter(ctx, nil, &DeleteOptions{Namespace: "products"}); !IsValidationError(err) {
+		t.Fatalf("expected validation error for empty filters, got %v", err)
+	}
+
+	if _, err := client.DeleteByFilter(ctx, nil, &DeleteOptions{Namespace: "products", AllowDeleteAll: true}); err != nil {
+		t.Fatalf("expected delete all to be allowed, got %v", err)
+	}
+	if filters, ok := captured["filters"].(map[string]any); !ok || len(filters) != 0 {
+		t.Fatalf("expected empty filters object for delete all, got %+v", captured)
+	}
+}
+
+func TestUpdateMetadata(t *testing.T) {
+	ctx := context.Background()
+	var (
+		method   string
+		path     string
+		captured map[string]any
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		method = req.Method
+		path = req.URL.Path
+		captured = nil
+		if err := json.NewDecoder(req.Body).Decode(&captured); err != nil {
+			t.Fatalf("decode body: %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	client := New(WithIngestURL(srv.URL), WithDefaultNamespace("default"))
+
+	if err := client.UpdateMetadata(ctx, "doc-1", Attributes{"tag": "b"}, &UpsertOptions{Namespace: "products"}); err != nil {
+		t.Fatalf("update metadata failed: %v", err)
+	}
+	if method != http.MethodPatch || path != "/v1/vectors/products/doc-1" {
+		t.Fatalf("unexpected request %s %s", method, path)
+	}
+	if _, ok := captured["vector"]; ok {
+		t.Fatalf("expected vector to be omitted")
+	}
+	attrs, _ := captured["attributes"].(map[string]any)
+	if attrs["tag"] != "b" {
+		t.Fatalf("unexpected attributes payload: %+v", captured)
+	}
+
+	if err := client.UpdateMetadata(ctx, "doc-1", nil, nil); !IsValidationError(err) {
+		t.Fatalf("expected validation error for empty attributes, got %v", err)
+	}
+	if err := client.UpdateMetadata(ctx, "", Attributes{"tag": "b"}, nil); !IsValidationError(err) {
+		t.Fatalf("expected validation error for empty id, got %v", err)
+	}
+
+	err := client.UpdateMetadataBatch(ctx, map[string]Attributes{
+		"doc-2": {"tag": "c"},
+		"doc-1": {"tag": "b"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("batch update metadata failed: %v", err)
+	}
+	if method != http.MethodPatch || path != "/v1/vectors/default" {
+		t.Fatalf("unexpected batch request %s %s", method, path)
+	}
+	updates, _ := captured["updates"].([]any)
+	if len(updates) != 2 || updates[0].(map[string]any)["id"] != "doc-1" {
+		t.Fatalf("expected sorted updates, got %+v", captured)
+	}
+}
+
+func TestDimensionCheck(t *testing.T) {
+	ctx := context.Background()
+	ingestRecorder := &requestRecorder{}
+	queryRecorder := &requestRecorder{}
+	ingestServer := newIngestServer(ingestRecorder)
+	queryServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		queryRecorder.record(req.URL.Path)
+		switch req.URL.Path {
+		case "/v1/namespaces/products":
+			_ = json.NewEncoder(w).Encode(NamespaceInfo{Namespace: "products", Dimensions: 3})
+		case "/v1/namespaces/fresh":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			_ = json.NewEncoder(w).Encode([]VectorResult{{ID: "a"}})
+		}
+	}))
+	defer ingestServer.Close()
+	defer queryServer.Close()
+
+	client := New(
+		WithIngestURL(ingestServer.URL),
+		WithQueryURL(queryServer.URL),
+		WithDimensionCheck(true),
+	)
+
+	docs := []Document{{ID: "doc-1", Vector: Vector{0.1, 0.2}}}
+	if err := client.Upsert(ctx, docs, &UpsertOptions{Namespace: "products"}); !IsValidationError(err) {
+		t.Fatalf("expected dimension validation error, got %v", err)
+	}
+	if ingestRecorder.contains("/v1/vectors/products") {
+		t.Fatalf("expected upsert to fail before the network call")
+	}
+
+	if _, err := client.Query(ctx, Vector{0.1, 0.2, 0.3}, &QueryOptions{Namespace: "products"}); err != nil {
+		t.Fatalf("query with matching dimensions failed: %v", err)
+	}
+	if _, err := client.Query(ctx, Vector{0.1}, &QueryOptions{Namespace: "products"}); !IsValidationError(err) {
+		t.Fatalf("expected dimension validation error for query, got %v", err)
+	}
+	if queryRecorder.count("/v1/namespaces/products") != 1 {
+		t.Fatalf("expected namespace dimensions to be cached, got %d lookups", queryRecorder.count("/v1/namespaces/products"))
+	}
+
+	fresh := make([]Document, 5)
+	for i := range fresh {
+		fresh[i] = Document{ID: fmt.Sprint(i), Vector: Vector{0.1, 0.2}}
+	}
+	if err := client.Upsert(ctx, fresh, &UpsertOptions{Namespace: "fresh"}); err != nil {
+		t.Fatalf("expected upsert to unknown namespace to skip the check, got %v", err)
+	}
+	if n := queryRecorder.count("/v1/namespaces/fresh"); n != 1 {
+		t.Fatalf("expected one dimension lookup for the whole upsert, got %d", n)
+	}
+	stream := make(chan Document, len(fresh))
+	for _, doc := range fresh {
+		stream <- doc
+	}
+	close(stream)
+	if err := client.UpsertStream(ctx, stream, &UpsertOptions{Namespace: "fresh", BatchSize: 2}); err != nil {
+		t.Fatalf("upsert stream failed: %v", err)
+	}
+	if n := queryRecorder.count("/v1/namespaces/fresh"); n != 4 {
+		t.Fatalf("expected one dimension lookup per streamed batch, got %d in total", n)
+	}
+}
+
+func TestWaitReady(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		checks int
+	)
+	ready := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		checks++
+		n := checks
+		mu.Unlock()
+		if n < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(HealthResponse{Service: "query", Status: "ok"})
+	}))
+	defer ready.Close()
+
+	starting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_ = json.NewEncoder(w).Encode(HealthResponse{Service: "ingest", Status: "starting"})
+	}))
+	defer starting.Close()
+
+	client := New(
+		WithQueryURL(ready.URL),
+		WithIngestURL(starting.URL),
+		WithPollInterval(time.Millisecond),
+	)
+
+	if err := client.WaitReady(context.Background(), "query"); err != nil {
+		t.Fatalf("wait ready failed: %v", err)
+	}
+	if checks != 3 {
+		t.Fatalf("expected 3 health checks, got %d", checks)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := client.WaitReady(ctx)
+	if err == nil {
+		t.Fatalf("expected ingest to never become ready")
+	}
+	if !strings.Contains(err.Error(), "ingest service not ready") || strings.Contains(err.Error(), "query service") {
+		t.Fatalf("expected error naming only the ingest service, got %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded in error chain, got %v", err)
+	}
+
+	if err := client.WaitReady(context.Background(), "bogus"); !IsValidationError(err) {
+		t.Fatalf("expected validation error for unknown service, got %v", err)
+	}
+}
+
+func TestPing(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_ = json.NewEncoder(w).Encode(HealthResponse{Status: "ok"})
+	}))
+	defer healthy.Close()
+	starting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_ = json.NewEncoder(w).Encode(HealthResponse{Status: "starting"})
+	}))
+	defer starting.Close()
+	hanging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		<-req.Context().Done()
+	}))
+	defer hanging.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	ctx := context.Background()
+	if err := New(WithQueryURL(healthy.URL), WithIngestURL(healthy.URL)).Ping(ctx); err != nil {
+		t.Fatalf("expected healthy ping, got %v", err)
+	}
+
+	err := New(WithQueryURL(starting.URL), WithIngestURL(starting.URL)).Ping(ctx)
+	if err == nil || !strings.Contains(err.Error(), "query service") || !strings.Contains(err.Error(), "ingest service") {
+		t.Fatalf("expected both services reported, got %v", err)
+	}
+	if !errors.Is(err, ErrServiceUnavailable) {
+		t.Fatalf("expected ErrServiceUnavailable, got %v", err)
+	}
+
+	start := time.Now()
+	err = New(WithQueryURL(hanging.URL), WithIngestURL(down.URL), WithTimeout(10*time.Second)).Ping(ctx)
+	if err == nil || !strings.Contains(err.Error(), "ingest service") || strings.Contains(err.Error(), "query service") {
+		t.Fatalf("expected only the ingest service reported, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("expected ping to return without waiting on the hanging service, took %s", elapsed)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	recorder := &requestRecorder{}
+	flushing := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		recorder.record(req.URL.Path)
+		if strings.HasSuffix(req.URL.Path, "/flush") && !flushing {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	client := New(WithIngestURL(srv.URL), WithDefaultNamespace("products"))
+
+	if err := client.Flush(ctx, ""); err != nil {
+		t.Fatalf("flush failed: %v", err)
+	}
+	if !recorder.contains("/v1/namespaces/products/flush") || recorder.contains("/v1/namespaces/products/compact") {
+		t.Fatalf("expected only the flush endpoint, got %v", recorder.paths)
+	}
+
+	flushing = false
+	if err := client.Flush(ctx, "products"); err != nil {
+		t.Fatalf("flush fallback failed: %v", err)
+	}
+	if !recorder.contains("/v1/namespaces/products/compact") {
+		t.Fatalf("expected fallback to compact, got %v", recorder.paths)
+	}
+}
+
+func TestDescribeNamespace(t *testing.T) {
+	queryServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/v1/namespaces/gone" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(NamespaceInfo{Namespace: "products", ApproxCount: 5, Dimensions: 3})
+	}))
+	defer queryServer.Close()
+	ingestServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/v1/namespaces/unsynced/status" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(CompactionStatus{WALFiles: 1, WALEntries: 2, Segments: 3, TotalVecs: 4, Dimensions: 3})
+	}))
+	defer ingestServer.Close()
+
+	ctx := context.Background()
+	client := New(WithQueryURL(queryServer.URL), WithIngestURL(ingestServer.URL))
+
+	desc, err := client.DescribeNamespace(ctx, "products")
+	if err != nil {
+		t.Fatalf("describe namespace failed: %v", err)
+	}
+	want := NamespaceDescription{
+		Namespace:         "products",
+		ApproxCount:       5,
+		Dimensions:        3,
+		PendingCompaction: true,
+		WALFiles:          1,
+		WALEntries:        2,
+		Segments:          3,
+		TotalVecs:         4,
+	}
+	if *desc != want {
+		t.Fatalf("expected %+v, got %+v", want, *desc)
+	}
+
+	for _, name := range []string{"gone", "unsynced"} {
+		_, err := client.DescribeNamespace(ctx, name)
+		if !IsNotFoundError(err) || !strings.Contains(err.Error(), name) {
+			t.Fatalf("expected not found error naming %s, got %v", name, err)
+		}
+	}
+}
+
+func TestAllNamespaceStatuses(t *testing.T) {
+	names := make([]NamespaceInfo, 20)
+	for i := range names {
+		names[i] = NamespaceInfo{Namespace: fmt.Sprintf("ns-%d", i)}
+	}
+	queryServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"namespaces": names})
+	}))
+	defer queryServer.Close()
+
+	var (
+		mu                  sync.Mutex
+		inFlight, maxFlight int
+	)
+	ingestServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		inFlight++
+		maxFlight = max(maxFlight, inFlight)
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+		}()
+		time.Sleep(5 * time.Millisecond)
+
+		if req.URL.Path == "/v1/namespaces/ns-3/status" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(CompactionStatus{WALEntries: 2, Segments: 1})
+	}))
+	defer ingestServer.Close()
+
+	client := New(WithQueryURL(queryServer.URL), WithIngestURL(ingestServer.URL))
+	statuses, err := client.AllNamespaceStatuses(context.Background())
+	if !IsNotFoundError(err) || !strings.Contains(err.Error(), `namespace "ns-3"`) {
+		t.Fatalf("expected not found error for ns-3, got %v", err)
+	}
+	if len(statuses) != 19 || statuses["ns-3"] != nil || statuses["ns-0"] == nil || !statuses["ns-0"].Pending() {
+		t.Fatalf("expected 19 pending statuses without ns-3, got %v", statuses)
+	}
+	if maxFlight > statusConcurrency {
+		t.Fatalf("expected at most %d concurrent requests, got %d", statusConcurrency, maxFlight)
+	}
+}
+
+func TestCreateAndDeleteNamespace(t *testing.T) {
+	ctx := context.Background()
+	existing := map[string]bool{"taken": true}
+	var captured map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch {
+		case req.Method == http.MethodPost && req.URL.Path == "/v1/namespaces":
+			captured = nil
+			_ = json.NewDecoder(req.Body).Decode(&captured)
+			name, _ := captured["namespace"].(string)
+			if existing[name] {
+				w.WriteHeader(http.StatusConflict)
+				_, _ = w.Write([]byte(`{"error":"namespace already exists"}`))
+				return
+			}
+			existing[name] = true
+			w.WriteHeader(http.StatusCreated)
+		case req.Method == http.MethodDelete && strings.HasPrefix(req.URL.Path, "/v1/namespaces/"):
+			name := strings.TrimPrefix(req.URL.Path, "/v1/namespaces/")
+			if !existing[name] {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(`{"error":"namespace not found"}`))
+				return
+			}
+			delete(existing, name)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Fatalf("unexpected request %s %s", req.Method, req.URL.Path)
+		}
+	}))
+	defer srv.Close()
+
+	client := New(WithIngestURL(srv.URL))
+
+	err := client.CreateNamespace(ctx, "tenant_a", &CreateNamespaceOptions{Dimensions: 768, DistanceMetric: DistanceDotProduct})
+	if err != nil {
+		t.Fatalf("create namespace failed: %v", err)
+	}
+	if captured["dimensions"] != float64(768) || captured["distance_metric"] != string(DistanceDotProduct) {
+		t.Fatalf("unexpected create payload: %+v", captured)
+	}
+
+	err = client.CreateNamespace(ctx, "taken", nil)
+	if !IsConflictError(err) || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("expected conflict error, got %v", err)
+	}
+
+	if err := client.DeleteNamespace(ctx, "tenant_a"); err != nil {
+		t.Fatalf("delete namespace failed: %v", err)
+	}
+	if err := client.DeleteNamespace(ctx, "tenant_a"); !IsNotFoundError(err) {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+	if err := client.CreateNamespace(ctx, "", nil); !IsValidationError(err) {
+		t.Fatalf("expected validation error for empty name, got %v", err)
+	}
+}
+
+func TestListNamespacesPagination(t *testing.T) {
+	pages := map[string]string{
+		"":   `{"namespaces":[{"namespace":"a","approx_count":1},{"namespace":"b"}],"next_cursor":"c1"}`,
+		"c1": `{"namespaces":[{"namespace":"c","dimensions":4}]}`,
+	}
+	var queries []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		queries = append(queries, req.URL.RawQuery)
+		_, _ = w.Write([]byte(pages[req.URL.Query().Get("cursor")]))
+	}))
+	defer srv.Close()
+
+	client := New(WithQueryURL(srv.URL))
+	ctx := context.Background()
+
+	page, next, err := client.ListNamespacesPage(ctx, ListOptions{Limit: 2})
+	if err != nil {
+		t.Fatalf("list page failed: %v", err)
+	}
+	if len(page) != 2 || page[0].ApproxCount != 1 || next != "c1" {
+		t.Fatalf("unexpected first page %+v, next %q", page, next)
+	}
+	if queries[0] != "limit=2" {
+		t.Fatalf("expected limit query param, got %q", queries[0])
+	}
+
+	page, next, err = client.ListNamespacesPage(ctx, ListOptions{Limit: 2, Cursor: next})
+	if err != nil || len(page) != 1 || page[0].Dimensions != 4 || next != "" {
+		t.Fatalf("unexpected last page %+v, next %q: %v", page, next, err)
+	}
+	if queries[1] != "cursor=c1&limit=2" {
+		t.Fatalf("expected cursor and limit query params, got %q", queries[1])
+	}
+
+	all, err := client.ListNamespaces(ctx)
+	if err != nil {
+		t.Fatalf("list namespaces failed: %v", err)
+	}
+	if len(all) != 3 || all[2].Namespace != "c" {
+		t.Fatalf("expected all pages to be collected, got %+v", all)
+	}
+
+	if _, _, err := client.ListNamespacesPage(ctx, ListOptions{Limit: -1}); !IsValidationError(err) {
+		t.Fatalf("expected validation error for negative limit, got %v", err)
+	}
+}
+
+func TestExists(t *testing.T) {
+	var methods []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		methods = append(methods, req.Method+" "+req.URL.Path)
+		switch {
+		case req.Method == http.MethodHead && req.URL.Path == "/v1/vectors/default/present":
+			w.WriteHeader(http.StatusOK)
+		case req.Method == http.MethodHead && req.URL.Path == "/v1/vectors/default/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		case req.Method == http.MethodHead:
+			w.WriteHeader(http.StatusNotFound)
+		case req.Method == http.MethodGet:
+			_ = json.NewEncoder(w).Encode(map[string]any{"results": []VectorResult{{ID: "present"}}})
+		}
+	}))
+	defer srv.Close()
+
+	client := New(WithQueryURL(srv.URL))
+	ctx := context.Background()
+
+	if ok, err := client.Exists(ctx, "present", nil); err != nil || !ok {
+		t.Fatalf("expected present to exist, got %v, %v", ok, err)
+	}
+	if ok, err := client.Exists(ctx, "missing", nil); err != nil || ok {
+		t.Fatalf("expected missing to not exist without error, got %v, %v", ok, err)
+	}
+	if _, err := client.Exists(ctx, "broken", nil); !IsServerError(err) {
+		t.Fatalf("expected server error, got %v", err)
+	}
+	if methods[0] != "HEAD /v1/vectors/default/present" {
+		t.Fatalf("unexpected request %q", methods[0])
+	}
+
+	exists, err := client.ExistsMany(ctx, []string{"present", "missing"}, nil)
+	if err != nil {
+		t.Fatalf("exists many failed: %v", err)
+	}
+	if !exists["present"] || exists["missing"] || len(exists) != 2 {
+		t.Fatalf("unexpected exists map %v", exists)
+	}
+}
+
+func TestFindSimilar(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("server side", func(t *testing.T) {
+		var captured map[string]any
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Path != "/v1/vectors/default/seed/similar" {
+				t.Fatalf("unexpected path %s", req.URL.Path)
+			}
+			_ = json.NewDecoder(req.Body).Decode(&captured)
+			_ = json.NewEncoder(w).Encode([]VectorResult{{ID: "seed"}, {ID: "a"}, {ID: "b"}})
+		}))
+		defer srv.Close()
+
+		client := New(WithQueryURL(srv.URL))
+		results, err := client.FindSimilar(ctx, "seed", &QueryOptions{TopK: 2, Filters: Attributes{"tag": "x"}})
+		if err != nil {
+			t.Fatalf("find similar failed: %v", err)
+		}
+		if len(results) != 2 || results[0].ID != "a" || results[1].ID != "b" {
+			t.Fatalf("expected seed to be excluded, got %+v", results)
+		}
+		if captured["top_k"] != float64(3) {
+			t.Fatalf("expected top_k padded for the seed, got %v", captured["top_k"])
+		}
+		filters, _ := captured["filters"].(map[string]any)
+		if filters["tag"] != "x" {
+			t.Fatalf("expected filters to be forwarded, got %v", captured["filters"])
+		}
+	})
+
+	t.Run("client side fallback", func(t *testing.T) {
+		var queried map[string]any
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			switch {
+			case strings.HasSuffix(req.URL.Path, "/similar"):
+				w.WriteHeader(http.StatusNotFound)
+			case req.Method == http.MethodGet:
+				_ = json.NewEncoder(w).Encode([]VectorResult{{ID: "seed", Vector: Vector{0.5, 0.5}}})
+			default:
+				_ = json.NewDecoder(req.Body).Decode(&queried)
+				_ = json.NewEncoder(w).Encode([]VectorResult{{ID: "a"}, {ID: "seed"}, {ID: "b"}})
+			}
+		}))
+		defer srv.Close()
+
+		client := New(WithQueryURL(srv.URL))
+		results, err := client.FindSimilar(ctx, "seed", &QueryOptions{TopK: 1})
+		if err != nil {
+			t.Fatalf("find similar failed: %v", err)
+		}
+		if len(results) != 1 || results[0].ID != "a" {
+			t.Fatalf("unexpected fallback results %+v", results)
+		}
+		vec, _ := queried["vector"].([]any)
+		if len(vec) != 2 || queried["top_k"] != float64(2) {
+			t.Fatalf("expected query with the seed vector, got %v", queried)
+		}
+	})
+}
@@ -1,11 +1,22 @@
 package tidepool
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
 
 // TidepoolError is the base error type.
 type TidepoolError struct {
 	Message    string
 	StatusCode int
+	// Code is the machine-readable error code from the server, e.g. "DIM_MISMATCH".
+	Code string
+	// Details holds structured error details from the server, if any.
+	Details map[string]any
+	// RetryAfter is the delay requested by the server's Retry-After header,
+	// or zero if none was sent.
+	RetryAfter time.Duration
 	Response   []byte
 }
 
@@ -13,13 +24,70 @@ func (e *TidepoolError) Error() string {
 	return e.Message
 }
 
+// BatchError reports which batch of a batched write failed and how many
+// documents were committed by earlier batches.
+type BatchError struct {
+	BatchIndex int
+	// Committed is the number of documents written when the batch failed.
+	// UpsertConcurrent batches finish out of order, so there it is only a
+	// count of documents written by other batches; use Succeeded to tell
+	// which.
+	Committed int
+	// Succeeded lists, in ascending order, the indices of the batches that
+	// had been written once every in-flight batch finished. Only
+	// UpsertConcurrent sets it. Batch i holds docs[i*size:(i+1)*size] for
+	// the batch size in use, so a caller can resume by resending the rest.
+	Succeeded []int
+	Err       error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("batch %d failed after %d documents committed: %v", e.BatchIndex, e.Committed, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
 // Sentinel errors for type checking.
 var (
 	ErrValidation         = errors.New("validation error")
 	ErrNotFound           = errors.New("not found")
+	ErrConflict           = errors.New("conflict")
 	ErrServiceUnavailable = errors.New("service unavailable")
+	ErrRateLimited        = errors.New("rate limited")
+	ErrServer             = errors.New("server error")
 )
 
+// ErrResponseTooLarge is returned when a response body, after decompression,
+// exceeds the limit set with WithMaxResponseBytes.
+var ErrResponseTooLarge = errors.New("response body too large")
+
+// ErrClientClosed is returned for requests made after Client.Close.
+var ErrClientClosed = errors.New("client is closed")
+
+// ErrDimensionMismatch reports a vector whose dimensions differ from its
+// namespace's, whether detected by the server or by WithDimensionCheck. It
+// wraps ErrValidation; see IsDimensionMismatch for the dimensions involved.
+var ErrDimensionMismatch = fmt.Errorf("%w: dimension mismatch", ErrValidation)
+
+// dimensionMismatchCodes are the server error codes for dimension mismatches.
+var dimensionMismatchCodes = []string{"DIM_MISMATCH", "DIMENSION_MISMATCH"}
+
+// dimensionError is a dimension mismatch detected by the client.
+type dimensionError struct {
+	expected int
+	actual   int
+}
+
+func (e *dimensionError) Error() string {
+	return fmt.Sprintf("%v: expected %d dimensions, got %d", ErrDimensionMismatch, e.expected, e.actual)
+}
+
+func (e *dimensionError) Unwrap() error {
+	return ErrDimensionMismatch
+}
+
 // IsValidationError checks if err is a validation error.
 func IsValidationError(err error) bool {
 	return errors.Is(err, ErrValidation)
@@ -30,7 +98,51 @@ func IsNotFoundError(err error) bool {
 	return errors.Is(err, ErrNotFound)
 }
 
+// IsConflictError checks if err is a conflict (409) error, e.g. creating a
+// namespace that already exists.
+func IsConflictError(err error) bool {
+	return errors.Is(err, ErrConflict)
+}
+
 // IsServiceUnavailableError checks if err is a service unavailable error.
 func IsServiceUnavailableError(err error) bool {
 	return errors.Is(err, ErrServiceUnavailable)
 }
+
+// IsDimensionMismatch checks if err is a dimension mismatch and returns the
+// namespace's expected dimensions and the vector's actual dimensions. For
+// server-side mismatches these come from the error details ("expected" and
+// "got" or "actual") and are zero when the server does not send them.
+func IsDimensionMismatch(err error) (expected, actual int, ok bool) {
+	if !errors.Is(err, ErrDimensionMismatch) {
+		return 0, 0, false
+	}
+	var dimErr *dimensionError
+	if errors.As(err, &dimErr) {
+		return dimErr.expected, dimErr.actual, true
+	}
+	var tideErr *TidepoolError
+	if errors.As(err, &tideErr) {
+		expected = detailInt(tideErr.Details, "expected")
+		actual = detailInt(tideErr.Details, "got")
+		if actual == 0 {
+			actual = detailInt(tideErr.Details, "actual")
+		}
+	}
+	return expected, actual, true
+}
+
+func detailInt(details map[string]any, key string) int {
+	n, _ := toInt64(details[key])
+	return int(n)
+}
+
+// IsRateLimitError checks if err is a rate limit (429) error.
+func IsRateLimitError(err error) bool {
+	return errors.Is(err, ErrRateLimited)
+}
+
+// IsServerError checks if err is a server (5xx other than 503) error.
+func IsServerError(err error) bool {
+	return errors.Is(err, ErrServer)
+}
@@ -0,0 +1,144 @@
+package tidepool
+
+import "fmt"
+
+// FilterBuilder builds QueryOptions.Filters fluently. Each method adds one
+// condition; Build combines them. The JSON shapes emitted are:
+//
+//	Eq(k, v)        {"k": v}
+//	Ne(k, v)        {"k": {"$ne": v}}
+//	In(k, v1, v2)   {"k": {"$in": [v1, v2]}}
+//	Gt/Gte/Lt/Lte   {"k": {"$gt": v}} (and "$gte", "$lt", "$lte")
+//	And(f1, f2)     {"$and": [f1, f2]}
+//	Or(f1, f2)      {"$or": [f1, f2]}
+//
+// A builder with a single condition builds to that condition; multiple
+// conditions are combined with "$and".
+type FilterBuilder struct {
+	conds []Attributes
+	err   error
+}
+
+// NewFilter returns an empty FilterBuilder.
+func NewFilter() *FilterBuilder {
+	return &FilterBuilder{}
+}
+
+// Eq matches documents whose attribute key equals val.
+func (b *FilterBuilder) Eq(key string, val AttrValue) *FilterBuilder {
+	if b.checkKey(key) {
+		b.conds = append(b.conds, Attributes{key: val})
+	}
+	return b
+}
+
+// Ne matches documents whose attribute key does not equal val.
+func (b *FilterBuilder) Ne(key string, val AttrValue) *FilterBuilder {
+	return b.op(key, "$ne", val)
+}
+
+// In matches documents whose attribute key equals any of vals.
+func (b *FilterBuilder) In(key string, vals ...AttrValue) *FilterBuilder {
+	if vals == nil {
+		vals = []AttrValue{}
+	}
+	return b.op(key, "$in", vals)
+}
+
+// Gt matches documents whose attribute key is greater than val.
+func (b *FilterBuilder) Gt(key string, val AttrValue) *FilterBuilder {
+	return b.op(key, "$gt", val)
+}
+
+// Gte matches documents whose attribute key is greater than or equal to val.
+func (b *FilterBuilder) Gte(key string, val AttrValue) *FilterBuilder {
+	return b.op(key, "$gte", val)
+}
+
+// Lt matches documents whose attribute key is less than val.
+func (b *FilterBuilder) Lt(key string, val AttrValue) *FilterBuilder {
+	return b.op(key, "$lt", val)
+}
+
+// Lte matches documents whose attribute key is less than or equal to val.
+func (b *FilterBuilder) Lte(key string, val AttrValue) *FilterBuilder {
+	return b.op(key, "$lte", val)
+}
+
+// And matches documents that satisfy every filter.
+func (b *FilterBuilder) And(filters ...*FilterBuilder) *FilterBuilder {
+	return b.group("$and", filters)
+}
+
+// Or matches documents that satisfy at least one filter.
+func (b *FilterBuilder) Or(filters ...*FilterBuilder) *FilterBuilder {
+	return b.group("$or", filters)
+}
+
+// Build returns the filter as Attributes, or an ErrValidation error if any
+// condition was invalid. An empty builder returns nil filters.
+func (b *FilterBuilder) Build() (Attributes, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	switch len(b.conds) {
+	case 0:
+		return nil, nil
+	case 1:
+		return b.conds[0], nil
+	default:
+		return Attributes{"$and": conditionsToValues(b.conds)}, nil
+	}
+}
+
+func (b *FilterBuilder) op(key, operator string, val AttrValue) *FilterBuilder {
+	if b.checkKey(key) {
+		b.conds = append(b.conds, Attributes{key: Attributes{operator: val}})
+	}
+	return b
+}
+
+func (b *FilterBuilder) group(operator string, filters []*FilterBuilder) *FilterBuilder {
+	if b.err != nil {
+		return b
+	}
+	if len(filters) == 0 {
+		b.err = fmt.Errorf("%w: %s requires at least one filter", ErrValidation, operator)
+		return b
+	}
+	conds := make([]Attributes, 0, len(filters))
+	for _, f := range filters {
+		if f == nil {
+			continue
+		}
+		built, err := f.Build()
+		if err != nil {
+			b.err = err
+			return b
+		}
+		if built != nil {
+			conds = append(conds, built)
+		}
+	}
+	b.conds = append(b.conds, Attributes{operator: conditionsToValues(conds)})
+	return b
+}
+
+func (b *FilterBuilder) checkKey(key string) bool {
+	if b.err != nil {
+		return false
+	}
+	if key == "" {
+		b.err = fmt.Errorf("%w: filter key cannot be empty", ErrValidation)
+		return false
+	}
+	return true
+}
+
+func conditionsToValues(conds []Attributes) []AttrValue {
+	values := make([]AttrValue, len(conds))
+	for i, cond := range conds {
+		values[i] = cond
+	}
+	return values
+}
@@ -0,0 +1,110 @@
+package tidepool
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFilterBuilder(t *testing.T) {
+	cases := []struct {
+		name    string
+		builder *FilterBuilder
+		want    string
+	}{
+		{"empty", NewFilter(), `null`},
+		{"eq", NewFilter().Eq("tag", "a"), `{"tag":"a"}`},
+		{"in", NewFilter().In("tag", "a", "b"), `{"tag":{"$in":["a","b"]}}`},
+		{"range", NewFilter().Gte("price", 10).Lt("price", 20), `{"$and":[{"price":{"$gte":10}},{"price":{"$lt":20}}]}`},
+		{"or", NewFilter().Or(NewFilter().Eq("tag", "a"), NewFilter().Ne("tag", "b")), `{"$or":[{"tag":"a"},{"tag":{"$ne":"b"}}]}`},
+		{"nested", NewFilter().Eq("tenant", "x").And(NewFilter().Gt("score", 1), NewFilter().Lte("score", 5)), `{"$and":[{"tenant":"x"},{"$and":[{"score":{"$gt":1}},{"score":{"$lte":5}}]}]}`},
+	}
+	for _, tc := range cases {
+		filters, err := tc.builder.Build()
+		if err != nil {
+			t.Fatalf("%s: build failed: %v", tc.name, err)
+		}
+		data, err := json.Marshal(filters)
+		if err != nil {
+			t.Fatalf("%s: marshal failed: %v", tc.name, err)
+		}
+		if string(data) != tc.want {
+			t.Fatalf("%s: expected %s, got %s", tc.name, tc.want, data)
+		}
+	}
+}
+
+func TestFilterBuilderValidation(t *testing.T) {
+	if _, err := NewFilter().Eq("", "a").Build(); !IsValidationError(err) {
+		t.Fatalf("expected validation error for empty key, got %v", err)
+	}
+	if _, err := NewFilter().Or(NewFilter().Gte("", 1)).Build(); !IsValidationError(err) {
+		t.Fatalf("expected nested validation error, got %v", err)
+	}
+	if _, err := NewFilter().And().Build(); !IsValidationError(err) {
+		t.Fatalf("expected validation error for empty group, got %v", err)
+	}
+}
+
+func TestAttributeSchemaValidation(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = w.Write([]byte(`{"results":[],"deleted":0,"count":0}`))
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	client := New(
+		WithQueryURL(srv.URL),
+		WithIngestURL(srv.URL),
+		WithAttributeSchema("products", map[string]AttrKind{
+			"category": AttrString,
+			"price":    AttrNumber,
+			"active":   AttrBool,
+			"meta":     AttrAny,
+		}),
+	)
+
+	valid, err := NewFilter().
+		Eq("category", "shoes").
+		In("category", "a", "b").
+		Gte("price", 10).
+		Or(NewFilter().Eq("active", true), NewFilter().Eq("meta", []int{1})).
+		Build()
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	if _, err := client.Query(ctx, Vector{1}, &QueryOptions{Namespace: "products", Filters: valid}); err != nil {
+		t.Fatalf("expected valid filters to pass, got %v", err)
+	}
+	if _, err := client.Query(ctx, Vector{1}, &QueryOptions{Namespace: "products", Filters: Attributes{"category": Attributes{"$in": []string{"a"}}}}); err != nil {
+		t.Fatalf("expected typed $in list to pass, got %v", err)
+	}
+
+	invalid := []Attributes{
+		{"catgory": "shoes"},
+		{"price": "cheap"},
+		{"category": Attributes{"$in": []AttrValue{"a", 1}}},
+		{"$and": []AttrValue{Attributes{"active": "yes"}}},
+		{"$or": "price"},
+	}
+	before := requests
+	for _, filters := range invalid {
+		if _, err := client.Query(ctx, Vector{1}, &QueryOptions{Namespace: "products", Filters: filters}); !IsValidationError(err) {
+			t.Fatalf("expected validation error for %v, got %v", filters, err)
+		}
+		if _, err := client.DeleteByFilter(ctx, filters, &DeleteOptions{Namespace: "products"}); !IsValidationError(err) {
+			t.Fatalf("expected delete validation error for %v, got %v", filters, err)
+		}
+	}
+	if requests != before {
+		t.Fatalf("expected invalid filters to be rejected before sending, got %d requests", requests-before)
+	}
+
+	if _, err := client.Query(ctx, Vector{1}, &QueryOptions{Namespace: "other", Filters: Attributes{"catgory": 1}}); err != nil {
+		t.Fatalf("expected namespaces without a schema to skip validation, got %v", err)
+	}
+}
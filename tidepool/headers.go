@@ -0,0 +1,32 @@
+package tidepool
+
+import (
+	"context"
+	"net/http"
+)
+
+type requestHeadersKey struct{}
+
+// WithRequestHeaders returns a copy of ctx carrying headers to add to every
+// request the client makes with it, such as a tenant ID or trace header.
+// Calling it again on a derived context adds to the headers already present;
+// values for the same header replace earlier ones. Headers the client sets
+// itself on a request (Accept, Accept-Encoding, User-Agent, Content-Type and
+// Content-Encoding for bodies, and configured authentication) take
+// precedence.
+func WithRequestHeaders(ctx context.Context, headers http.Header) context.Context {
+	merged := requestHeaders(ctx).Clone()
+	if merged == nil {
+		merged = make(http.Header, len(headers))
+	}
+	for key, values := range headers {
+		merged[http.CanonicalHeaderKey(key)] = append([]string(nil), values...)
+	}
+	return context.WithValue(ctx, requestHeadersKey{}, merged)
+}
+
+// requestHeaders returns the headers stashed in ctx by WithRequestHeaders.
+func requestHeaders(ctx context.Context) http.Header {
+	headers, _ := ctx.Value(requestHeadersKey{}).(http.Header)
+	return headers
+}
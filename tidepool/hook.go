@@ -0,0 +1,82 @@
+package tidepool
+
+import (
+	"reflect"
+	"slices"
+)
+
+// respond passes a deep copy of v, the successful result of the public
+// method op, to the configured response hook.
+func (c *Client) respond(op string, v any) {
+	if c.config.ResponseHook == nil {
+		return
+	}
+	c.config.ResponseHook(op, deepCopy(reflect.ValueOf(v)).Interface())
+}
+
+// warn passes the non-fatal warnings the server returned to the public
+// method op to the configured warning handler. Nothing is reported when
+// there are none.
+func (c *Client) warn(op string, warnings []string) {
+	if c.config.WarningHandler == nil || len(warnings) == 0 {
+		return
+	}
+	c.config.WarningHandler(op, slices.Clone(warnings))
+}
+
+// deepCopy returns a copy of v that shares no pointers, slices, or maps with
+// it. Unexported struct fields are copied shallowly.
+func deepCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.New(v.Type().Elem())
+		out.Elem().Set(deepCopy(v.Elem()))
+		return out
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.New(v.Type()).Elem()
+		out.Set(deepCopy(v.Elem()))
+		return out
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := range v.Len() {
+			out.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return out
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			out.SetMapIndex(iter.Key(), deepCopy(iter.Value()))
+		}
+		return out
+	case reflect.Struct:
+		out := reflect.New(v.Type()).Elem()
+		out.Set(v)
+		for i := range v.NumField() {
+			if field := out.Field(i); field.CanSet() {
+				field.Set(deepCopy(v.Field(i)))
+			}
+		}
+		return out
+	case reflect.Array:
+		out := reflect.New(v.Type()).Elem()
+		for i := range v.Len() {
+			out.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return out
+	default:
+		return v
+	}
+}
@@ -0,0 +1,75 @@
+package tidepool
+
+import (
+	"fmt"
+	"strings"
+)
+
+// compositeIDSeparator joins the parts of a composite ID. Separators and
+// backslashes inside parts are escaped with a backslash.
+const compositeIDSeparator = ':'
+
+// CompositeID joins parts into a single document ID, e.g.
+// CompositeID("3f2a...", "7") returns "3f2a...:7". Parts may contain any
+// characters; SplitCompositeID recovers them exactly.
+func CompositeID(parts ...string) string {
+	var b strings.Builder
+	for i, part := range parts {
+		if i > 0 {
+			b.WriteByte(compositeIDSeparator)
+		}
+		for _, r := range part {
+			if r == compositeIDSeparator || r == '\\' {
+				b.WriteByte('\\')
+			}
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// SplitCompositeID splits an ID built by CompositeID back into its parts.
+func SplitCompositeID(id string) ([]string, error) {
+	var (
+		parts   []string
+		current strings.Builder
+		escaped bool
+	)
+	for _, r := range id {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == compositeIDSeparator:
+			parts = append(parts, current.String())
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if escaped {
+		return nil, fmt.Errorf("%w: composite id %q ends with an escape", ErrValidation, id)
+	}
+	return append(parts, current.String()), nil
+}
+
+// WithCompositeID returns a copy of d whose ID is CompositeID(parts...).
+func (d Document) WithCompositeID(parts ...string) Document {
+	d.ID = CompositeID(parts...)
+	return d
+}
+
+// checkDuplicateIDs rejects documents that share an ID, which the server
+// would otherwise silently collapse to the last one.
+func checkDuplicateIDs(docs []Document) error {
+	seen := make(map[string]int, len(docs))
+	for i, doc := range docs {
+		if first, ok := seen[doc.ID]; ok {
+			return fmt.Errorf("%w: duplicate document id %q at indexes %d and %d", ErrValidation, doc.ID, first, i)
+		}
+		seen[doc.ID] = i
+	}
+	return nil
+}
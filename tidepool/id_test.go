@@ -0,0 +1,48 @@
+package tidepool
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestCompositeIDRoundTrip(t *testing.T) {
+	cases := [][]string{
+		{"3f2a9c", "7"},
+		{"a:b", `c\d`, ""},
+		{"single"},
+	}
+	for _, parts := range cases {
+		id := CompositeID(parts...)
+		got, err := SplitCompositeID(id)
+		if err != nil {
+			t.Fatalf("split %q failed: %v", id, err)
+		}
+		if strings.Join(got, "|") != strings.Join(parts, "|") || len(got) != len(parts) {
+			t.Fatalf("expected %q, got %q (id %q)", parts, got, id)
+		}
+	}
+
+	if id := CompositeID("3f2a9c", "7"); id != "3f2a9c:7" {
+		t.Fatalf("unexpected composite id %q", id)
+	}
+	doc := Document{Text: "x"}.WithCompositeID("u", "1")
+	if doc.ID != "u:1" || doc.Text != "x" {
+		t.Fatalf("unexpected document %+v", doc)
+	}
+	if _, err := SplitCompositeID(`bad\`); !IsValidationError(err) {
+		t.Fatalf("expected validation error for trailing escape, got %v", err)
+	}
+}
+
+func TestUpsertRejectsDuplicateIDs(t *testing.T) {
+	client := New(WithIngestURL("http://127.0.0.1:0"))
+	err := client.Upsert(context.Background(), []Document{
+		{ID: "a", Vector: Vector{1}},
+		{ID: "b", Vector: Vector{1}},
+		{ID: "a", Vector: Vector{2}},
+	}, nil)
+	if !IsValidationError(err) || !strings.Contains(err.Error(), `"a"`) {
+		t.Fatalf("expected duplicate id validation error, got %v", err)
+	}
+}
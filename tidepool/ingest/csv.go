@@ -0,0 +1,166 @@
+// Package ingest converts tabular embedding exports into Tidepool documents.
+//
+// Only CSV is supported; Parquet would require a third-party dependency and
+// is left to callers.
+package ingest
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/milannair/tidepool-go/tidepool"
+)
+
+// Document is an alias for tidepool.Document.
+type Document = tidepool.Document
+
+// ColumnSpec maps CSV columns, by header name, to Document fields.
+type ColumnSpec struct {
+	// IDColumn names the column holding Document.ID. Required.
+	IDColumn string
+	// TextColumn optionally names the column holding Document.Text.
+	TextColumn string
+	// AttributeColumns name columns copied into Document.Attributes as strings.
+	AttributeColumns []string
+	// VectorStart and VectorEnd select the contiguous range of float columns,
+	// by zero-based index, that form Document.Vector. VectorEnd is exclusive;
+	// zero means through the last column.
+	VectorStart int
+	VectorEnd   int
+}
+
+// ParseCSV reads a CSV with a header row and returns one Document per data
+// row. Vectors are checked with tidepool.ValidateVector; errors name the
+// offending row, counting the header as row 1.
+func ParseCSV(r io.Reader, spec ColumnSpec) ([]Document, error) {
+	var docs []Document
+	err := ParseCSVFunc(r, spec, func(doc Document) error {
+		docs = append(docs, doc)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// ParseCSVFunc is like ParseCSV but calls fn for each row instead of
+// collecting documents, so large files can be streamed (e.g. into a channel
+// for Client.UpsertStream). Parsing stops at the first error returned by fn.
+func ParseCSVFunc(r io.Reader, spec ColumnSpec, fn func(Document) error) error {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("%w: missing header row", tidepool.ErrValidation)
+		}
+		return fmt.Errorf("read header: %w", err)
+	}
+	cols, err := resolveColumns(header, spec)
+	if err != nil {
+		return err
+	}
+
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("row %d: %w", row, err)
+		}
+
+		doc, err := cols.document(record)
+		if err != nil {
+			return fmt.Errorf("row %d: %w", row, err)
+		}
+		if err := fn(doc); err != nil {
+			return err
+		}
+	}
+}
+
+type columns struct {
+	id          int
+	text        int
+	attrs       map[string]int
+	vectorStart int
+	vectorEnd   int
+}
+
+func resolveColumns(header []string, spec ColumnSpec) (*columns, error) {
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[name] = i
+	}
+	lookup := func(name string) (int, error) {
+		i, ok := index[name]
+		if !ok {
+			return 0, fmt.Errorf("%w: column %q not found in header", tidepool.ErrValidation, name)
+		}
+		return i, nil
+	}
+
+	if spec.IDColumn == "" {
+		return nil, fmt.Errorf("%w: id column is required", tidepool.ErrValidation)
+	}
+	cols := &columns{text: -1, attrs: make(map[string]int, len(spec.AttributeColumns))}
+	var err error
+	if cols.id, err = lookup(spec.IDColumn); err != nil {
+		return nil, err
+	}
+	if spec.TextColumn != "" {
+		if cols.text, err = lookup(spec.TextColumn); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range spec.AttributeColumns {
+		if cols.attrs[name], err = lookup(name); err != nil {
+			return nil, err
+		}
+	}
+
+	cols.vectorStart = spec.VectorStart
+	cols.vectorEnd = spec.VectorEnd
+	if cols.vectorEnd == 0 {
+		cols.vectorEnd = len(header)
+	}
+	if cols.vectorStart < 0 || cols.vectorStart >= cols.vectorEnd || cols.vectorEnd > len(header) {
+		return nil, fmt.Errorf("%w: invalid vector column range [%d, %d)", tidepool.ErrValidation, spec.VectorStart, spec.VectorEnd)
+	}
+	return cols, nil
+}
+
+func (c *columns) document(record []string) (Document, error) {
+	doc := Document{ID: record[c.id]}
+	if doc.ID == "" {
+		return Document{}, fmt.Errorf("%w: empty id", tidepool.ErrValidation)
+	}
+	if c.text >= 0 {
+		doc.Text = record[c.text]
+	}
+	if len(c.attrs) > 0 {
+		doc.Attributes = make(tidepool.Attributes, len(c.attrs))
+		for name, i := range c.attrs {
+			doc.Attributes[name] = record[i]
+		}
+	}
+
+	doc.Vector = make(tidepool.Vector, 0, c.vectorEnd-c.vectorStart)
+	for i := c.vectorStart; i < c.vectorEnd; i++ {
+		val, err := strconv.ParseFloat(record[i], 32)
+		if err != nil {
+			return Document{}, fmt.Errorf("%w: column %d: invalid float %q", tidepool.ErrValidation, i, record[i])
+		}
+		doc.Vector = append(doc.Vector, float32(val))
+	}
+	if err := tidepool.ValidateVector(doc.Vector, 0); err != nil {
+		return Document{}, err
+	}
+	return doc, nil
+}
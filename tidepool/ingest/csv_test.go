@@ -0,0 +1,72 @@
+package ingest
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/milannair/tidepool-go/tidepool"
+)
+
+const sample = `id,category,text,v0,v1,v2
+a,news,hello,0.1,0.2,0.3
+b,blog,world,0.4,0.5,0.6
+`
+
+func TestParseCSV(t *testing.T) {
+	docs, err := ParseCSV(strings.NewReader(sample), ColumnSpec{
+		IDColumn:         "id",
+		TextColumn:       "text",
+		AttributeColumns: []string{"category"},
+		VectorStart:      3,
+	})
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if len(docs) != 2 {
+		t.Fatalf("expected 2 documents, got %d", len(docs))
+	}
+	doc := docs[1]
+	if doc.ID != "b" || doc.Text != "world" || doc.Attributes["category"] != "blog" {
+		t.Fatalf("unexpected document: %+v", doc)
+	}
+	if len(doc.Vector) != 3 || doc.Vector[0] != 0.4 || doc.Vector[2] != 0.6 {
+		t.Fatalf("unexpected vector: %v", doc.Vector)
+	}
+}
+
+func TestParseCSVErrors(t *testing.T) {
+	spec := ColumnSpec{IDColumn: "id", VectorStart: 1}
+
+	_, err := ParseCSV(strings.NewReader("id,v0\na,0.1\nb,NaN\n"), spec)
+	if !tidepool.IsValidationError(err) || !strings.Contains(err.Error(), "row 3") {
+		t.Fatalf("expected validation error on row 3, got %v", err)
+	}
+
+	_, err = ParseCSV(strings.NewReader("id,v0\na,abc\n"), spec)
+	if !tidepool.IsValidationError(err) || !strings.Contains(err.Error(), "row 2") {
+		t.Fatalf("expected invalid float error on row 2, got %v", err)
+	}
+
+	if _, err := ParseCSV(strings.NewReader("key,v0\na,0.1\n"), spec); !tidepool.IsValidationError(err) {
+		t.Fatalf("expected missing column error, got %v", err)
+	}
+	if _, err := ParseCSV(strings.NewReader(""), spec); !tidepool.IsValidationError(err) {
+		t.Fatalf("expected missing header error, got %v", err)
+	}
+	if _, err := ParseCSV(strings.NewReader("id,v0\n"), ColumnSpec{IDColumn: "id", VectorStart: 5}); !tidepool.IsValidationError(err) {
+		t.Fatalf("expected invalid range error, got %v", err)
+	}
+}
+
+func TestParseCSVFuncStopsOnCallbackError(t *testing.T) {
+	stop := errors.New("stop")
+	calls := 0
+	err := ParseCSVFunc(strings.NewReader(sample), ColumnSpec{IDColumn: "id", VectorStart: 3}, func(doc Document) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Fatalf("expected callback error after one row, got %v (%d calls)", err, calls)
+	}
+}
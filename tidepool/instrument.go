@@ -0,0 +1,117 @@
+package tidepool
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// OperationInfo describes a completed client method call.
+type OperationInfo struct {
+	// Name is the client method, e.g. "Query" or "Upsert".
+	Name      string
+	Namespace string
+	// TopK is the requested result count for queries.
+	TopK int
+	// ResultCount is the number of results returned by queries and fetches.
+	ResultCount int
+	// StatusCode is the HTTP status of the last request made by the call, or
+	// zero if no response was received.
+	StatusCode int
+	Duration   time.Duration
+	Err        error
+}
+
+// Instrumenter observes client operations. StartOperation is called when a
+// client method begins and may return a derived context (e.g. carrying a
+// span) that is used for the method's requests. The returned function is
+// called once when the method returns.
+//
+// See the tidepoolotel and tidepoolprom subpackages for implementations.
+type Instrumenter interface {
+	StartOperation(ctx context.Context, name string) (context.Context, func(OperationInfo))
+}
+
+type operationKey struct{}
+
+type operation struct {
+	mu       sync.Mutex
+	info     OperationInfo
+	start    time.Time
+	finishes []func(OperationInfo)
+}
+
+func (c *Client) startOperation(ctx context.Context, name string) (context.Context, *operation) {
+	if len(c.config.Instrumenters) == 0 {
+		return ctx, nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	op := &operation{
+		info:  OperationInfo{Name: name},
+		start: time.Now(),
+	}
+	for _, inst := range c.config.Instrumenters {
+		var finish func(OperationInfo)
+		ctx, finish = inst.StartOperation(ctx, name)
+		op.finishes = append(op.finishes, finish)
+	}
+	return context.WithValue(ctx, operationKey{}, op), op
+}
+
+func (op *operation) setNamespace(namespace string) {
+	if op == nil {
+		return
+	}
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	op.info.Namespace = namespace
+}
+
+func (op *operation) setTopK(topK int) {
+	if op == nil {
+		return
+	}
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	op.info.TopK = topK
+}
+
+func (op *operation) setResultCount(n int) {
+	if op == nil {
+		return
+	}
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	op.info.ResultCount = n
+}
+
+func (op *operation) end(err error) {
+	if op == nil {
+		return
+	}
+	op.mu.Lock()
+	op.info.Err = err
+	op.info.Duration = time.Since(op.start)
+	info := op.info
+	op.mu.Unlock()
+
+	for i := len(op.finishes) - 1; i >= 0; i-- {
+		if op.finishes[i] != nil {
+			op.finishes[i](info)
+		}
+	}
+}
+
+// recordStatus stores the HTTP status on the operation in ctx, if any.
+func recordStatus(ctx context.Context, statusCode int) {
+	op, ok := ctx.Value(operationKey{}).(*operation)
+	if !ok {
+		return
+	}
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	op.info.StatusCode = statusCode
+}
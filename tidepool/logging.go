@@ -0,0 +1,60 @@
+package tidepool
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// logBodyLimit caps the request/response bytes captured in RequestInfo.
+const logBodyLimit = 4096
+
+const redacted = "REDACTED"
+
+// RequestInfo describes a completed HTTP request for logging.
+type RequestInfo struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Duration   time.Duration
+	// Header holds the request headers with credentials redacted.
+	Header http.Header
+	// RequestBody and ResponseBody are captured, truncated to 4 KiB, only when
+	// body logging is enabled with WithLogBodies.
+	RequestBody  []byte
+	ResponseBody []byte
+	// Err is the error returned to the caller, if any.
+	Err error
+}
+
+func (c *Client) logRequest(ctx context.Context, req *http.Request, reqBody, respBody []byte, statusCode int, duration time.Duration, err error) {
+	header := req.Header.Clone()
+	if header.Get("Authorization") != "" {
+		header.Set("Authorization", redacted)
+	}
+	if c.config.AuthHeader != "" && header.Get(c.config.AuthHeader) != "" {
+		header.Set(c.config.AuthHeader, redacted)
+	}
+
+	info := RequestInfo{
+		Method:     req.Method,
+		URL:        req.URL.String(),
+		StatusCode: statusCode,
+		Duration:   duration,
+		Header:     header,
+		Err:        err,
+	}
+	if c.config.LogBodies {
+		info.RequestBody = truncateBody(reqBody)
+		info.ResponseBody = truncateBody(respBody)
+	}
+
+	c.config.Logger(ctx, info)
+}
+
+func truncateBody(body []byte) []byte {
+	if len(body) > logBodyLimit {
+		body = body[:logBodyLimit]
+	}
+	return append([]byte(nil), body...)
+}
@@ -0,0 +1,142 @@
+package tidepool
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+)
+
+// MigrateOptions configures Migrate.
+type MigrateOptions struct {
+	// DestNamespace is the namespace written in the destination. Empty uses
+	// the source namespace.
+	DestNamespace string
+	// PageSize is the number of documents read from the source per request.
+	// Zero uses the server default.
+	PageSize int
+	// BatchSize is the number of documents per upsert request to the
+	// destination. Zero uses the destination client's batch size.
+	BatchSize int
+	// Concurrency is the number of upsert batches in flight. Zero means one.
+	Concurrency int
+	// DistanceMetric is sent with every upsert, so a destination namespace
+	// with a different metric rejects the copy. Empty uses the destination
+	// client's default.
+	DistanceMetric DistanceMetric
+	// CheckDimensions compares the dimensions of the source and destination
+	// namespaces before copying and fails with ErrDimensionMismatch when both
+	// are known and differ. A destination namespace that does not exist yet
+	// passes the check.
+	CheckDimensions bool
+	// Cursor resumes a migration after the page it ends, as reported to
+	// OnCheckpoint or in the error of a failed migration. Empty starts from
+	// the beginning.
+	Cursor string
+	// OnCheckpoint, when set, is called after each page is written to the
+	// destination with the number of documents copied so far and the cursor
+	// to resume from, which is empty after the last page.
+	OnCheckpoint func(copied int64, cursor string)
+	// OnSkip, when set, is called with the ID of each source document that
+	// is not copied because the source returned no vector, sparse vector, or
+	// text for it.
+	OnSkip func(id string)
+}
+
+// Migrate copies every document of namespace from src to dst, preserving
+// IDs, vectors, sparse vectors, text, and attributes. Documents are read a
+// page at a time as by Scroll, and each page is upserted into dst with
+// UpsertConcurrent before the next is read. A document the source returns
+// without a vector, sparse vector, or text cannot be written and is skipped
+// and reported to MigrateOptions.OnSkip. copied counts the documents written
+// from fully written pages. On failure the error names the cursor to pass as
+// MigrateOptions.Cursor to resume; documents of a page that failed partway
+// are written again, which is harmless because upserts overwrite by ID.
+func Migrate(ctx context.Context, src, dst *Client, namespace string, opts MigrateOptions) (copied int64, err error) {
+	if src == nil || dst == nil {
+		return 0, fmt.Errorf("%w: source and destination clients are required", ErrValidation)
+	}
+	if opts.PageSize < 0 || opts.BatchSize < 0 || opts.Concurrency < 0 {
+		return 0, fmt.Errorf("%w: page size, batch size, and concurrency must not be negative", ErrValidation)
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	srcNamespace, err := src.namespaceOrDefault(namespace)
+	if err != nil {
+		return 0, err
+	}
+	dstNamespace, err := dst.namespaceOrDefault(cmp.Or(opts.DestNamespace, srcNamespace))
+	if err != nil {
+		return 0, err
+	}
+
+	if opts.CheckDimensions {
+		if err := checkMigrateDimensions(ctx, src, dst, srcNamespace, dstNamespace); err != nil {
+			return 0, err
+		}
+	}
+
+	upsertOpts := &UpsertOptions{
+		Namespace:      dstNamespace,
+		DistanceMetric: opts.DistanceMetric,
+		BatchSize:      opts.BatchSize,
+	}
+	concurrency := max(opts.Concurrency, 1)
+	cursor := opts.Cursor
+	for {
+		docs, next, err := src.scrollPage(ctx, srcNamespace, opts.PageSize, cursor)
+		if err != nil {
+			return copied, migrateError(copied, cursor, err)
+		}
+		docs = slices.DeleteFunc(docs, func(doc Document) bool {
+			if len(doc.Vector) > 0 || doc.SparseVector != nil || doc.Text != "" {
+				return false
+			}
+			if opts.OnSkip != nil {
+				opts.OnSkip(doc.ID)
+			}
+			return true
+		})
+		if len(docs) > 0 {
+			if err := dst.UpsertConcurrent(ctx, docs, upsertOpts, concurrency); err != nil {
+				return copied, migrateError(copied, cursor, err)
+			}
+			copied += int64(len(docs))
+		}
+		if next != "" && next == cursor {
+			return copied, fmt.Errorf("migrate: source repeated cursor %q", next)
+		}
+		cursor = next
+		if opts.OnCheckpoint != nil {
+			opts.OnCheckpoint(copied, cursor)
+		}
+		if cursor == "" {
+			return copied, nil
+		}
+	}
+}
+
+func migrateError(copied int64, cursor string, err error) error {
+	return fmt.Errorf("migrate: %d documents copied, resume from cursor %q: %w", copied, cursor, err)
+}
+
+// checkMigrateDimensions fails when the source and an existing destination
+// namespace have different known dimensions.
+func checkMigrateDimensions(ctx context.Context, src, dst *Client, srcNamespace, dstNamespace string) error {
+	srcInfo, err := src.GetNamespace(ctx, srcNamespace)
+	if err != nil {
+		return fmt.Errorf("migrate: source namespace: %w", err)
+	}
+	dstInfo, err := dst.GetNamespace(ctx, dstNamespace)
+	if IsNotFoundError(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("migrate: destination namespace: %w", err)
+	}
+	if srcInfo.Dimensions > 0 && dstInfo.Dimensions > 0 && srcInfo.Dimensions != dstInfo.Dimensions {
+		return fmt.Errorf("migrate: %w", &dimensionError{expected: dstInfo.Dimensions, actual: srcInfo.Dimensions})
+	}
+	return nil
+}
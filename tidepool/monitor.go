@@ -0,0 +1,120 @@
+package tidepool
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// defaultHealthMonitorInterval is the StartHealthMonitor interval used when
+// none is given.
+const defaultHealthMonitorInterval = 30 * time.Second
+
+// HealthEvent reports a change in a service's health, as seen by
+// StartHealthMonitor.
+type HealthEvent struct {
+	// Service is "query" or "ingest".
+	Service string
+	// Healthy reports whether the service answered its health check with a
+	// healthy status.
+	Healthy bool
+	// Err is why the service is unhealthy: the request error, or an
+	// ErrServiceUnavailable error carrying the reported status. It is nil
+	// when Healthy is true.
+	Err error
+	// Time is when the check completed.
+	Time time.Time
+}
+
+// StartHealthMonitor checks the health of the query and ingest services in a
+// background goroutine and sends an event on the returned channel whenever a
+// service goes from healthy to unhealthy or back. The first check runs
+// immediately and reports the initial state of both services. Checks repeat
+// every interval, jittered by up to ±10% so that clients started together do
+// not ping in lockstep; a non-positive interval uses 30 seconds. Checks go
+// through Health, so instrumenters and the response hook see them.
+//
+// The goroutine stops and the channel is closed when ctx is canceled or the
+// client is closed. Events are never dropped, so a consumer that stops
+// reading pauses the monitor; keep reading until the channel is closed.
+func (c *Client) StartHealthMonitor(ctx context.Context, interval time.Duration) <-chan HealthEvent {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if interval <= 0 {
+		interval = defaultHealthMonitorInterval
+	}
+	services := []string{"query", "ingest"}
+	events := make(chan HealthEvent, len(services))
+	go c.monitorHealth(ctx, services, interval, events)
+	return events
+}
+
+// monitorHealth runs the StartHealthMonitor loop and closes events when it
+// stops.
+func (c *Client) monitorHealth(ctx context.Context, services []string, interval time.Duration, events chan<- HealthEvent) {
+	defer close(events)
+
+	healthy := make(map[string]bool, len(services))
+	for {
+		for _, event := range c.checkHealth(ctx, services) {
+			// A check cut short by cancellation or Close says nothing
+			// about the service.
+			if ctx.Err() != nil || c.closed.Load() {
+				return
+			}
+			if last, ok := healthy[event.Service]; ok && last == event.Healthy {
+				continue
+			}
+			healthy[event.Service] = event.Healthy
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			case <-c.done:
+				return
+			}
+		}
+
+		timer := time.NewTimer(time.Duration(float64(interval) * (0.9 + 0.2*rand.Float64())))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-c.done:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// checkHealth checks services concurrently and returns one event per
+// service, in order.
+func (c *Client) checkHealth(ctx context.Context, services []string) []HealthEvent {
+	events := make([]HealthEvent, len(services))
+	var wg sync.WaitGroup
+	for i, service := range services {
+		wg.Add(1)
+		go func(i int, service string) {
+			defer wg.Done()
+
+			event := HealthEvent{Service: service}
+			resp, err := c.Health(ctx, service)
+			switch {
+			case err != nil:
+				event.Err = err
+			case !isHealthy(resp.Status):
+				event.Err = fmt.Errorf("%w: status %q", ErrServiceUnavailable, resp.Status)
+			default:
+				event.Healthy = true
+			}
+			event.Time = time.Now()
+			events[i] = event
+		}(i, service)
+	}
+	wg.Wait()
+	return events
+}
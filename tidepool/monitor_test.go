@@ -0,0 +1,99 @@
+package tidepool
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStartHealthMonitor(t *testing.T) {
+	var queryDown atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host == "query.test" && queryDown.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer srv.Close()
+
+	// Route both services to srv, telling them apart by Host.
+	transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		req = req.Clone(req.Context())
+		req.Host = req.URL.Host
+		req.URL.Host = srv.Listener.Addr().String()
+		return http.DefaultTransport.RoundTrip(req)
+	})
+	client := New(
+		WithQueryURL("http://query.test"),
+		WithIngestURL("http://ingest.test"),
+		WithHTTPClient(&http.Client{Transport: transport}),
+	)
+
+	next := func(events <-chan HealthEvent) HealthEvent {
+		t.Helper()
+		select {
+		case event, ok := <-events:
+			if !ok {
+				t.Fatalf("expected an event, got a closed channel")
+			}
+			return event
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for a health event")
+		}
+		return HealthEvent{}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := client.StartHealthMonitor(ctx, 10*time.Millisecond)
+
+	initial := map[string]bool{}
+	for range 2 {
+		event := next(events)
+		initial[event.Service] = event.Healthy
+	}
+	if !initial["query"] || !initial["ingest"] {
+		t.Fatalf("expected both services to start healthy, got %v", initial)
+	}
+
+	queryDown.Store(true)
+	down := next(events)
+	if down.Service != "query" || down.Healthy || !errors.Is(down.Err, ErrServiceUnavailable) || down.Time.IsZero() {
+		t.Fatalf("expected query to go unhealthy, got %+v", down)
+	}
+	queryDown.Store(false)
+	if up := next(events); up.Service != "query" || !up.Healthy || up.Err != nil {
+		t.Fatalf("expected query to recover, got %+v", up)
+	}
+
+	cancel()
+	waitClosed(t, events)
+
+	events = client.StartHealthMonitor(context.Background(), time.Hour)
+	next(events)
+	next(events)
+	if err := client.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	waitClosed(t, events)
+}
+
+func waitClosed(t *testing.T, events <-chan HealthEvent) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-events:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("expected the monitor to stop and close its channel")
+		}
+	}
+}
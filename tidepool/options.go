@@ -1,7 +1,14 @@
 package tidepool
 
 import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"maps"
 	"net/http"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
 )
 
@@ -10,22 +17,175 @@ const (
 	defaultIngestURL = "http://localhost:8081"
 	defaultTimeout   = 30 * time.Second
 	defaultNamespace = "default"
+
+	defaultPollInterval = 500 * time.Millisecond
+
+	defaultUpsertBatchSize = 1000
+
+	// defaultMaxResponseBytes caps buffered response bodies unless
+	// WithMaxResponseBytes says otherwise.
+	defaultMaxResponseBytes = 64 << 20
+
+	// statusConcurrency bounds the concurrent status requests made by
+	// AllNamespaceStatuses.
+	statusConcurrency = 8
+
+	// compressionThreshold is the minimum request body size that is gzipped
+	// when compression is enabled.
+	compressionThreshold = 8 << 10
+
+	// decodeSnippetLimit caps how much of a malformed response body is
+	// quoted in decode errors.
+	decodeSnippetLimit = 200
+
+	// streamDrainLimit caps how much of an abandoned streamed response is
+	// read and discarded so its connection can be reused. Larger remainders
+	// close the connection instead.
+	streamDrainLimit = 256 << 10
 )
 
 // Config holds client configuration.
 type Config struct {
-	QueryURL   string
-	IngestURL  string
-	Timeout    time.Duration
+	QueryURL  string
+	IngestURL string
+	// BaseURL is used for whichever of QueryURL and IngestURL is not set,
+	// for deployments that serve both services from one host.
+	BaseURL string
+	// PathPrefix is inserted between the service URL and every endpoint
+	// path, e.g. "tidepool" for /tidepool/v1/vectors/{namespace}.
+	PathPrefix       string
+	Timeout          time.Duration
 	DefaultNamespace string
 	// Namespace is deprecated. Use DefaultNamespace.
 	Namespace  string
 	HTTPClient *http.Client
+	// AuthHeader and AuthValue are sent on every request when AuthHeader is set.
+	AuthHeader string
+	AuthValue  string
+	// NamespaceHeader, when set, names a header that carries the namespace
+	// of every namespaced request, matching the one in the URL path.
+	NamespaceHeader string
+	// TokenProvider returns a bearer token per request. It takes precedence
+	// over AuthHeader/AuthValue for the Authorization header.
+	TokenProvider func(ctx context.Context) (string, error)
+	// UpsertBatchSize splits upserts into chunks of this many documents, and
+	// deletes into chunks of this many ids. Zero sends everything in a single
+	// request.
+	UpsertBatchSize int
+	// MultiQueryFallback is the concurrency used by MultiQuery when the server
+	// has no batch endpoint. Zero disables the fallback.
+	MultiQueryFallback int
+	// DimensionCheck validates outgoing vectors against the namespace's
+	// dimensions, fetched once per namespace and cached.
+	DimensionCheck bool
+	// SkipVectorValidation skips the per-element NaN/Inf scan of dense
+	// vectors, keeping only the empty and dimension checks.
+	SkipVectorValidation bool
+	// MaxResponseBytes caps the size of a buffered response body after
+	// decompression. Zero means 64 MiB; negative disables the limit.
+	MaxResponseBytes int64
+	// DefaultDistanceMetric is used by queries, upserts, and CreateNamespace
+	// calls that do not set a DistanceMetric.
+	DefaultDistanceMetric DistanceMetric
+	// MetricInference remembers the distance metric of each namespace's
+	// upserts and sends it with queries that do not set one.
+	MetricInference bool
+	// Logger is called after every request, including failed ones.
+	Logger func(ctx context.Context, info RequestInfo)
+	// LogBodies captures truncated request and response bodies in RequestInfo.
+	LogBodies bool
+	// Instrumenters observe every client operation.
+	Instrumenters []Instrumenter
+	// Compression gzips request bodies of at least 8 KiB.
+	Compression bool
+	// PollInterval is the delay between polls in WaitReady and UpsertAndWait,
+	// and the default for CompactAndWait. Default: 500ms.
+	PollInterval time.Duration
+	// Retry configures automatic retries. The zero value disables them.
+	Retry RetryPolicy
+	// ContextTimeoutOnly leaves http.Client.Timeout unset so each call is
+	// bounded only by its context deadline. Timeout is ignored.
+	ContextTimeoutOnly bool
+	// CircuitBreaker enables per-service circuit breaking when non-nil.
+	CircuitBreaker *CircuitBreakerSettings
+	// Transport tunes the connection pool of the client built by New. Ignored
+	// when HTTPClient is set.
+	Transport *TransportOptions
+	// TLSConfig is used by the transport of the client built by New. Ignored
+	// when HTTPClient is set.
+	TLSConfig *tls.Config
+	// HTTP2 makes the client built by New use HTTP/2, including h2c for
+	// plaintext URLs. See WithHTTP2. Ignored when HTTPClient is set.
+	HTTP2 bool
+	// NamespacePattern, when set, must match every namespace in addition to
+	// the built-in checks.
+	NamespacePattern *regexp.Regexp
+	// DisableAlphaClamp sends QueryOptions.Alpha as given instead of
+	// clamping it to [0, 1].
+	DisableAlphaClamp bool
+	// StrictAlpha rejects an Alpha outside [0, 1] with ErrValidation. It takes
+	// precedence over clamping.
+	StrictAlpha bool
+	// UserAgent is prepended to the default "tidepool-go/<Version>"
+	// User-Agent header.
+	UserAgent string
+	// Embedder computes vectors client-side for text-only documents and
+	// queries. Optional.
+	Embedder Embedder
+	// RequestSigner returns headers to add to each request, computed from
+	// its canonical JSON body (nil for requests without a body). See
+	// WithRequestSigner.
+	RequestSigner func(body []byte) http.Header
+	// UseNumber decodes numbers in result attributes as json.Number instead
+	// of float64, so integers beyond 2^53 keep their exact value.
+	UseNumber bool
+	// AttributeSchemas maps namespaces to their attribute kinds. Filters on
+	// a namespace with a schema are validated before they are sent.
+	AttributeSchemas map[string]map[string]AttrKind
+	// Quantization sends upserted vectors as int8 with a per-vector scale
+	// when non-nil. See WithQuantization.
+	Quantization *QuantConfig
+	// ResponseHook receives a copy of the value returned by every successful
+	// client method. See WithResponseHook.
+	ResponseHook func(op string, v any)
+	// WarningHandler receives the non-fatal warnings servers return with
+	// successful responses. See WithWarningHandler.
+	WarningHandler func(op string, warnings []string)
 }
 
 // Option configures the client.
 type Option func(*Config)
 
+// clone returns a copy of cfg that shares no mutable state with it, apart
+// from HTTPClient and the interfaces and functions it holds.
+func (cfg Config) clone() Config {
+	cfg.Namespace = cfg.DefaultNamespace
+	cfg.Instrumenters = slices.Clone(cfg.Instrumenters)
+	if cfg.AttributeSchemas != nil {
+		schemas := make(map[string]map[string]AttrKind, len(cfg.AttributeSchemas))
+		for namespace, fields := range cfg.AttributeSchemas {
+			schemas[namespace] = maps.Clone(fields)
+		}
+		cfg.AttributeSchemas = schemas
+	}
+	if cfg.CircuitBreaker != nil {
+		settings := *cfg.CircuitBreaker
+		cfg.CircuitBreaker = &settings
+	}
+	if cfg.Transport != nil {
+		transport := *cfg.Transport
+		cfg.Transport = &transport
+	}
+	if cfg.Quantization != nil {
+		quant := *cfg.Quantization
+		cfg.Quantization = &quant
+	}
+	if cfg.TLSConfig != nil {
+		cfg.TLSConfig = cfg.TLSConfig.Clone()
+	}
+	return cfg
+}
+
 // WithQueryURL sets the base URL for the query service.
 func WithQueryURL(url string) Option {
 	return func(c *Config) {
@@ -40,6 +200,15 @@ func WithIngestURL(url string) Option {
 	}
 }
 
+// WithBaseURL serves both the query and ingest services from one base URL.
+// WithQueryURL and WithIngestURL take precedence over it regardless of
+// option order.
+func WithBaseURL(url string) Option {
+	return func(c *Config) {
+		c.BaseURL = url
+	}
+}
+
 // WithTimeout sets the HTTP client timeout.
 func WithTimeout(d time.Duration) Option {
 	return func(c *Config) {
@@ -68,3 +237,363 @@ func WithHTTPClient(client *http.Client) Option {
 		c.HTTPClient = client
 	}
 }
+
+// WithAPIKey sends key as a bearer token in the Authorization header.
+func WithAPIKey(key string) Option {
+	return func(c *Config) {
+		c.AuthHeader = "Authorization"
+		c.AuthValue = "Bearer " + key
+	}
+}
+
+// WithAuthHeader sends a custom authentication header on every request,
+// e.g. WithAuthHeader("X-Tidepool-Token", token).
+func WithAuthHeader(name, value string) Option {
+	return func(c *Config) {
+		c.AuthHeader = name
+		c.AuthValue = value
+	}
+}
+
+// WithTokenProvider sets a callback that returns a bearer token for each request.
+// Use it when tokens expire and must be refreshed (e.g. OIDC).
+func WithTokenProvider(provider func(ctx context.Context) (string, error)) Option {
+	return func(c *Config) {
+		c.TokenProvider = provider
+	}
+}
+
+// WithUpsertBatchSize splits Upsert calls into sequential requests of at most n documents.
+// Delete splits its ids using the same size.
+func WithUpsertBatchSize(n int) Option {
+	return func(c *Config) {
+		c.UpsertBatchSize = n
+	}
+}
+
+// WithMultiQueryFallback makes MultiQuery fall back to concurrent single queries,
+// at most concurrency at a time, when the server has no batch query endpoint.
+func WithMultiQueryFallback(concurrency int) Option {
+	return func(c *Config) {
+		c.MultiQueryFallback = concurrency
+	}
+}
+
+// WithDimensionCheck enables local validation of upsert and query vectors
+// against the namespace dimensions before any request is sent.
+func WithDimensionCheck(enabled bool) Option {
+	return func(c *Config) {
+		c.DimensionCheck = enabled
+	}
+}
+
+// WithSkipVectorValidation skips the per-element NaN/Inf scan of dense query
+// and upsert vectors, keeping only the empty and dimension checks. It is
+// meant for trusted pipelines whose vectors are already validated.
+//
+// A NaN or Inf value is then caught only when the request body is encoded:
+// the call fails with a *json.UnsupportedValueError that does not name the
+// document, rather than ErrValidation, and a batched upsert may already have
+// written the batches before it.
+func WithSkipVectorValidation() Option {
+	return func(c *Config) {
+		c.SkipVectorValidation = true
+	}
+}
+
+// WithPathPrefix serves every endpoint under prefix, for servers exposed
+// below a subpath by a reverse proxy: WithPathPrefix("tidepool") sends
+// queries to {QueryURL}/tidepool/v1/vectors/{namespace} and health checks to
+// {QueryURL}/tidepool/health. Leading, trailing, and repeated slashes are
+// ignored, and the prefix may span several segments ("api/tidepool").
+func WithPathPrefix(prefix string) Option {
+	return func(c *Config) {
+		c.PathPrefix = prefix
+	}
+}
+
+// WithNamespaceHeader sends the namespace of every namespaced request in the
+// named header as well as the URL path, e.g. WithNamespaceHeader("X-Namespace")
+// for gateways that enforce per-namespace policy. The value is taken from
+// the request path, so it always matches it exactly. Requests that are not
+// scoped to one namespace, such as Health and ListNamespaces, omit it.
+func WithNamespaceHeader(name string) Option {
+	return func(c *Config) {
+		c.NamespaceHeader = name
+	}
+}
+
+// WithLogger sets a hook that is called after every request completes.
+// Credentials in the Authorization or custom auth header are redacted.
+func WithLogger(logger func(ctx context.Context, info RequestInfo)) Option {
+	return func(c *Config) {
+		c.Logger = logger
+	}
+}
+
+// WithResponseHook sets a hook that receives the result of every client
+// method that returns one, for auditing. It is called just before the method
+// returns, with the method name (as in OperationInfo.Name) and a deep copy of
+// the value, e.g. *QueryResponse for Query or []VectorResult for Fetch, so it
+// cannot modify what the caller gets. Calls that fail are not reported.
+// Methods built on other methods, such as ExistsMany and CompactAndWait, are
+// reported through the calls they make, and QueryStream and Scroll results
+// are not reported. The hook may be called concurrently.
+func WithResponseHook(hook func(op string, v any)) Option {
+	return func(c *Config) {
+		c.ResponseHook = hook
+	}
+}
+
+// WithWarningHandler sets a handler for the non-fatal warnings a server
+// returns with a successful response, e.g. {"results": [...], "warnings":
+// ["topK exceeds segment size"]}, which point at misconfigurations that
+// degrade results without failing the call. It is called with the method
+// name and the warnings of each response that has any: Query, FindSimilar,
+// Fetch, and Scroll pages, each MultiQuery result, and each batch written by
+// UpsertConcurrent and UpsertStream. Upsert and UpsertWithResult report the
+// warnings of all their batches together, once the call succeeds. Methods
+// built on other methods, such as QueryMulti, report through the calls they
+// make; QueryMulti also reports the namespaces it skipped as missing. The
+// handler may be called concurrently. Warnings are also kept in
+// QueryResponse.Warnings and UpsertResponse.Warnings.
+func WithWarningHandler(handler func(op string, warnings []string)) Option {
+	return func(c *Config) {
+		c.WarningHandler = handler
+	}
+}
+
+// WithLogBodies enables capturing truncated request and response bodies for
+// the logger. It is off by default to avoid logging large vectors.
+func WithLogBodies(enabled bool) Option {
+	return func(c *Config) {
+		c.LogBodies = enabled
+	}
+}
+
+// WithInstrumenter adds an Instrumenter that observes every client operation.
+// It may be given multiple times.
+func WithInstrumenter(inst Instrumenter) Option {
+	return func(c *Config) {
+		if inst != nil {
+			c.Instrumenters = append(c.Instrumenters, inst)
+		}
+	}
+}
+
+// WithCompression gzip-compresses request bodies of at least 8 KiB and sets
+// Content-Encoding: gzip. Smaller bodies and bodiless requests are sent as-is.
+// It is off by default; enable it only if the server accepts gzip requests.
+func WithCompression(enabled bool) Option {
+	return func(c *Config) {
+		c.Compression = enabled
+	}
+}
+
+// WithPollInterval sets how often WaitReady polls service health and
+// UpsertAndWait polls for the written documents. It is also the default
+// interval of CompactAndWait.
+func WithPollInterval(d time.Duration) Option {
+	return func(c *Config) {
+		c.PollInterval = d
+	}
+}
+
+// WithRetry enables automatic retries of rate-limited and unavailable
+// responses. A server-sent Retry-After header is honored exactly; otherwise
+// the policy's exponential backoff is used.
+func WithRetry(policy RetryPolicy) Option {
+	return func(c *Config) {
+		c.Retry = policy
+	}
+}
+
+// WithContextTimeoutOnly disables the client-wide HTTP timeout so each call is
+// governed only by its own context (e.g. context.WithTimeout), letting a slow
+// bulk operation run longer than everything else. WithTimeout is ignored, and
+// a client supplied with WithHTTPClient keeps its own Timeout.
+//
+// Calls made without a context deadline can then block indefinitely on an
+// unresponsive server. As with the client timeout, a request canceled by its
+// context mid-response closes its connection instead of returning it to the
+// idle pool, so frequent short deadlines reduce connection reuse.
+func WithContextTimeoutOnly() Option {
+	return func(c *Config) {
+		c.ContextTimeoutOnly = true
+	}
+}
+
+// WithCircuitBreaker fails calls fast with ErrCircuitOpen once a service has
+// returned FailureThreshold consecutive transport or 5xx errors. After
+// Cooldown a single probe request is allowed; success closes the breaker and
+// failure keeps it open for another cooldown.
+func WithCircuitBreaker(settings CircuitBreakerSettings) Option {
+	return func(c *Config) {
+		c.CircuitBreaker = &settings
+	}
+}
+
+// WithEmbedder computes embeddings on the client. Upserted documents with Text
+// but no Vector are embedded before sending, and a Query with Mode set to
+// QueryModeVector or QueryModeHybrid that has Text but no vector embeds the
+// text as its query vector. The embedder is never called when vectors are
+// already present.
+func WithEmbedder(e Embedder) Option {
+	return func(c *Config) {
+		c.Embedder = e
+	}
+}
+
+// WithUserAgent identifies the calling application in the User-Agent header.
+// ua is sent ahead of the library's own token, e.g.
+// "search-api/2.3 tidepool-go/0.1.0", so traffic stays attributable to both.
+func WithUserAgent(ua string) Option {
+	return func(c *Config) {
+		c.UserAgent = strings.TrimSpace(ua)
+	}
+}
+
+// WithAlphaClamp controls whether QueryOptions.Alpha values outside [0, 1]
+// are clamped into range before sending. Clamping is on by default; disable
+// it for servers that accept a wider range.
+func WithAlphaClamp(enabled bool) Option {
+	return func(c *Config) {
+		c.DisableAlphaClamp = !enabled
+	}
+}
+
+// WithStrictAlpha makes Query return ErrValidation for an Alpha outside
+// [0, 1] instead of clamping or passing it through.
+func WithStrictAlpha(strict bool) Option {
+	return func(c *Config) {
+		c.StrictAlpha = strict
+	}
+}
+
+// WithTransport tunes the connection pool of the HTTP client built by New,
+// without having to construct a client by hand. It is ignored when
+// WithHTTPClient is also used; configure that client's transport instead.
+func WithTransport(opts TransportOptions) Option {
+	return func(c *Config) {
+		c.Transport = &opts
+	}
+}
+
+// WithTLSConfig sets the TLS configuration, e.g. a custom root CA pool or
+// client certificates for mutual TLS, on the transport of the HTTP client
+// built by New. The config is cloned. It is ignored when WithHTTPClient is
+// used; set TLSClientConfig on that client's transport instead.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(c *Config) {
+		c.TLSConfig = cfg
+	}
+}
+
+// WithHTTP2 makes the built-in HTTP client speak HTTP/2 so concurrent
+// requests share one multiplexed connection per host. HTTPS URLs negotiate
+// HTTP/2 with ALPN and use HTTP/1.1 if the server does not offer it.
+// Plaintext http:// URLs, such as the localhost defaults, use h2c with prior
+// knowledge; because h2c cannot be negotiated, a host whose first h2c
+// request fails is retried over HTTP/1.1 and, if that succeeds, used over
+// HTTP/1.1 from then on. WithTransport settings apply to both protocols;
+// over HTTP/2 they matter less, since far fewer connections are opened.
+// Ignored when WithHTTPClient is used.
+func WithHTTP2(enabled bool) Option {
+	return func(c *Config) {
+		c.HTTP2 = enabled
+	}
+}
+
+// WithNamespacePattern restricts namespaces to names matching pattern, e.g.
+// regexp.MustCompile(`^[a-z0-9_-]{1,64}$`). Names with whitespace, path
+// separators, or control characters are always rejected, whatever the
+// pattern allows.
+func WithNamespacePattern(pattern *regexp.Regexp) Option {
+	return func(c *Config) {
+		c.NamespacePattern = pattern
+	}
+}
+
+// WithMetricInference makes the client remember the DistanceMetric last
+// upserted to each namespace and reuse it for queries on that namespace that
+// do not specify one. The cache lives only as long as the client.
+func WithMetricInference(enabled bool) Option {
+	return func(c *Config) {
+		c.MetricInference = enabled
+	}
+}
+
+// WithAttributeSchema registers the attributes of namespace so that filters
+// passed to Query, MultiQuery, FindSimilar, Count, and DeleteByFilter on it
+// are checked client-side: unknown keys and values of the wrong kind fail
+// with ErrValidation instead of silently matching nothing. Namespaces without
+// a schema are not checked. Registering a namespace again replaces its schema.
+func WithAttributeSchema(namespace string, fields map[string]AttrKind) Option {
+	return func(c *Config) {
+		if c.AttributeSchemas == nil {
+			c.AttributeSchemas = make(map[string]map[string]AttrKind)
+		}
+		c.AttributeSchemas[namespace] = maps.Clone(fields)
+	}
+}
+
+// WithRequestSigner attaches the headers returned by sign to every request,
+// for schemes such as HMAC request signing. sign receives the request body
+// in canonical form: JSON with the keys of every object sorted, so the bytes
+// depend only on the content and not on field order or map iteration. The
+// body is passed before compression and is nil for requests without one.
+// sign is called again for each retry attempt, and its headers are applied
+// after authentication headers.
+func WithRequestSigner(sign func(body []byte) http.Header) Option {
+	return func(c *Config) {
+		c.RequestSigner = sign
+	}
+}
+
+// WithUseNumber makes the client decode numbers in result attributes as
+// json.Number instead of float64, so large integers such as 64-bit IDs are
+// not rounded. Read them with VectorResult.GetInt64 or GetAttr, which accept
+// json.Number; code that type-asserts attribute values to float64 must
+// handle json.Number instead.
+func WithUseNumber(enabled bool) Option {
+	return func(c *Config) {
+		c.UseNumber = enabled
+	}
+}
+
+// WithDefaultDistanceMetric sets the DistanceMetric for queries, upserts, and
+// CreateNamespace calls whose options leave it empty. Per-call options still
+// win, as does a metric remembered through WithMetricInference. It panics
+// when called with an unknown metric, so a typo fails where the option is
+// built rather than on the first request.
+func WithDefaultDistanceMetric(m DistanceMetric) Option {
+	if err := validateMetric(m); err != nil {
+		panic(fmt.Sprintf("tidepool: WithDefaultDistanceMetric: %v", err))
+	}
+	return func(c *Config) {
+		c.DefaultDistanceMetric = m
+	}
+}
+
+// WithMaxResponseBytes caps how much of a response body the client reads
+// into memory, after decompression, so a misbehaving server cannot exhaust
+// it. Larger responses fail with ErrResponseTooLarge. The default is 64 MiB;
+// n < 0 removes the limit. Successful QueryStream responses are decoded
+// incrementally and are not limited.
+func WithMaxResponseBytes(n int64) Option {
+	return func(c *Config) {
+		c.MaxResponseBytes = n
+	}
+}
+
+// WithQuantization sends upserted document vectors quantized to int8 with a
+// per-vector scale (see QuantizeVector), which makes upsert bodies
+// considerably smaller at the cost of precision. Quantization is applied
+// only once the ingest service advertises FeatureQuantizedVectors in its
+// health response, unless cfg.SkipSupportCheck is set; until then vectors
+// are sent as float32. Queries are unaffected.
+func WithQuantization(cfg QuantConfig) Option {
+	return func(c *Config) {
+		c.Quantization = &cfg
+	}
+}
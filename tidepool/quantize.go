@@ -0,0 +1,116 @@
+package tidepool
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+)
+
+// FeatureQuantizedVectors is the feature the ingest service lists in its
+// health response when it accepts int8-quantized vectors in upserts.
+const FeatureQuantizedVectors = "quantized_vectors"
+
+// QuantConfig configures client-side vector quantization. See
+// WithQuantization.
+type QuantConfig struct {
+	// SkipSupportCheck quantizes without first checking that the ingest
+	// service advertises FeatureQuantizedVectors. Use it only for servers
+	// known to accept the quantized format.
+	SkipSupportCheck bool
+}
+
+// QuantizedVector is a vector quantized to int8 with a single scale:
+// component i is approximately float32(Quantized[i]) * Scale. It is the wire
+// format sent in place of Document.Vector when quantization is enabled.
+type QuantizedVector struct {
+	Quantized []int8  `json:"quantized"`
+	Scale     float32 `json:"scale"`
+}
+
+// QuantizeVector quantizes v symmetrically to int8, scaling by the largest
+// absolute component so it maps to ±127. The error of each component is at
+// most Scale/2. A zero vector has a zero scale. Non-finite values fail with
+// ErrValidation.
+func QuantizeVector(v Vector) (QuantizedVector, error) {
+	var maxAbs float64
+	for i, val := range v {
+		f := float64(val)
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return QuantizedVector{}, fmt.Errorf("%w: vector value at index %d is not finite", ErrValidation, i)
+		}
+		maxAbs = max(maxAbs, math.Abs(f))
+	}
+
+	q := QuantizedVector{Quantized: make([]int8, len(v))}
+	if maxAbs == 0 {
+		return q, nil
+	}
+	scale := maxAbs / math.MaxInt8
+	q.Scale = float32(scale)
+	for i, val := range v {
+		q.Quantized[i] = int8(max(-math.MaxInt8, min(math.MaxInt8, math.Round(float64(val)/scale))))
+	}
+	return q, nil
+}
+
+// Dequantize returns the float32 approximation of q.
+func (q QuantizedVector) Dequantize() Vector {
+	out := make(Vector, len(q.Quantized))
+	for i, val := range q.Quantized {
+		out[i] = float32(val) * q.Scale
+	}
+	return out
+}
+
+// quantizedDocument is the wire form of a Document whose vector is sent
+// quantized. Its Vector field shadows the embedded Document.Vector.
+type quantizedDocument struct {
+	Document
+	Vector *QuantizedVector `json:"vector,omitempty"`
+}
+
+// quantizeDocuments returns the wire form of docs with every dense vector
+// quantized.
+func quantizeDocuments(docs []Document) ([]quantizedDocument, error) {
+	out := make([]quantizedDocument, len(docs))
+	for i, doc := range docs {
+		out[i].Document = doc
+		if len(doc.Vector) == 0 {
+			continue
+		}
+		q, err := QuantizeVector(doc.Vector)
+		if err != nil {
+			return nil, fmt.Errorf("document %d: %w", i, err)
+		}
+		out[i].Vector = &q
+	}
+	return out, nil
+}
+
+// quantizeUpserts reports whether upserts should send quantized vectors. The
+// ingest service's advertised features are fetched once and cached; when
+// the check fails, vectors are sent unquantized and the check is retried on
+// the next upsert.
+func (c *Client) quantizeUpserts(ctx context.Context) bool {
+	cfg := c.config.Quantization
+	if cfg == nil {
+		return false
+	}
+	if cfg.SkipSupportCheck {
+		return true
+	}
+
+	c.quantMu.Lock()
+	defer c.quantMu.Unlock()
+	if c.quantSupported != nil {
+		return *c.quantSupported
+	}
+	health, err := c.health(ctx, "ingest")
+	if err != nil {
+		return false
+	}
+	supported := slices.Contains(health.Features, FeatureQuantizedVectors)
+	c.quantSupported = &supported
+	return supported
+}
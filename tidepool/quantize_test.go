@@ -0,0 +1,119 @@
+package tidepool
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestQuantizeVectorRoundTrip(t *testing.T) {
+	vectors := []Vector{
+		{0.1, -0.5, 0.25, 1e-4, -0.999},
+		{120, -3, 0, 7.5},
+		{-2},
+	}
+	for _, v := range vectors {
+		q, err := QuantizeVector(v)
+		if err != nil {
+			t.Fatalf("quantize %v: %v", v, err)
+		}
+		got := q.Dequantize()
+		if len(got) != len(v) {
+			t.Fatalf("expected %d components, got %d", len(v), len(got))
+		}
+		for i := range v {
+			if diff := math.Abs(float64(got[i] - v[i])); diff > float64(q.Scale)/2+1e-6 {
+				t.Fatalf("component %d of %v: expected within %v, got %v (diff %v)", i, v, q.Scale/2, got[i], diff)
+			}
+		}
+	}
+
+	q, err := QuantizeVector(Vector{0, 0})
+	if err != nil || q.Scale != 0 || len(q.Dequantize()) != 2 {
+		t.Fatalf("expected zero vector to quantize with zero scale, got %+v, %v", q, err)
+	}
+	if _, err := QuantizeVector(Vector{1, float32(math.Inf(1))}); !IsValidationError(err) {
+		t.Fatalf("expected validation error for infinite value, got %v", err)
+	}
+}
+
+func TestUpsertQuantization(t *testing.T) {
+	newServer := func(features []string, healthChecks *atomic.Int32) (*httptest.Server, *[]json.RawMessage) {
+		var vectors []json.RawMessage
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/health" {
+				healthChecks.Add(1)
+				_ = json.NewEncoder(w).Encode(HealthResponse{Service: "ingest", Status: "ok", Features: features})
+				return
+			}
+			var body struct {
+				Vectors []struct {
+					Vector json.RawMessage `json:"vector"`
+				} `json:"vectors"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Errorf("decode body: %v", err)
+			}
+			for _, doc := range body.Vectors {
+				vectors = append(vectors, doc.Vector)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		return srv, &vectors
+	}
+	ctx := context.Background()
+	docs := []Document{{ID: "a", Vector: Vector{0.5, -1, 0.25}}, {ID: "b", Text: "no vector"}}
+
+	var checks atomic.Int32
+	srv, sent := newServer([]string{FeatureQuantizedVectors}, &checks)
+	defer srv.Close()
+	client := New(WithIngestURL(srv.URL), WithQuantization(QuantConfig{}))
+	for range 2 {
+		if err := client.Upsert(ctx, docs, nil); err != nil {
+			t.Fatalf("upsert failed: %v", err)
+		}
+	}
+	if checks.Load() != 1 {
+		t.Fatalf("expected advertised features to be fetched once, got %d", checks.Load())
+	}
+	var q QuantizedVector
+	if err := json.Unmarshal((*sent)[0], &q); err != nil {
+		t.Fatalf("expected quantized wire format, got %s: %v", (*sent)[0], err)
+	}
+	if got := q.Dequantize(); math.Abs(float64(got[1]+1)) > 1e-6 || math.Abs(float64(got[2]-0.25)) > float64(q.Scale)/2 {
+		t.Fatalf("expected dequantized vector near %v, got %v", docs[0].Vector, got)
+	}
+	if string((*sent)[1]) != "" {
+		t.Fatalf("expected document without a vector to omit it, got %s", (*sent)[1])
+	}
+	if len(docs[0].Vector) != 3 || docs[0].Vector[0] != 0.5 {
+		t.Fatalf("expected caller documents to be unchanged")
+	}
+
+	var plainChecks atomic.Int32
+	plain, plainSent := newServer(nil, &plainChecks)
+	defer plain.Close()
+	client = New(WithIngestURL(plain.URL), WithQuantization(QuantConfig{}))
+	if err := client.Upsert(ctx, docs[:1], nil); err != nil {
+		t.Fatalf("upsert failed: %v", err)
+	}
+	var v Vector
+	if err := json.Unmarshal((*plainSent)[0], &v); err != nil {
+		t.Fatalf("expected float vector when the server does not advertise support, got %s", (*plainSent)[0])
+	}
+
+	client = New(WithIngestURL(plain.URL), WithQuantization(QuantConfig{SkipSupportCheck: true}))
+	if err := client.Upsert(ctx, docs[:1], nil); err != nil {
+		t.Fatalf("upsert failed: %v", err)
+	}
+	if err := json.Unmarshal((*plainSent)[1], &q); err != nil || len(q.Quantized) != 3 {
+		t.Fatalf("expected quantized vector with SkipSupportCheck, got %s", (*plainSent)[1])
+	}
+	if plainChecks.Load() != 1 {
+		t.Fatalf("expected SkipSupportCheck to skip the health check, got %d checks", plainChecks.Load())
+	}
+}
@@ -0,0 +1,121 @@
+package tidepool
+
+import (
+	"context"
+	"maps"
+	"slices"
+)
+
+// QueryBuilder builds and runs a Query fluently, taking care of the pointer
+// fields in QueryOptions:
+//
+//	resp, err := tidepool.NewQuery(vec).
+//		Text("running shoes").
+//		Hybrid(0.7).
+//		TopK(10).
+//		Filter(tidepool.Attributes{"brand": "acme"}).
+//		Execute(ctx, client)
+//
+// Validation is left to Query, so an invalid combination (e.g. a bad mode or
+// rrf_k) returns the same ErrValidation it would with QueryOptions.
+type QueryBuilder struct {
+	vector Vector
+	opts   QueryOptions
+}
+
+// NewQuery starts a query for vector, which may be nil for text-only queries.
+func NewQuery(vector Vector) *QueryBuilder {
+	return &QueryBuilder{vector: vector}
+}
+
+// TopK sets the number of results to return.
+func (b *QueryBuilder) TopK(n int) *QueryBuilder {
+	b.opts.TopK = n
+	return b
+}
+
+// Namespace sets the namespace to query instead of the client default.
+func (b *QueryBuilder) Namespace(ns string) *QueryBuilder {
+	b.opts.Namespace = ns
+	return b
+}
+
+// Text sets the full-text query. Without a vector this is a text query; with
+// one the mode is inferred as hybrid unless set explicitly.
+func (b *QueryBuilder) Text(text string) *QueryBuilder {
+	b.opts.Text = text
+	return b
+}
+
+// Mode sets the query mode explicitly.
+func (b *QueryBuilder) Mode(mode QueryMode) *QueryBuilder {
+	b.opts.Mode = mode
+	return b
+}
+
+// Hybrid selects hybrid mode with blend fusion weighted by alpha.
+func (b *QueryBuilder) Hybrid(alpha float32) *QueryBuilder {
+	b.opts.Mode = QueryModeHybrid
+	b.opts.Fusion = FusionBlend
+	b.opts.Alpha = &alpha
+	return b
+}
+
+// RRF selects hybrid mode with reciprocal-rank fusion using constant k.
+func (b *QueryBuilder) RRF(k int) *QueryBuilder {
+	b.opts.Mode = QueryModeHybrid
+	b.opts.Fusion = FusionRRF
+	b.opts.RRFK = &k
+	return b
+}
+
+// Filter adds attribute filters. Repeated calls merge, with later keys
+// overriding earlier ones. Use NewFilter for operator filters.
+func (b *QueryBuilder) Filter(attrs Attributes) *QueryBuilder {
+	if b.opts.Filters == nil {
+		b.opts.Filters = make(Attributes, len(attrs))
+	}
+	maps.Copy(b.opts.Filters, attrs)
+	return b
+}
+
+// IncludeVectors requests that results carry (or omit) their vectors.
+func (b *QueryBuilder) IncludeVectors(include bool) *QueryBuilder {
+	b.opts.IncludeVectors = &include
+	return b
+}
+
+// IncludeAttributes limits the attributes returned with each result to keys.
+func (b *QueryBuilder) IncludeAttributes(keys ...string) *QueryBuilder {
+	b.opts.IncludeAttributes = append(b.opts.IncludeAttributes, keys...)
+	return b
+}
+
+// Vectors sets the query vectors for a multi-vector (late-interaction)
+// query. They take precedence over the vector passed to NewQuery.
+func (b *QueryBuilder) Vectors(vectors ...Vector) *QueryBuilder {
+	b.opts.Vectors = append(b.opts.Vectors, vectors...)
+	return b
+}
+
+// MinScore drops results scoring worse than score; see QueryOptions.MinScore
+// for its direction per metric.
+func (b *QueryBuilder) MinScore(score float32) *QueryBuilder {
+	b.opts.MinScore = &score
+	return b
+}
+
+// Options returns a copy of the QueryOptions built so far.
+func (b *QueryBuilder) Options() QueryOptions {
+	opts := b.opts
+	opts.Filters = maps.Clone(b.opts.Filters)
+	opts.IncludeAttributes = slices.Clone(b.opts.IncludeAttributes)
+	opts.Vectors = slices.Clone(b.opts.Vectors)
+	return opts
+}
+
+// Execute runs the query with client.
+func (b *QueryBuilder) Execute(ctx context.Context, client *Client) (*QueryResponse, error) {
+	opts := b.Options()
+	return client.Query(ctx, b.vector, &opts)
+}
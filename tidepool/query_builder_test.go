@@ -0,0 +1,70 @@
+package tidepool
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestQueryBuilder(t *testing.T) {
+	var captured map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		captured = nil
+		_ = json.NewDecoder(r.Body).Decode(&captured)
+		_ = json.NewEncoder(w).Encode([]VectorResult{{ID: "a"}})
+	}))
+	defer srv.Close()
+
+	client := New(WithQueryURL(srv.URL))
+	resp, err := NewQuery(Vector{0.1, 0.2}).
+		Text("shoes").
+		Hybrid(0.7).
+		TopK(5).
+		Filter(Attributes{"brand": "acme"}).
+		Filter(Attributes{"size": 42}).
+		IncludeVectors(true).
+		IncludeAttributes("title").
+		MinScore(0).
+		Execute(context.Background(), client)
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	if len(resp.Results) != 1 {
+		t.Fatalf("unexpected results %+v", resp.Results)
+	}
+	if captured["mode"] != "hybrid" || captured["fusion"] != "blend" || captured["top_k"] != float64(5) || captured["include_vectors"] != true {
+		t.Fatalf("unexpected payload %v", captured)
+	}
+	if attrs, _ := captured["include_attributes"].([]any); len(attrs) != 1 || attrs[0] != "title" {
+		t.Fatalf("expected include_attributes [title], got %v", captured["include_attributes"])
+	}
+	if minScore, ok := captured["min_score"]; !ok || minScore != float64(0) {
+		t.Fatalf("expected min_score 0 to be sent, got %v", captured["min_score"])
+	}
+	if alpha, _ := captured["alpha"].(float64); alpha < 0.69 || alpha > 0.71 {
+		t.Fatalf("expected alpha 0.7, got %v", captured["alpha"])
+	}
+	filters, _ := captured["filters"].(map[string]any)
+	if filters["brand"] != "acme" || filters["size"] != float64(42) {
+		t.Fatalf("expected merged filters, got %v", captured["filters"])
+	}
+
+	if _, err := NewQuery(Vector{0.1}).Text("shoes").RRF(0).Execute(context.Background(), client); !IsValidationError(err) {
+		t.Fatalf("expected rrf_k validation error, got %v", err)
+	}
+	if _, err := NewQuery(nil).Mode("bogus").Text("x").Execute(context.Background(), client); !IsValidationError(err) {
+		t.Fatalf("expected mode validation error, got %v", err)
+	}
+}
+
+func TestQueryBuilderOptionsCopy(t *testing.T) {
+	b := NewQuery(nil).Filter(Attributes{"a": 1}).Vectors(Vector{1}, Vector{2})
+	opts := b.Options()
+	opts.Filters["a"] = 2
+	opts.Vectors[0] = Vector{3}
+	if b.Options().Filters["a"] != 1 || b.Options().Vectors[0][0] != 1 {
+		t.Fatalf("expected Options to return an independent copy")
+	}
+}
@@ -0,0 +1,64 @@
+package tidepool
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// RawResponse is a successful response to a request made with Do.
+type RawResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// Do sends a request to an arbitrary endpoint with the same handling as the
+// typed methods: authentication, custom and per-request headers, retries,
+// the circuit breaker, compression, logging, and instrumentation. It is an
+// escape hatch for endpoints the client does not model, or for callers that
+// need the status code and headers (such as X-Request-Id) of a successful
+// response.
+//
+// A relative path, such as "/v1/namespaces?limit=10", is sent to the query
+// service below the configured PathPrefix; pass an absolute URL, such as
+// client.Config().IngestURL + "/status", to reach the ingest service. A
+// non-nil body is marshaled to JSON; use json.RawMessage to send pre-encoded
+// JSON. Error responses fail as in the typed methods, with a *TidepoolError.
+// An absolute URL uses the ingest service's circuit breaker when it is below
+// IngestURL but not QueryURL, and the query service's otherwise.
+func (c *Client) Do(ctx context.Context, method, path string, body any) (_ *RawResponse, err error) {
+	ctx, op := c.startOperation(ctx, "Do")
+	defer func() { op.end(err) }()
+
+	u, err := url.Parse(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid path %q: %v", ErrValidation, path, err)
+	}
+	endpoint, service := path, "query"
+	if u.IsAbs() {
+		if strings.HasPrefix(path, c.config.IngestURL) && !strings.HasPrefix(path, c.config.QueryURL) {
+			service = "ingest"
+		}
+	} else {
+		endpoint, err = c.joinURL(c.config.QueryURL, u.Path)
+		if err != nil {
+			return nil, err
+		}
+		if u.RawQuery != "" {
+			endpoint += "?" + u.RawQuery
+		}
+	}
+	if namespace, ok := pathNamespace(u); ok {
+		op.setNamespace(namespace)
+	}
+
+	resp, err := c.do(ctx, service, method, endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	c.respond("Do", resp)
+	return resp, nil
+}
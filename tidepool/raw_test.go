@@ -0,0 +1,73 @@
+package tidepool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDo(t *testing.T) {
+	var attempts atomic.Int32
+	var lastBody, lastURL, lastAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		lastBody, lastURL, lastAuth = string(data), r.URL.String(), r.Header.Get("Authorization")
+		switch r.URL.Path {
+		case "/api/v1/flaky":
+			if attempts.Add(1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		case "/api/v1/missing":
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":"no such thing"}`))
+			return
+		}
+		w.Header().Set("X-Request-Id", "req-42")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	client := New(
+		WithBaseURL(srv.URL),
+		WithPathPrefix("api"),
+		WithAPIKey("secret"),
+		WithRetry(RetryPolicy{MaxRetries: 2, InitialBackoff: time.Millisecond}),
+	)
+
+	resp, err := client.Do(ctx, http.MethodPost, "/v1/vectors/docs/explain?verbose=1", json.RawMessage(`{"id":"a"}`))
+	if err != nil {
+		t.Fatalf("do failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusCreated || resp.Header.Get("X-Request-Id") != "req-42" || string(resp.Body) != `{"ok":true}` {
+		t.Fatalf("unexpected raw response: %d %v %s", resp.StatusCode, resp.Header, resp.Body)
+	}
+	if lastURL != "/api/v1/vectors/docs/explain?verbose=1" || lastBody != `{"id":"a"}` || lastAuth != "Bearer secret" {
+		t.Fatalf("unexpected request: %s %s auth=%q", lastURL, lastBody, lastAuth)
+	}
+
+	if _, err := client.Do(ctx, http.MethodGet, "/v1/flaky", nil); err != nil {
+		t.Fatalf("expected the retry to succeed, got %v", err)
+	}
+	if attempts.Load() != 2 || lastBody != "" {
+		t.Fatalf("expected two bodiless attempts, got %d with body %q", attempts.Load(), lastBody)
+	}
+
+	if _, err := client.Do(ctx, http.MethodGet, srv.URL+"/status", nil); err != nil || lastURL != "/status" {
+		t.Fatalf("expected an absolute URL to be used as is, got %s, %v", lastURL, err)
+	}
+
+	_, err = client.Do(ctx, http.MethodGet, "/v1/missing", nil)
+	var tideErr *TidepoolError
+	if !errors.As(err, &tideErr) || tideErr.StatusCode != http.StatusNotFound || !IsNotFoundError(err) {
+		t.Fatalf("expected a not-found *TidepoolError, got %v", err)
+	}
+}
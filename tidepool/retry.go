@@ -0,0 +1,84 @@
+package tidepool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	defaultInitialBackoff = 100 * time.Millisecond
+	defaultMaxBackoff     = 5 * time.Second
+)
+
+// RetryPolicy configures automatic retries for rate-limited (429) and
+// unavailable (503) responses, and for network failures that wrap
+// ErrServiceUnavailable. A write whose connection fails after it was
+// established, such as by a reset or timeout, is not retried, since the
+// server may have applied it. The zero value disables retries. A retry is
+// skipped, and the last error returned, when the context deadline would
+// expire before the backoff and another attempt as long as the last could
+// finish.
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt.
+	MaxRetries int
+	// InitialBackoff is the delay before the first retry. It doubles on each
+	// subsequent retry up to MaxBackoff. Defaults: 100ms and 5s.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// delay returns how long to wait before retry number attempt+1. A
+// Retry-After duration sent by the server takes precedence over backoff.
+func (p RetryPolicy) delay(attempt int, err error) time.Duration {
+	var tideErr *TidepoolError
+	if errors.As(err, &tideErr) && tideErr.RetryAfter > 0 {
+		return tideErr.RetryAfter
+	}
+
+	initial := p.InitialBackoff
+	if initial <= 0 {
+		initial = defaultInitialBackoff
+	}
+	maxBackoff := p.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxBackoff
+	}
+
+	d := initial
+	for i := 0; i < attempt && d < maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxBackoff)
+}
+
+// retryBudget reports an error when the time left before ctx's deadline
+// cannot cover the backoff delay plus another attempt as long as the last
+// one, in which case retrying would only run into the deadline.
+func retryBudget(ctx context.Context, delay, lastAttempt time.Duration) error {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return nil
+	}
+	if remaining := time.Until(deadline); remaining < delay+lastAttempt {
+		return fmt.Errorf("retry skipped: %v left before the context deadline, need %v backoff plus about %v for the attempt",
+			remaining.Round(time.Millisecond), delay, lastAttempt.Round(time.Millisecond))
+	}
+	return nil
+}
+
+// sleepContext waits for d or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
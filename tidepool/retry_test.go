@@ -0,0 +1,127 @@
+package tidepool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryHonorsRetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= 2 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_ = json.NewEncoder(w).Encode([]VectorResult{{ID: "a"}})
+	}))
+	defer srv.Close()
+
+	client := New(WithQueryURL(srv.URL), WithRetry(RetryPolicy{MaxRetries: 3, InitialBackoff: time.Millisecond}))
+	start := time.Now()
+	resp, err := client.Query(context.Background(), Vector{0.1}, nil)
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	elapsed := time.Since(start)
+
+	if calls.Load() != 3 || len(resp.Results) != 1 {
+		t.Fatalf("expected success on third attempt, got %d calls", calls.Load())
+	}
+	if elapsed < 2*time.Second {
+		t.Fatalf("expected Retry-After to be honored (>= 2s), took %s", elapsed)
+	}
+}
+
+func TestRetryBackoffAndLimits(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	client := New(WithIngestURL(srv.URL), WithRetry(RetryPolicy{MaxRetries: 2, InitialBackoff: time.Millisecond}))
+	err := client.Upsert(context.Background(), []Document{{ID: "a", Vector: Vector{0.1}}}, nil)
+	if !IsServiceUnavailableError(err) {
+		t.Fatalf("expected service unavailable error, got %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Fatalf("expected 3 attempts, got %d", calls.Load())
+	}
+
+	calls.Store(0)
+	if err := client.Delete(context.Background(), []string{"a"}, nil); !IsValidationError(err) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("expected non-retryable error not to be retried, got %d attempts", calls.Load())
+	}
+
+	calls.Store(0)
+	noRetry := New(WithIngestURL(srv.URL))
+	_ = noRetry.Upsert(context.Background(), []Document{{ID: "a", Vector: Vector{0.1}}}, nil)
+	if calls.Load() != 1 {
+		t.Fatalf("expected retries to be disabled by default, got %d attempts", calls.Load())
+	}
+}
+
+func TestRetryRespectsDeadlineBudget(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		time.Sleep(150 * time.Millisecond)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	client := New(WithIngestURL(srv.URL), WithRetry(RetryPolicy{MaxRetries: 10, InitialBackoff: 50 * time.Millisecond}))
+	budget := 500 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), budget)
+	defer cancel()
+
+	start := time.Now()
+	err := client.Upsert(ctx, []Document{{ID: "a", Vector: Vector{0.1}}}, nil)
+	elapsed := time.Since(start)
+
+	if !IsServiceUnavailableError(err) || !strings.Contains(err.Error(), "retry skipped") {
+		t.Fatalf("expected the last 503 with a skipped retry note, got %v", err)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected retries to stop before the deadline, got %v", err)
+	}
+	if elapsed >= budget {
+		t.Fatalf("expected to give up within %v, took %v", budget, elapsed)
+	}
+	// 0-150ms and 200-350ms fit; a third attempt would need 100ms of backoff
+	// plus 150ms with only 150ms left.
+	if calls.Load() != 2 {
+		t.Fatalf("expected 2 attempts, got %d", calls.Load())
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+	plain := errors.Join(ErrServiceUnavailable, &TidepoolError{StatusCode: http.StatusServiceUnavailable})
+	for attempt, want := range []time.Duration{10, 20, 40, 50, 50} {
+		if got := policy.delay(attempt, plain); got != want*time.Millisecond {
+			t.Fatalf("attempt %d: expected %s, got %s", attempt, want*time.Millisecond, got)
+		}
+	}
+
+	throttled := errors.Join(ErrRateLimited, &TidepoolError{StatusCode: http.StatusTooManyRequests, RetryAfter: 3 * time.Second})
+	if got := policy.delay(0, throttled); got != 3*time.Second {
+		t.Fatalf("expected Retry-After to override backoff, got %s", got)
+	}
+}
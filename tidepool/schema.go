@@ -0,0 +1,156 @@
+package tidepool
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// AttrKind is the type of an attribute in a namespace schema registered with
+// WithAttributeSchema.
+type AttrKind int
+
+const (
+	// AttrAny accepts any value; only the key is checked.
+	AttrAny AttrKind = iota
+	// AttrString accepts string values.
+	AttrString
+	// AttrNumber accepts numeric values.
+	AttrNumber
+	// AttrBool accepts boolean values.
+	AttrBool
+)
+
+// String returns the name of the kind.
+func (k AttrKind) String() string {
+	switch k {
+	case AttrAny:
+		return "any"
+	case AttrString:
+		return "string"
+	case AttrNumber:
+		return "number"
+	case AttrBool:
+		return "bool"
+	default:
+		return fmt.Sprintf("AttrKind(%d)", int(k))
+	}
+}
+
+// accepts reports whether value is allowed for an attribute of kind k. Null
+// is always allowed.
+func (k AttrKind) accepts(value AttrValue) bool {
+	if value == nil || k == AttrAny {
+		return true
+	}
+	switch k {
+	case AttrString:
+		_, ok := value.(string)
+		return ok
+	case AttrNumber:
+		switch value.(type) {
+		case float64, float32, int, int8, int16, int32, int64,
+			uint, uint8, uint16, uint32, uint64, json.Number:
+			return true
+		}
+		return false
+	case AttrBool:
+		_, ok := value.(bool)
+		return ok
+	default:
+		return true
+	}
+}
+
+// checkFilters validates the keys and values of filters against the schema
+// registered for namespace. It does nothing when no schema is registered.
+func (c *Client) checkFilters(namespace string, filters Attributes) error {
+	schema, ok := c.config.AttributeSchemas[namespace]
+	if !ok || len(filters) == 0 {
+		return nil
+	}
+	return validateFilterSchema(schema, filters)
+}
+
+// validateFilterSchema walks filters in the shapes produced by FilterBuilder:
+// "$and"/"$or" groups, plain equality, and {"$op": value} conditions, where
+// "$in" and "$nin" take a list of values.
+func validateFilterSchema(schema map[string]AttrKind, filters map[string]AttrValue) error {
+	for key, value := range filters {
+		if key == "$and" || key == "$or" {
+			if reflect.ValueOf(value).Kind() != reflect.Slice {
+				return fmt.Errorf("%w: filter %s must be a list of filters", ErrValidation, key)
+			}
+			for _, sub := range filterList(value) {
+				cond, ok := asFilterMap(sub)
+				if !ok {
+					return fmt.Errorf("%w: filter %s must be a list of filters", ErrValidation, key)
+				}
+				if err := validateFilterSchema(schema, cond); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		kind, ok := schema[key]
+		if !ok {
+			return fmt.Errorf("%w: unknown filter attribute %q", ErrValidation, key)
+		}
+		ops, ok := asFilterMap(value)
+		if !ok {
+			if err := checkFilterValue(key, kind, value); err != nil {
+				return err
+			}
+			continue
+		}
+		for operator, operand := range ops {
+			if !strings.HasPrefix(operator, "$") {
+				return fmt.Errorf("%w: filter attribute %q: %s is not an operator", ErrValidation, key, operator)
+			}
+			values := []AttrValue{operand}
+			if operator == "$in" || operator == "$nin" {
+				values = filterList(operand)
+			}
+			for _, v := range values {
+				if err := checkFilterValue(key, kind, v); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func checkFilterValue(key string, kind AttrKind, value AttrValue) error {
+	if !kind.accepts(value) {
+		return fmt.Errorf("%w: filter attribute %q expects a %s, got %T", ErrValidation, key, kind, value)
+	}
+	return nil
+}
+
+// filterList returns the elements of a list operand such as []AttrValue or
+// []string, or the operand itself when it is not a list.
+func filterList(operand AttrValue) []AttrValue {
+	rv := reflect.ValueOf(operand)
+	if rv.Kind() != reflect.Slice {
+		return []AttrValue{operand}
+	}
+	values := make([]AttrValue, rv.Len())
+	for i := range values {
+		values[i] = rv.Index(i).Interface()
+	}
+	return values
+}
+
+func asFilterMap(value AttrValue) (map[string]AttrValue, bool) {
+	switch v := value.(type) {
+	case Attributes:
+		return v, true
+	case map[string]AttrValue:
+		return v, true
+	default:
+		return nil, false
+	}
+}
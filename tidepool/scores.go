@@ -0,0 +1,69 @@
+package tidepool
+
+import (
+	"cmp"
+	"math"
+	"slices"
+)
+
+// NormalizeScores returns a copy of results with each Score mapped to a 0–1
+// similarity, where 1 is the best match. The caller's slice is not modified.
+// The mapping depends on the metric the scores were computed with:
+//
+//	DistanceCosine:      1 - d, clamped to [0, 1] (cosine similarity)
+//	DistanceEuclidean:   1 / (1 + d)
+//	DistanceEuclideanL2: 1 / (1 + d)
+//	DistanceDotProduct:  1 / (1 + e^-s) (logistic)
+//
+// Scores for any other metric are copied unchanged.
+func NormalizeScores(results []VectorResult, metric DistanceMetric) []VectorResult {
+	if results == nil {
+		return nil
+	}
+	out := make([]VectorResult, len(results))
+	copy(out, results)
+	for i := range out {
+		out[i].Score = normalizeScore(out[i].Score, metric)
+	}
+	return out
+}
+
+func normalizeScore(score float32, metric DistanceMetric) float32 {
+	s := float64(score)
+	switch metric {
+	case DistanceCosine:
+		return float32(math.Min(1, math.Max(0, 1-s)))
+	case DistanceEuclidean, DistanceEuclideanL2:
+		return float32(1 / (1 + math.Max(0, s)))
+	case DistanceDotProduct:
+		return float32(1 / (1 + math.Exp(-s)))
+	default:
+		return score
+	}
+}
+
+// SortResults sorts results in place, best match first, by the polarity of
+// metric: descending scores for DistanceDotProduct and ascending scores for
+// distance metrics. The sort is stable, so equal scores keep their order.
+func SortResults(results []VectorResult, metric DistanceMetric) {
+	sortResults(results, metric.HigherIsBetter())
+}
+
+// sortResults sorts results in place, best match first: descending scores
+// when higher is true, ascending otherwise.
+func sortResults(results []VectorResult, higher bool) {
+	slices.SortStableFunc(results, func(a, b VectorResult) int {
+		if higher {
+			return cmp.Compare(b.Score, a.Score)
+		}
+		return cmp.Compare(a.Score, b.Score)
+	})
+}
+
+// EuclideanDistance converts the result's score from a squared Euclidean
+// distance to a Euclidean distance. Use it for DistanceEuclideanL2 queries
+// against servers that only compute squared distances, so scores are in real
+// distance units. Negative scores are treated as zero.
+func (r VectorResult) EuclideanDistance() float32 {
+	return float32(math.Sqrt(math.Max(0, float64(r.Score))))
+}
@@ -0,0 +1,84 @@
+package tidepool
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNormalizeScores(t *testing.T) {
+	cases := []struct {
+		metric DistanceMetric
+		in     float32
+		want   float32
+	}{
+		{DistanceCosine, 0, 1},
+		{DistanceCosine, 0.25, 0.75},
+		{DistanceCosine, 1.5, 0},
+		{DistanceEuclidean, 0, 1},
+		{DistanceEuclidean, 3, 0.25},
+		{DistanceEuclideanL2, 3, 0.25},
+		{DistanceDotProduct, 0, 0.5},
+		{"custom", 7, 7},
+	}
+	for _, tc := range cases {
+		results := []VectorResult{{ID: "a", Score: tc.in}}
+		got := NormalizeScores(results, tc.metric)
+		if math.Abs(float64(got[0].Score-tc.want)) > 1e-6 {
+			t.Fatalf("%s(%v): expected %v, got %v", tc.metric, tc.in, tc.want, got[0].Score)
+		}
+		if results[0].Score != tc.in {
+			t.Fatalf("expected caller slice to be unchanged")
+		}
+	}
+
+	dot := NormalizeScores([]VectorResult{{Score: 5}, {Score: -5}}, DistanceDotProduct)
+	if !(dot[0].Score > 0.99 && dot[1].Score < 0.01) {
+		t.Fatalf("unexpected dot product normalization: %+v", dot)
+	}
+	if NormalizeScores(nil, DistanceCosine) != nil {
+		t.Fatalf("expected nil for nil input")
+	}
+}
+
+func TestEuclideanDistance(t *testing.T) {
+	if got := (VectorResult{Score: 9}).EuclideanDistance(); got != 3 {
+		t.Fatalf("expected 3, got %v", got)
+	}
+	if got := (VectorResult{Score: -1}).EuclideanDistance(); got != 0 {
+		t.Fatalf("expected negative score to map to 0, got %v", got)
+	}
+	if !DistanceEuclideanL2.Valid() {
+		t.Fatalf("expected euclidean to be a valid metric")
+	}
+}
+
+func TestSortResults(t *testing.T) {
+	ids := func(results []VectorResult) string {
+		var out string
+		for _, r := range results {
+			out += r.ID
+		}
+		return out
+	}
+	newResults := func() []VectorResult {
+		return []VectorResult{{ID: "a", Score: 0.4}, {ID: "b", Score: -2}, {ID: "c", Score: 3}, {ID: "d", Score: 0.4}}
+	}
+
+	cases := map[DistanceMetric]string{
+		DistanceCosine:      "badc",
+		DistanceEuclidean:   "badc",
+		DistanceEuclideanL2: "badc",
+		"":                  "badc",
+		DistanceDotProduct:  "cadb",
+	}
+	for metric, want := range cases {
+		results := newResults()
+		SortResults(results, metric)
+		if got := ids(results); got != want {
+			t.Fatalf("%q: expected order %s, got %s", metric, want, got)
+		}
+		if metric.HigherIsBetter() != (metric == DistanceDotProduct) {
+			t.Fatalf("%q: unexpected HigherIsBetter %v", metric, metric.HigherIsBetter())
+		}
+	}
+}
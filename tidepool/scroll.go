@@ -0,0 +1,111 @@
+package tidepool
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Scroll returns every document stored in namespace, with its vector, sparse
+// vector, text, and attributes as far as the server returns them, for
+// backups and migrations. Documents are listed a page at a time with
+// GET /v1/vectors/{ns}?limit=&cursor= on the query service, and the cursor is
+// advanced internally. The first page is requested before Scroll returns, so
+// an unknown namespace or an unsupported endpoint is reported by the returned
+// error; failures on later pages, including cancellation of ctx, are yielded
+// by the sequence, which then stops. Documents written while scrolling may or
+// may not be included.
+func (c *Client) Scroll(ctx context.Context, namespace string, opts ScrollOptions) (iter.Seq2[Document, error], error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if opts.PageSize < 0 {
+		return nil, fmt.Errorf("%w: page size must be a positive integer", ErrValidation)
+	}
+	resolved, err := c.namespaceOrDefault(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	first, next, err := c.scrollPage(ctx, resolved, opts.PageSize, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return func(yield func(Document, error) bool) {
+		page, cursor := first, ""
+		for {
+			for _, doc := range page {
+				if !yield(doc, nil) {
+					return
+				}
+			}
+			if next == "" {
+				return
+			}
+			if next == cursor {
+				yield(Document{}, fmt.Errorf("scroll: server repeated cursor %q", next))
+				return
+			}
+			if err := ctx.Err(); err != nil {
+				yield(Document{}, err)
+				return
+			}
+			cursor = next
+			var err error
+			page, next, err = c.scrollPage(ctx, resolved, opts.PageSize, cursor)
+			if err != nil {
+				yield(Document{}, err)
+				return
+			}
+		}
+	}, nil
+}
+
+// scrollPage fetches one page of documents and the cursor for the next page.
+func (c *Client) scrollPage(ctx context.Context, namespace string, limit int, cursor string) (_ []Document, next string, err error) {
+	ctx, op := c.startOperation(ctx, "Scroll")
+	defer func() { op.end(err) }()
+	op.setNamespace(namespace)
+
+	endpoint, err := c.queryVectorsEndpoint(namespace)
+	if err != nil {
+		return nil, "", err
+	}
+	params := url.Values{}
+	if limit > 0 {
+		params.Set("limit", strconv.Itoa(limit))
+	}
+	if cursor != "" {
+		params.Set("cursor", cursor)
+	}
+	if len(params) > 0 {
+		endpoint += "?" + params.Encode()
+	}
+
+	body, err := c.doRequest(ctx, "query", http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := decodeQueryResponse(body, namespace, c.config.UseNumber)
+	if err != nil {
+		return nil, "", err
+	}
+	c.warn("Scroll", resp.Warnings)
+	op.setResultCount(len(resp.Results))
+
+	docs := make([]Document, len(resp.Results))
+	for i, result := range resp.Results {
+		docs[i] = Document{
+			ID:           result.ID,
+			Vector:       result.Vector,
+			SparseVector: result.SparseVector,
+			Text:         result.Text,
+			Attributes:   result.Attributes,
+		}
+	}
+	return docs, resp.NextCursor, nil
+}
@@ -0,0 +1,170 @@
+package tidepool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestScroll(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		queries []string
+		failAt  string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Path != "/v1/vectors/backup" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		queries = append(queries, r.URL.RawQuery)
+		cursor := r.URL.Query().Get("cursor")
+		if cursor != "" && cursor == failAt {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		start, _ := strconv.Atoi(cursor)
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		var results []VectorResult
+		for i := start; i < min(start+limit, 5); i++ {
+			id := strconv.Itoa(i)
+			results = append(results, VectorResult{ID: id, Vector: Vector{float32(i)}, Attributes: Attributes{"n": i}})
+		}
+		next := ""
+		if start+limit < 5 {
+			next = strconv.Itoa(start + limit)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"results": results, "next_cursor": next})
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	client := New(WithQueryURL(srv.URL))
+	docs, err := client.Scroll(ctx, "backup", ScrollOptions{PageSize: 2})
+	if err != nil {
+		t.Fatalf("scroll failed: %v", err)
+	}
+	var ids []string
+	for doc, err := range docs {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(doc.Vector) != 1 || doc.Attributes["n"] == nil {
+			t.Fatalf("expected vector and attributes, got %+v", doc)
+		}
+		ids = append(ids, doc.ID)
+	}
+	if len(ids) != 5 || ids[0] != "0" || ids[4] != "4" {
+		t.Fatalf("expected all 5 documents in order, got %v", ids)
+	}
+	if len(queries) != 3 || queries[0] != "limit=2" || queries[2] != "cursor=4&limit=2" {
+		t.Fatalf("expected 3 paged requests, got %v", queries)
+	}
+
+	queries = nil
+	docs, err = client.Scroll(ctx, "backup", ScrollOptions{PageSize: 2})
+	if err != nil {
+		t.Fatalf("scroll failed: %v", err)
+	}
+	for range docs {
+		break
+	}
+	if len(queries) != 1 {
+		t.Fatalf("expected breaking early to stop paging, got %d requests", len(queries))
+	}
+
+	failAt = "2"
+	docs, err = client.Scroll(ctx, "backup", ScrollOptions{PageSize: 2})
+	if err != nil {
+		t.Fatalf("scroll failed: %v", err)
+	}
+	var yielded int
+	var pageErr error
+	for _, err := range docs {
+		if err != nil {
+			pageErr = err
+			break
+		}
+		yielded++
+	}
+	if yielded != 2 || !errors.Is(pageErr, ErrServer) {
+		t.Fatalf("expected 2 documents then a server error, got %d and %v", yielded, pageErr)
+	}
+
+	cancelCtx, cancel := context.WithCancel(ctx)
+	failAt = ""
+	docs, err = client.Scroll(cancelCtx, "backup", ScrollOptions{PageSize: 2})
+	if err != nil {
+		t.Fatalf("scroll failed: %v", err)
+	}
+	cancel()
+	pageErr = nil
+	for _, err := range docs {
+		if err != nil {
+			pageErr = err
+		}
+	}
+	if !errors.Is(pageErr, context.Canceled) {
+		t.Fatalf("expected cancellation to stop the scroll, got %v", pageErr)
+	}
+
+	if _, err := client.Scroll(ctx, "missing", ScrollOptions{}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected not found from the first page, got %v", err)
+	}
+	if _, err := client.Scroll(ctx, "backup", ScrollOptions{PageSize: -1}); !IsValidationError(err) {
+		t.Fatalf("expected validation error for negative page size, got %v", err)
+	}
+}
+
+func TestMigrateCarriesTextAndSkipsEmptyDocuments(t *testing.T) {
+	src := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"results":[
+			{"id":"text","text":"hello","attributes":{"lang":"en"}},
+			{"id":"sparse","sparse_vector":{"indices":[3],"values":[0.5]}},
+			{"id":"empty","attributes":{"lang":"en"}}
+		]}`))
+	}))
+	defer src.Close()
+	var written []Document
+	dst := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Vectors []Document `json:"vectors"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		written = append(written, req.Vectors...)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer dst.Close()
+
+	ctx := context.Background()
+	docs, err := New(WithQueryURL(src.URL)).Scroll(ctx, "docs", ScrollOptions{})
+	if err != nil {
+		t.Fatalf("scroll failed: %v", err)
+	}
+	for doc, err := range docs {
+		if err == nil && doc.ID == "text" && doc.Text != "hello" {
+			t.Fatalf("expected scroll to carry text, got %+v", doc)
+		}
+	}
+
+	var skipped []string
+	copied, err := Migrate(ctx, New(WithQueryURL(src.URL)), New(WithIngestURL(dst.URL)), "docs", MigrateOptions{
+		OnSkip: func(id string) { skipped = append(skipped, id) },
+	})
+	if err != nil || copied != 2 {
+		t.Fatalf("expected 2 documents copied, got %d: %v", copied, err)
+	}
+	if len(written) != 2 || written[0].Text != "hello" || written[1].SparseVector == nil || written[1].SparseVector.Indices[0] != 3 {
+		t.Fatalf("expected text and sparse vector to be copied, got %+v", written)
+	}
+	if len(skipped) != 1 || skipped[0] != "empty" {
+		t.Fatalf("expected the empty document to be skipped, got %v", skipped)
+	}
+}
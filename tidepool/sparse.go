@@ -0,0 +1,28 @@
+package tidepool
+
+import "sort"
+
+// SparseVector is a sparse vector, such as a bag-of-words term weighting,
+// given as parallel index and value slices. It is sent alongside the dense
+// vector for hybrid search.
+type SparseVector struct {
+	Indices []uint32  `json:"indices"`
+	Values  []float32 `json:"values"`
+}
+
+// NewSparseVector builds a SparseVector from an index-to-value map, with
+// indices in ascending order.
+func NewSparseVector(weights map[uint32]float32) *SparseVector {
+	sv := &SparseVector{
+		Indices: make([]uint32, 0, len(weights)),
+		Values:  make([]float32, 0, len(weights)),
+	}
+	for index := range weights {
+		sv.Indices = append(sv.Indices, index)
+	}
+	sort.Slice(sv.Indices, func(i, j int) bool { return sv.Indices[i] < sv.Indices[j] })
+	for _, index := range sv.Indices {
+		sv.Values = append(sv.Values, weights[index])
+	}
+	return sv
+}
@@ -0,0 +1,92 @@
+package tidepool
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewSparseVector(t *testing.T) {
+	sv := NewSparseVector(map[uint32]float32{7: 0.5, 2: 1.5, 40: 0.25})
+	if !reflect.DeepEqual(sv.Indices, []uint32{2, 7, 40}) || !reflect.DeepEqual(sv.Values, []float32{1.5, 0.5, 0.25}) {
+		t.Fatalf("unexpected sparse vector: %+v", sv)
+	}
+	if err := ValidateSparseVector(sv); err != nil {
+		t.Fatalf("expected valid sparse vector, got %v", err)
+	}
+}
+
+func TestValidateSparseVector(t *testing.T) {
+	cases := map[string]*SparseVector{
+		"nil":       nil,
+		"empty":     {},
+		"mismatch":  {Indices: []uint32{1, 2}, Values: []float32{1}},
+		"duplicate": {Indices: []uint32{1, 1}, Values: []float32{1, 2}},
+		"nan":       {Indices: []uint32{1}, Values: []float32{float32(math.NaN())}},
+		"inf":       {Indices: []uint32{1}, Values: []float32{float32(math.Inf(1))}},
+	}
+	for name, sv := range cases {
+		if err := ValidateSparseVector(sv); !IsValidationError(err) {
+			t.Fatalf("%s: expected validation error, got %v", name, err)
+		}
+	}
+}
+
+func TestSparseVectorPayloads(t *testing.T) {
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("decode body: %v", err)
+		}
+		if vectors, ok := body["vectors"]; ok {
+			bodies = append(bodies, string(vectors))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		bodies = append(bodies, string(body["sparse_vector"]))
+		_ = json.NewEncoder(w).Encode([]VectorResult{{ID: "a"}})
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	client := New(WithQueryURL(srv.URL), WithIngestURL(srv.URL))
+	sparse := &SparseVector{Indices: []uint32{3, 9}, Values: []float32{0.5, 1}}
+
+	err := client.Upsert(ctx, []Document{
+		{ID: "a", Vector: Vector{1}, SparseVector: sparse},
+		{ID: "b", Vector: Vector{1}},
+	}, nil)
+	if err != nil {
+		t.Fatalf("upsert failed: %v", err)
+	}
+	if _, err := client.Query(ctx, Vector{1}, &QueryOptions{SparseVector: sparse}); err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+
+	if len(bodies) != 2 {
+		t.Fatalf("expected two requests, got %d", len(bodies))
+	}
+	if !strings.Contains(bodies[0], `"sparse_vector":{"indices":[3,9],"values":[0.5,1]}`) || strings.Count(bodies[0], "sparse_vector") != 1 {
+		t.Fatalf("unexpected upsert vectors: %s", bodies[0])
+	}
+	if bodies[1] != `{"indices":[3,9],"values":[0.5,1]}` {
+		t.Fatalf("unexpected query sparse vector: %s", bodies[1])
+	}
+
+	bad := &SparseVector{Indices: []uint32{1, 1}, Values: []float32{1, 1}}
+	if err := client.Upsert(ctx, []Document{{ID: "c", Vector: Vector{1}, SparseVector: bad}}, nil); !IsValidationError(err) {
+		t.Fatalf("expected validation error for upsert, got %v", err)
+	}
+	if _, err := client.Query(ctx, Vector{1}, &QueryOptions{SparseVector: bad}); !IsValidationError(err) {
+		t.Fatalf("expected validation error for query, got %v", err)
+	}
+	if len(bodies) != 2 {
+		t.Fatalf("expected invalid sparse vectors not to be sent")
+	}
+}
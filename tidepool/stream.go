@@ -0,0 +1,232 @@
+package tidepool
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// QueryStream runs a query like Query but decodes results one at a time off
+// the response body instead of buffering it, which keeps memory flat for
+// large TopK queries with IncludeVectors. The returned error covers building
+// and sending the request; decode failures are yielded by the sequence.
+//
+// The response body is closed when the range loop ends, whether it runs to
+// completion, breaks early, or stops at an error. A caller that decides not
+// to read the results at all must still range over the sequence (breaking on
+// the first element) or cancel ctx; otherwise the connection leaks. When a
+// loop ends early, up to 256 KiB of the remaining body is drained so the
+// connection can be reused. The sequence can be iterated only once.
+// DedupeBy and Rerank are not applied, and the page cursor and took_ms of a
+// wrapped response are not exposed.
+func (c *Client) QueryStream(ctx context.Context, vector Vector, opts *QueryOptions) (_ iter.Seq2[VectorResult, error], err error) {
+	ctx, op := c.startOperation(ctx, "QueryStream")
+	defer func() { op.end(err) }()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	_, endpoint, req, err := c.prepareQuery(ctx, op, vector, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := c.doStream(ctx, "query", http.MethodPost, endpoint, req)
+	if err != nil {
+		return nil, err
+	}
+
+	used := false
+	return func(yield func(VectorResult, error) bool) {
+		defer drainAndClose(body)
+		if used {
+			yield(VectorResult{}, errors.New("query stream: already consumed"))
+			return
+		}
+		used = true
+		decodeResultStream(json.NewDecoder(body), c.config.UseNumber, func(result VectorResult, err error) bool {
+			if err == nil && !req.keepsScore(result.Score) {
+				return true
+			}
+			return yield(result, err)
+		})
+	}, nil
+}
+
+// doStream sends a request like doRequest but returns the open response body
+// of a successful response instead of reading it. Retries apply only until a
+// response is accepted.
+func (c *Client) doStream(ctx context.Context, service, method, endpoint string, body any) (io.ReadCloser, error) {
+	data, payload, compressed, err := c.encodeBody(body)
+	if err != nil {
+		return nil, err
+	}
+
+	var respBody io.ReadCloser
+	err = c.withRetries(ctx, service, method, func() error {
+		var err error
+		respBody, err = c.sendStream(ctx, method, endpoint, data, payload, compressed)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return respBody, nil
+}
+
+// sendStream performs a single HTTP attempt and returns the (decompressed)
+// response body when the status is successful. Error responses are read and
+// mapped like in send. The request is logged when the response arrives,
+// without a response body.
+func (c *Client) sendStream(ctx context.Context, method, endpoint string, data, payload []byte, compressed bool) (_ io.ReadCloser, err error) {
+	req, err := c.newRequest(ctx, method, endpoint, data, payload, compressed)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		statusCode int
+		errBody    []byte
+	)
+	if c.config.Logger != nil {
+		start := time.Now()
+		defer func() {
+			c.logRequest(ctx, req, data, errBody, statusCode, time.Since(start), err)
+		}()
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, wrapTransportError(ctx, err)
+	}
+	statusCode = resp.StatusCode
+	recordStatus(ctx, statusCode)
+
+	if resp.StatusCode >= 400 || !isJSONContentType(resp.Header.Get("Content-Type")) {
+		defer resp.Body.Close()
+		errBody, err = readResponseBody(resp, c.config.MaxResponseBytes)
+		if err != nil {
+			return nil, fmt.Errorf("read response: %w", err)
+		}
+		if resp.StatusCode >= 400 {
+			return nil, c.handleError(resp.StatusCode, resp.Header, errBody)
+		}
+		return nil, checkContentType(resp.Header.Get("Content-Type"), errBody)
+	}
+
+	if !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		return resp.Body, nil
+	}
+	zr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("read response: decompress: %w", err)
+	}
+	return &gzipBody{Reader: zr, body: resp.Body}, nil
+}
+
+// drainAndClose discards up to streamDrainLimit bytes of what is left of body
+// before closing it, so that an early-abandoned response does not prevent
+// its connection from going back to the idle pool.
+func drainAndClose(body io.ReadCloser) {
+	_, _ = io.CopyN(io.Discard, body, streamDrainLimit)
+	_ = body.Close()
+}
+
+// gzipBody closes both the gzip reader and the underlying response body.
+type gzipBody struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+func (b *gzipBody) Close() error {
+	return errors.Join(b.Reader.Close(), b.body.Close())
+}
+
+// decodeResultStream yields the results of a query response read from dec.
+// It accepts the same shapes as decodeQueryResponse: a bare array, or an
+// object whose "results" (or "vectors") field holds the array. With
+// useNumber, attribute numbers are kept as json.Number.
+func decodeResultStream(dec *json.Decoder, useNumber bool, yield func(VectorResult, error) bool) {
+	fail := func(err error) {
+		yield(VectorResult{}, fmt.Errorf("decode query response: %w", err))
+	}
+
+	tok, err := dec.Token()
+	if err != nil {
+		fail(err)
+		return
+	}
+	switch tok {
+	case json.Delim('['):
+		decodeResultArray(dec, useNumber, yield, fail)
+		return
+	case json.Delim('{'):
+	default:
+		fail(fmt.Errorf("unexpected token %v", tok))
+		return
+	}
+
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			fail(err)
+			return
+		}
+		if key, _ := tok.(string); key == "results" || key == "vectors" {
+			tok, err := dec.Token()
+			if err != nil {
+				fail(err)
+				return
+			}
+			if tok == nil {
+				// A null list is a query without matches.
+				return
+			}
+			if tok != json.Delim('[') {
+				fail(fmt.Errorf("%s: expected array, got %v", key, tok))
+				return
+			}
+			// Stop once the results have been read; the remaining fields
+			// (cursor, timing) are not surfaced by the stream.
+			decodeResultArray(dec, useNumber, yield, fail)
+			return
+		}
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			fail(err)
+			return
+		}
+	}
+	fail(errors.New("missing results"))
+}
+
+// decodeResultArray yields array elements until the closing bracket. The
+// opening bracket must already have been consumed.
+func decodeResultArray(dec *json.Decoder, useNumber bool, yield func(VectorResult, error) bool, fail func(error)) {
+	for dec.More() {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			fail(err)
+			return
+		}
+		var result VectorResult
+		if err := result.decode(raw, useNumber); err != nil {
+			fail(err)
+			return
+		}
+		if !yield(result, nil) {
+			return
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		fail(err)
+	}
+}
@@ -0,0 +1,195 @@
+package tidepool
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestQueryStreamShapes(t *testing.T) {
+	responses := map[string]string{
+		"array":   `[{"id":"a","score":0.1,"vector":[1,2]},{"id":"b","dist":0.2}]`,
+		"wrapped": `{"namespace":"default","took_ms":3,"results":[{"id":"a","score":0.1,"vector":[1,2]},{"id":"b","dist":0.2}],"next_cursor":"c"}`,
+		"vectors": `{"extra":{"nested":[1,2,3]},"vectors":[{"id":"a","score":0.1,"vector":[1,2]},{"id":"b","dist":0.2}]}`,
+		"gzip":    `[{"id":"a","score":0.1,"vector":[1,2]},{"id":"b","dist":0.2}]`,
+	}
+	for _, empty := range []string{`[]`, `{"results":[]}`, `{"namespace":"x","results":null}`} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(empty))
+		}))
+		seq, err := New(WithQueryURL(srv.URL)).QueryStream(context.Background(), Vector{1}, nil)
+		if err != nil {
+			t.Fatalf("%s: query stream failed: %v", empty, err)
+		}
+		for result, err := range seq {
+			t.Fatalf("%s: expected no results, got %+v: %v", empty, result, err)
+		}
+		srv.Close()
+	}
+	for name, payload := range responses {
+		t.Run(name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body map[string]any
+				_ = json.NewDecoder(r.Body).Decode(&body)
+				if body["include_vectors"] != true {
+					t.Errorf("expected include_vectors in request, got %v", body)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				if name == "gzip" {
+					w.Header().Set("Content-Encoding", "gzip")
+					zw := gzip.NewWriter(w)
+					_, _ = zw.Write([]byte(payload))
+					_ = zw.Close()
+					return
+				}
+				_, _ = w.Write([]byte(payload))
+			}))
+			defer srv.Close()
+
+			client := New(WithQueryURL(srv.URL))
+			seq, err := client.QueryStream(context.Background(), Vector{1, 2}, &QueryOptions{IncludeVectors: Bool(true)})
+			if err != nil {
+				t.Fatalf("query stream failed: %v", err)
+			}
+			var results []VectorResult
+			for result, err := range seq {
+				if err != nil {
+					t.Fatalf("stream error: %v", err)
+				}
+				results = append(results, result)
+			}
+			if len(results) != 2 || results[0].ID != "a" || len(results[0].Vector) != 2 || results[1].Score != 0.2 {
+				t.Fatalf("unexpected results: %+v", results)
+			}
+		})
+	}
+}
+
+func TestQueryStreamBreakAndErrors(t *testing.T) {
+	payload := `[{"id":"a"},{"id":"b"},{"id":"c"}]`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/vectors/missing":
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":"namespace not found"}`))
+		case "/v1/vectors/truncated":
+			_, _ = w.Write([]byte(`{"results":[{"id":"a"},{"id":`))
+		case "/v1/vectors/html":
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte("<html>proxy error</html>"))
+		default:
+			_, _ = w.Write([]byte(payload))
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	client := New(WithQueryURL(srv.URL))
+
+	seq, err := client.QueryStream(ctx, Vector{1}, nil)
+	if err != nil {
+		t.Fatalf("query stream failed: %v", err)
+	}
+	var ids []string
+	for result, err := range seq {
+		if err != nil {
+			t.Fatalf("stream error: %v", err)
+		}
+		ids = append(ids, result.ID)
+		if len(ids) == 2 {
+			break
+		}
+	}
+	if strings.Join(ids, ",") != "a,b" {
+		t.Fatalf("expected to stop after two results, got %v", ids)
+	}
+	for _, err := range seq {
+		if err == nil || !strings.Contains(err.Error(), "already consumed") {
+			t.Fatalf("expected second iteration to fail, got %v", err)
+		}
+	}
+
+	if _, err := client.QueryStream(ctx, Vector{1}, &QueryOptions{Namespace: "missing"}); !IsNotFoundError(err) {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+	if _, err := client.QueryStream(ctx, Vector{1}, &QueryOptions{Namespace: "html"}); err == nil || !strings.Contains(err.Error(), "content type") {
+		t.Fatalf("expected content type error, got %v", err)
+	}
+	if _, err := client.QueryStream(ctx, nil, nil); !IsValidationError(err) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+
+	seq, err = client.QueryStream(ctx, Vector{1}, &QueryOptions{Namespace: "truncated"})
+	if err != nil {
+		t.Fatalf("query stream failed: %v", err)
+	}
+	var (
+		count   int
+		lastErr error
+	)
+	for _, err := range seq {
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		count++
+	}
+	if count != 1 || lastErr == nil || !strings.Contains(lastErr.Error(), "decode query response") {
+		t.Fatalf("expected one result then a decode error, got %d results and %v", count, lastErr)
+	}
+}
+
+func TestQueryStreamReusesConnectionsWhenAbandoned(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		newConns int
+	)
+	results := make([]VectorResult, 2000)
+	for i := range results {
+		results[i] = VectorResult{ID: fmt.Sprintf("doc-%d", i), Vector: Vector{1, 2, 3, 4}}
+	}
+	payload, err := json.Marshal(map[string]any{"results": results})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(payload)
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mu.Lock()
+			newConns++
+			mu.Unlock()
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	client := New(WithQueryURL(srv.URL), WithHTTPClient(&http.Client{Transport: &http.Transport{}}))
+	for i := 0; i < 100; i++ {
+		seq, err := client.QueryStream(context.Background(), Vector{1}, nil)
+		if err != nil {
+			t.Fatalf("query stream %d failed: %v", i, err)
+		}
+		for result, err := range seq {
+			if err != nil || result.ID != "doc-0" {
+				t.Fatalf("unexpected first result %+v: %v", result, err)
+			}
+			break
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if newConns != 1 {
+		t.Fatalf("expected abandoned streams to reuse one connection, opened %d", newConns)
+	}
+}
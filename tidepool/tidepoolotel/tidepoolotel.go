@@ -0,0 +1,59 @@
+// Package tidepoolotel adds OpenTelemetry tracing to the Tidepool client.
+//
+// It lives in its own package so the core tidepool package stays free of
+// OpenTelemetry dependencies.
+package tidepoolotel
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/milannair/tidepool-go/tidepool"
+)
+
+const instrumentationName = "github.com/milannair/tidepool-go/tidepool/tidepoolotel"
+
+// WithTracerProvider starts a client span per operation, named after the
+// method (e.g. "tidepool.Query"), as a child of the span in the call's context.
+// Spans carry the namespace, top_k, result count, and HTTP status code, and
+// record errors.
+func WithTracerProvider(tp trace.TracerProvider) tidepool.Option {
+	return tidepool.WithInstrumenter(&tracer{
+		tracer: tp.Tracer(instrumentationName, trace.WithInstrumentationVersion(tidepool.Version)),
+	})
+}
+
+type tracer struct {
+	tracer trace.Tracer
+}
+
+func (t *tracer) StartOperation(ctx context.Context, name string) (context.Context, func(tidepool.OperationInfo)) {
+	ctx, span := t.tracer.Start(ctx, "tidepool."+name, trace.WithSpanKind(trace.SpanKindClient))
+	return ctx, func(info tidepool.OperationInfo) {
+		attrs := []attribute.KeyValue{
+			attribute.String("tidepool.operation", info.Name),
+		}
+		if info.Namespace != "" {
+			attrs = append(attrs, attribute.String("tidepool.namespace", info.Namespace))
+		}
+		if info.TopK > 0 {
+			attrs = append(attrs, attribute.Int("tidepool.top_k", info.TopK))
+		}
+		if info.Err == nil {
+			attrs = append(attrs, attribute.Int("tidepool.result_count", info.ResultCount))
+		}
+		if info.StatusCode != 0 {
+			attrs = append(attrs, attribute.Int("http.response.status_code", info.StatusCode))
+		}
+		span.SetAttributes(attrs...)
+
+		if info.Err != nil {
+			span.RecordError(info.Err)
+			span.SetStatus(codes.Error, info.Err.Error())
+		}
+		span.End()
+	}
+}
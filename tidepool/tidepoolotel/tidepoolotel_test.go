@@ -0,0 +1,86 @@
+package tidepoolotel
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/milannair/tidepool-go/tidepool"
+)
+
+func spanAttr(span sdktrace.ReadOnlySpan, key string) (attribute.Value, bool) {
+	for _, kv := range span.Attributes() {
+		if string(kv.Key) == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestTracerProviderSpans(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_ = json.NewEncoder(w).Encode([]tidepool.VectorResult{{ID: "a"}, {ID: "b"}})
+	}))
+	defer srv.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	client := tidepool.New(
+		tidepool.WithQueryURL(srv.URL),
+		tidepool.WithIngestURL(srv.URL),
+		WithTracerProvider(tp),
+	)
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+	if _, err := client.Query(ctx, tidepool.Vector{0.1}, &tidepool.QueryOptions{Namespace: "docs", TopK: 5}); err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if err := client.Delete(ctx, []string{"a"}, &tidepool.DeleteOptions{Namespace: "docs"}); err == nil {
+		t.Fatalf("expected delete to fail")
+	}
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans, got %d", len(spans))
+	}
+
+	query := spans[0]
+	if query.Name() != "tidepool.Query" {
+		t.Fatalf("expected tidepool.Query span, got %q", query.Name())
+	}
+	if query.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Fatalf("expected query span to be a child of the context span")
+	}
+	if v, _ := spanAttr(query, "tidepool.namespace"); v.AsString() != "docs" {
+		t.Fatalf("expected namespace attribute docs, got %v", v.AsString())
+	}
+	if v, _ := spanAttr(query, "tidepool.top_k"); v.AsInt64() != 5 {
+		t.Fatalf("expected top_k attribute 5, got %v", v.AsInt64())
+	}
+	if v, _ := spanAttr(query, "tidepool.result_count"); v.AsInt64() != 2 {
+		t.Fatalf("expected result_count attribute 2, got %v", v.AsInt64())
+	}
+	if v, _ := spanAttr(query, "http.response.status_code"); v.AsInt64() != http.StatusOK {
+		t.Fatalf("expected status code attribute 200, got %v", v.AsInt64())
+	}
+
+	del := spans[1]
+	if del.Name() != "tidepool.Delete" || del.Status().Code != codes.Error {
+		t.Fatalf("expected errored tidepool.Delete span, got %q %v", del.Name(), del.Status())
+	}
+	if len(del.Events()) == 0 {
+		t.Fatalf("expected error to be recorded on the span")
+	}
+}
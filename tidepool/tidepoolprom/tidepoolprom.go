@@ -0,0 +1,98 @@
+// Package tidepoolprom records Prometheus metrics for Tidepool client
+// operations.
+//
+// It lives in its own package so the core tidepool package stays free of
+// Prometheus dependencies.
+package tidepoolprom
+
+import (
+	"context"
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/milannair/tidepool-go/tidepool"
+)
+
+// Error categories used for the "category" label of the errors counter.
+const (
+	CategoryValidation  = "validation"
+	CategoryNotFound    = "not_found"
+	CategoryUnavailable = "unavailable"
+	CategoryOther       = "other"
+)
+
+type metrics struct {
+	requests *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+}
+
+// WithMetrics records per-operation request counts, error counts by category,
+// and latency histograms labeled by operation and namespace:
+//
+//	tidepool_client_requests_total{operation,namespace}
+//	tidepool_client_errors_total{operation,namespace,category}
+//	tidepool_client_request_duration_seconds{operation,namespace}
+//
+// Collectors already registered on registerer (e.g. by another client) are
+// reused. Like prometheus.MustRegister, it panics on any other registration
+// error.
+func WithMetrics(registerer prometheus.Registerer) tidepool.Option {
+	return tidepool.WithInstrumenter(newMetrics(registerer))
+}
+
+func newMetrics(registerer prometheus.Registerer) *metrics {
+	return &metrics{
+		requests: register(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tidepool_client_requests_total",
+			Help: "Number of Tidepool client operations.",
+		}, []string{"operation", "namespace"})),
+		errors: register(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tidepool_client_errors_total",
+			Help: "Number of failed Tidepool client operations by error category.",
+		}, []string{"operation", "namespace", "category"})),
+		latency: register(registerer, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "tidepool_client_request_duration_seconds",
+			Help:    "Latency of Tidepool client operations.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"operation", "namespace"})),
+	}
+}
+
+func register[C prometheus.Collector](registerer prometheus.Registerer, collector C) C {
+	if err := registerer.Register(collector); err != nil {
+		var already prometheus.AlreadyRegisteredError
+		if errors.As(err, &already) {
+			if existing, ok := already.ExistingCollector.(C); ok {
+				return existing
+			}
+		}
+		panic(err)
+	}
+	return collector
+}
+
+func (m *metrics) StartOperation(ctx context.Context, name string) (context.Context, func(tidepool.OperationInfo)) {
+	return ctx, func(info tidepool.OperationInfo) {
+		m.requests.WithLabelValues(info.Name, info.Namespace).Inc()
+		m.latency.WithLabelValues(info.Name, info.Namespace).Observe(info.Duration.Seconds())
+		if info.Err != nil {
+			m.errors.WithLabelValues(info.Name, info.Namespace, Category(info.Err)).Inc()
+		}
+	}
+}
+
+// Category classifies err into one of the error categories.
+func Category(err error) string {
+	switch {
+	case tidepool.IsValidationError(err):
+		return CategoryValidation
+	case tidepool.IsNotFoundError(err):
+		return CategoryNotFound
+	case tidepool.IsServiceUnavailableError(err):
+		return CategoryUnavailable
+	default:
+		return CategoryOther
+	}
+}
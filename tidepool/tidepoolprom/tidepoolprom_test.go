@@ -0,0 +1,57 @@
+package tidepoolprom
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/milannair/tidepool-go/tidepool"
+)
+
+func TestWithMetrics(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode([]tidepool.VectorResult{{ID: "a"}})
+	}))
+	defer srv.Close()
+
+	reg := prometheus.NewRegistry()
+	m := newMetrics(reg)
+	client := tidepool.New(
+		tidepool.WithQueryURL(srv.URL),
+		tidepool.WithIngestURL(srv.URL),
+		tidepool.WithInstrumenter(m),
+	)
+	// A second client sharing the registry reuses the collectors.
+	_ = tidepool.New(WithMetrics(reg))
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if _, err := client.Query(ctx, tidepool.Vector{0.1}, &tidepool.QueryOptions{Namespace: "docs"}); err != nil {
+			t.Fatalf("query failed: %v", err)
+		}
+	}
+	_ = client.Delete(ctx, []string{"a"}, &tidepool.DeleteOptions{Namespace: "docs"})
+	_, _ = client.Query(ctx, nil, &tidepool.QueryOptions{Namespace: "docs"})
+
+	if got := testutil.ToFloat64(m.requests.WithLabelValues("Query", "docs")); got != 3 {
+		t.Fatalf("expected 3 query requests, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.errors.WithLabelValues("Delete", "docs", CategoryNotFound)); got != 1 {
+		t.Fatalf("expected 1 not-found delete error, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.errors.WithLabelValues("Query", "docs", CategoryValidation)); got != 1 {
+		t.Fatalf("expected 1 validation query error, got %v", got)
+	}
+	if n := testutil.CollectAndCount(reg, "tidepool_client_request_duration_seconds"); n != 2 {
+		t.Fatalf("expected latency series for Query and Delete, got %d", n)
+	}
+}
@@ -0,0 +1,580 @@
+// Package tidepooltest provides an in-memory fake of the Tidepool query and
+// ingest services for unit testing code built on the tidepool client.
+//
+// The fake is an http.RoundTripper, so no network is involved:
+//
+//	srv := tidepooltest.NewServer()
+//	srv.Seed("products", tidepool.Document{ID: "a", Vector: tidepool.Vector{1, 0}})
+//	client := tidepool.New(tidepool.WithHTTPClient(srv.Client()))
+//
+// Queries are answered by brute-force cosine search and scored by cosine
+// distance, so lower scores are closer. Only vector queries and equality
+// filters are supported; text and hybrid queries are rejected with 400.
+// Endpoints the fake does not implement return 404.
+package tidepooltest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/milannair/tidepool-go/tidepool"
+)
+
+const (
+	defaultTopK        = 10
+	defaultScrollLimit = 100
+)
+
+// Request is a request recorded by Server.
+type Request struct {
+	Method string
+	// Path is the URL path, e.g. "/v1/vectors/default".
+	Path string
+	// RawQuery is the encoded URL query string without the leading '?'.
+	RawQuery string
+	Header   http.Header
+	// Body is the decompressed request body.
+	Body []byte
+}
+
+// Server is an in-memory Tidepool fake. It is safe for concurrent use.
+type Server struct {
+	mu         sync.Mutex
+	namespaces map[string]*namespace
+	requests   []Request
+}
+
+type namespace struct {
+	dimensions int
+	docs       map[string]tidepool.Document
+}
+
+// NewServer returns an empty fake.
+func NewServer() *Server {
+	return &Server{namespaces: make(map[string]*namespace)}
+}
+
+// NewClient returns an HTTP client backed by a new, empty fake. Use NewServer
+// instead when the test needs to seed data or inspect requests.
+func NewClient() *http.Client {
+	return NewServer().Client()
+}
+
+// Client returns an HTTP client that sends every request to s.
+func (s *Server) Client() *http.Client {
+	return &http.Client{Transport: s}
+}
+
+// Seed stores docs in the namespace, creating it if needed.
+func (s *Server) Seed(ns string, docs ...tidepool.Document) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.upsert(ns, docs)
+}
+
+// Documents returns the documents stored in the namespace, sorted by ID.
+func (s *Server) Documents(ns string) []tidepool.Document {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := s.namespaces[ns]
+	if n == nil {
+		return nil
+	}
+	docs := make([]tidepool.Document, 0, len(n.docs))
+	for _, doc := range n.docs {
+		docs = append(docs, doc)
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].ID < docs[j].ID })
+	return docs
+}
+
+// Requests returns the requests received so far, oldest first.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// RoundTrip implements http.RoundTripper.
+func (s *Server) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		defer req.Body.Close()
+		var reader io.Reader = req.Body
+		if req.Header.Get("Content-Encoding") == "gzip" {
+			gz, err := gzip.NewReader(req.Body)
+			if err != nil {
+				return nil, fmt.Errorf("tidepooltest: decompress request: %w", err)
+			}
+			defer gz.Close()
+			reader = gz
+		}
+		var err error
+		body, err = io.ReadAll(reader)
+		if err != nil {
+			return nil, fmt.Errorf("tidepooltest: read request: %w", err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, Request{
+		Method:   req.Method,
+		Path:     req.URL.Path,
+		RawQuery: req.URL.RawQuery,
+		Header:   req.Header.Clone(),
+		Body:     body,
+	})
+
+	status, payload := s.handle(req, body)
+	var data []byte
+	if req.Method != http.MethodHead {
+		var err error
+		data, err = json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("tidepooltest: encode response: %w", err)
+		}
+	}
+	return &http.Response{
+		StatusCode:    status,
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(data)),
+		ContentLength: int64(len(data)),
+		Request:       req,
+	}, nil
+}
+
+// handle routes a request and returns the status code and JSON payload.
+// s.mu must be held.
+func (s *Server) handle(req *http.Request, body []byte) (int, any) {
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+
+	switch {
+	case len(parts) == 1 && parts[0] == "health" && req.Method == http.MethodGet:
+		return http.StatusOK, tidepool.HealthResponse{Service: "tidepooltest", Status: "ok"}
+	case len(parts) == 1 && parts[0] == "status" && req.Method == http.MethodGet:
+		return http.StatusOK, map[string]any{"wal_files": 0, "wal_entries": 0, "segments": 0}
+	case len(parts) >= 2 && parts[0] == "v1" && parts[1] == "vectors":
+		return s.handleVectors(req, parts[2:], body)
+	case len(parts) >= 2 && parts[0] == "v1" && parts[1] == "namespaces":
+		return s.handleNamespaces(req, parts[2:], body)
+	}
+	return errorResponse(http.StatusNotFound, "unsupported endpoint %s %s", req.Method, req.URL.Path)
+}
+
+func (s *Server) handleVectors(req *http.Request, rest []string, body []byte) (int, any) {
+	if len(rest) == 0 {
+		return errorResponse(http.StatusNotFound, "namespace is required")
+	}
+	ns := rest[0]
+
+	switch {
+	case len(rest) == 1 && req.Method == http.MethodPost:
+		var probe map[string]json.RawMessage
+		if err := json.Unmarshal(body, &probe); err != nil {
+			return errorResponse(http.StatusBadRequest, "invalid JSON: %v", err)
+		}
+		if _, ok := probe["vectors"]; ok {
+			return s.handleUpsert(ns, body)
+		}
+		return s.handleQuery(ns, body)
+	case len(rest) == 1 && req.Method == http.MethodGet:
+		query := req.URL.Query()
+		if ids, ok := query["ids"]; ok {
+			return s.handleFetch(ns, ids)
+		}
+		return s.handleScroll(ns, query.Get("limit"), query.Get("cursor"))
+	case len(rest) == 1 && req.Method == http.MethodDelete:
+		return s.handleDelete(ns, body)
+	case len(rest) == 1 && req.Method == http.MethodPatch:
+		var update struct {
+			Updates []struct {
+				ID         string              `json:"id"`
+				Attributes tidepool.Attributes `json:"attributes"`
+			} `json:"updates"`
+		}
+		if err := json.Unmarshal(body, &update); err != nil {
+			return errorResponse(http.StatusBadRequest, "invalid JSON: %v", err)
+		}
+		for _, u := range update.Updates {
+			if status, payload := s.updateAttributes(ns, u.ID, u.Attributes); status != http.StatusOK {
+				return status, payload
+			}
+		}
+		return http.StatusOK, map[string]any{}
+	case len(rest) == 2 && req.Method == http.MethodHead:
+		if n := s.namespaces[ns]; n != nil {
+			if _, ok := n.docs[rest[1]]; ok {
+				return http.StatusOK, nil
+			}
+		}
+		return errorResponse(http.StatusNotFound, "vector %q not found", rest[1])
+	case len(rest) == 2 && req.Method == http.MethodPatch:
+		var update struct {
+			Attributes tidepool.Attributes `json:"attributes"`
+		}
+		if err := json.Unmarshal(body, &update); err != nil {
+			return errorResponse(http.StatusBadRequest, "invalid JSON: %v", err)
+		}
+		return s.updateAttributes(ns, rest[1], update.Attributes)
+	case len(rest) == 2 && rest[1] == "count" && req.Method == http.MethodPost:
+		var count struct {
+			Filters tidepool.Attributes `json:"filters"`
+		}
+		if len(body) > 0 {
+			if err := json.Unmarshal(body, &count); err != nil {
+				return errorResponse(http.StatusBadRequest, "invalid JSON: %v", err)
+			}
+		}
+		n := 0
+		if existing := s.namespaces[ns]; existing != nil {
+			for _, doc := range existing.docs {
+				if matches(doc.Attributes, count.Filters) {
+					n++
+				}
+			}
+		}
+		return http.StatusOK, map[string]any{"count": n}
+	}
+	return errorResponse(http.StatusNotFound, "unsupported endpoint %s %s", req.Method, req.URL.Path)
+}
+
+func (s *Server) handleUpsert(ns string, body []byte) (int, any) {
+	var req struct {
+		Vectors []tidepool.Document `json:"vectors"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return errorResponse(http.StatusBadRequest, "invalid JSON: %v", err)
+	}
+	if err := s.upsert(ns, req.Vectors); err != nil {
+		var dimErr *dimensionError
+		if errors.As(err, &dimErr) {
+			return dimErr.response()
+		}
+		return errorResponse(http.StatusBadRequest, "%v", err)
+	}
+	return http.StatusOK, map[string]any{}
+}
+
+// upsert stores docs after a JSON round trip so attribute values have the
+// same types as decoded filters. s.mu must be held.
+func (s *Server) upsert(ns string, docs []tidepool.Document) error {
+	data, err := json.Marshal(docs)
+	if err != nil {
+		return err
+	}
+	var normalized []tidepool.Document
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return err
+	}
+
+	n := s.namespaces[ns]
+	if n == nil {
+		n = &namespace{docs: make(map[string]tidepool.Document)}
+	}
+	for _, doc := range normalized {
+		if doc.ID == "" {
+			return fmt.Errorf("document id is required")
+		}
+		if len(doc.Vector) == 0 {
+			return fmt.Errorf("document %q has no vector", doc.ID)
+		}
+		if n.dimensions == 0 {
+			n.dimensions = len(doc.Vector)
+		} else if len(doc.Vector) != n.dimensions {
+			return &dimensionError{what: fmt.Sprintf("document %q", doc.ID), expected: n.dimensions, got: len(doc.Vector)}
+		}
+	}
+	for _, doc := range normalized {
+		n.docs[doc.ID] = doc
+	}
+	s.namespaces[ns] = n
+	return nil
+}
+
+func (s *Server) updateAttributes(ns, id string, attrs tidepool.Attributes) (int, any) {
+	n := s.namespaces[ns]
+	if n == nil {
+		return errorResponse(http.StatusNotFound, "namespace %q not found", ns)
+	}
+	doc, ok := n.docs[id]
+	if !ok {
+		return errorResponse(http.StatusNotFound, "vector %q not found", id)
+	}
+	doc.Attributes = attrs
+	n.docs[id] = doc
+	return http.StatusOK, map[string]any{}
+}
+
+func (s *Server) handleQuery(ns string, body []byte) (int, any) {
+	var req struct {
+		Vector            tidepool.Vector     `json:"vector"`
+		Mode              string              `json:"mode"`
+		TopK              int                 `json:"top_k"`
+		IncludeVectors    *bool               `json:"include_vectors"`
+		IncludeAttributes []string            `json:"include_attributes"`
+		Filters           tidepool.Attributes `json:"filters"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return errorResponse(http.StatusBadRequest, "invalid JSON: %v", err)
+	}
+	if req.Mode != "" && req.Mode != string(tidepool.QueryModeVector) {
+		return errorResponse(http.StatusBadRequest, "mode %q is not supported by tidepooltest", req.Mode)
+	}
+	if len(req.Vector) == 0 {
+		return errorResponse(http.StatusBadRequest, "vector is required")
+	}
+	topK := req.TopK
+	if topK <= 0 {
+		topK = defaultTopK
+	}
+
+	results := []tidepool.VectorResult{}
+	if n := s.namespaces[ns]; n != nil {
+		if len(req.Vector) != n.dimensions {
+			return (&dimensionError{what: "query", expected: n.dimensions, got: len(req.Vector)}).response()
+		}
+		for _, doc := range n.docs {
+			if !matches(doc.Attributes, req.Filters) {
+				continue
+			}
+			result := tidepool.VectorResult{
+				ID:         doc.ID,
+				Score:      cosineDistance(req.Vector, doc.Vector),
+				Attributes: project(doc.Attributes, req.IncludeAttributes),
+			}
+			if req.IncludeVectors != nil && *req.IncludeVectors {
+				result.Vector = doc.Vector
+			}
+			results = append(results, result)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score < results[j].Score
+		}
+		return results[i].ID < results[j].ID
+	})
+	if len(results) > topK {
+		results = results[:topK]
+	}
+	return http.StatusOK, map[string]any{"namespace": ns, "results": results}
+}
+
+func (s *Server) handleFetch(ns string, ids []string) (int, any) {
+	results := []tidepool.VectorResult{}
+	if n := s.namespaces[ns]; n != nil {
+		for _, id := range ids {
+			if doc, ok := n.docs[id]; ok {
+				results = append(results, tidepool.VectorResult{ID: doc.ID, Vector: doc.Vector, SparseVector: doc.SparseVector, Text: doc.Text, Attributes: doc.Attributes})
+			}
+		}
+	}
+	return http.StatusOK, map[string]any{"namespace": ns, "results": results}
+}
+
+// handleScroll lists documents in ID order. The cursor is the last ID of the
+// previous page.
+func (s *Server) handleScroll(ns, limit, cursor string) (int, any) {
+	n := s.namespaces[ns]
+	if n == nil {
+		return errorResponse(http.StatusNotFound, "namespace %q not found", ns)
+	}
+	size := defaultScrollLimit
+	if limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed <= 0 {
+			return errorResponse(http.StatusBadRequest, "invalid limit %q", limit)
+		}
+		size = parsed
+	}
+
+	ids := make([]string, 0, len(n.docs))
+	for id := range n.docs {
+		if id > cursor {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	next := ""
+	if len(ids) > size {
+		ids = ids[:size]
+		next = ids[size-1]
+	}
+	results := make([]tidepool.VectorResult, len(ids))
+	for i, id := range ids {
+		doc := n.docs[id]
+		results[i] = tidepool.VectorResult{ID: doc.ID, Vector: doc.Vector, SparseVector: doc.SparseVector, Text: doc.Text, Attributes: doc.Attributes}
+	}
+	return http.StatusOK, map[string]any{"namespace": ns, "results": results, "next_cursor": next}
+}
+
+func (s *Server) handleDelete(ns string, body []byte) (int, any) {
+	var req struct {
+		IDs     []string            `json:"ids"`
+		Filters tidepool.Attributes `json:"filters"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return errorResponse(http.StatusBadRequest, "invalid JSON: %v", err)
+	}
+	n := s.namespaces[ns]
+	if n == nil {
+		return http.StatusOK, map[string]any{"deleted": 0}
+	}
+	deleted := 0
+	if req.IDs != nil {
+		for _, id := range req.IDs {
+			if _, ok := n.docs[id]; ok {
+				delete(n.docs, id)
+				deleted++
+			}
+		}
+		return http.StatusOK, map[string]any{"deleted": deleted}
+	}
+	for id, doc := range n.docs {
+		if matches(doc.Attributes, req.Filters) {
+			delete(n.docs, id)
+			deleted++
+		}
+	}
+	return http.StatusOK, map[string]any{"deleted": deleted}
+}
+
+func (s *Server) handleNamespaces(req *http.Request, rest []string, body []byte) (int, any) {
+	switch {
+	case len(rest) == 0 && req.Method == http.MethodGet:
+		names := make([]string, 0, len(s.namespaces))
+		for name := range s.namespaces {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		infos := make([]tidepool.NamespaceInfo, len(names))
+		for i, name := range names {
+			infos[i] = s.namespaceInfo(name)
+		}
+		return http.StatusOK, map[string]any{"namespaces": infos}
+	case len(rest) == 0 && req.Method == http.MethodPost:
+		var create struct {
+			Namespace  string `json:"namespace"`
+			Dimensions int    `json:"dimensions"`
+		}
+		if err := json.Unmarshal(body, &create); err != nil {
+			return errorResponse(http.StatusBadRequest, "invalid JSON: %v", err)
+		}
+		if create.Namespace == "" {
+			return errorResponse(http.StatusBadRequest, "namespace is required")
+		}
+		if _, ok := s.namespaces[create.Namespace]; ok {
+			return errorResponse(http.StatusConflict, "namespace %q already exists", create.Namespace)
+		}
+		s.namespaces[create.Namespace] = &namespace{
+			dimensions: create.Dimensions,
+			docs:       make(map[string]tidepool.Document),
+		}
+		return http.StatusCreated, map[string]any{}
+	case len(rest) == 1 && req.Method == http.MethodGet:
+		if _, ok := s.namespaces[rest[0]]; !ok {
+			return errorResponse(http.StatusNotFound, "namespace %q not found", rest[0])
+		}
+		return http.StatusOK, s.namespaceInfo(rest[0])
+	case len(rest) == 1 && req.Method == http.MethodDelete:
+		if _, ok := s.namespaces[rest[0]]; !ok {
+			return errorResponse(http.StatusNotFound, "namespace %q not found", rest[0])
+		}
+		delete(s.namespaces, rest[0])
+		return http.StatusOK, map[string]any{}
+	}
+	return errorResponse(http.StatusNotFound, "unsupported endpoint %s %s", req.Method, req.URL.Path)
+}
+
+func (s *Server) namespaceInfo(name string) tidepool.NamespaceInfo {
+	n := s.namespaces[name]
+	return tidepool.NamespaceInfo{
+		Namespace:   name,
+		ApproxCount: int64(len(n.docs)),
+		Dimensions:  n.dimensions,
+	}
+}
+
+// matches reports whether attrs contains every key in filters with an equal
+// value.
+func matches(attrs, filters tidepool.Attributes) bool {
+	for key, want := range filters {
+		got, ok := attrs[key]
+		if !ok || !reflect.DeepEqual(got, want) {
+			return false
+		}
+	}
+	return true
+}
+
+// cosineDistance returns 1 - cosine similarity, or 1 when either vector is
+// zero.
+func cosineDistance(a, b tidepool.Vector) float32 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 1
+	}
+	return float32(1 - dot/(math.Sqrt(normA)*math.Sqrt(normB)))
+}
+
+// dimensionError is a vector whose length differs from its namespace's.
+type dimensionError struct {
+	what     string
+	expected int
+	got      int
+}
+
+func (e *dimensionError) Error() string {
+	return fmt.Sprintf("%s has %d dimensions, expected %d", e.what, e.got, e.expected)
+}
+
+// response renders e the way the server reports dimension mismatches.
+func (e *dimensionError) response() (int, any) {
+	return http.StatusBadRequest, map[string]any{
+		"error":   e.Error(),
+		"code":    "DIM_MISMATCH",
+		"details": map[string]int{"expected": e.expected, "got": e.got},
+	}
+}
+
+func errorResponse(status int, format string, args ...any) (int, any) {
+	return status, map[string]string{"error": fmt.Sprintf(format, args...)}
+}
+
+// project returns the attributes named in keys, or all of attrs when keys is
+// empty.
+func project(attrs tidepool.Attributes, keys []string) tidepool.Attributes {
+	if len(keys) == 0 || attrs == nil {
+		return attrs
+	}
+	projected := tidepool.Attributes{}
+	for _, key := range keys {
+		if value, ok := attrs[key]; ok {
+			projected[key] = value
+		}
+	}
+	return projected
+}
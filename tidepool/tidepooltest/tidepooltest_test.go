@@ -0,0 +1,224 @@
+package tidepooltest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/milannair/tidepool-go/tidepool"
+)
+
+func TestServerUpsertQueryAndRecord(t *testing.T) {
+	ctx := context.Background()
+	srv := NewServer()
+	if err := srv.Seed("products", tidepool.Document{ID: "seeded", Vector: tidepool.Vector{0, 1}, Attributes: tidepool.Attributes{"kind": "b"}}); err != nil {
+		t.Fatalf("seed failed: %v", err)
+	}
+	client := tidepool.New(tidepool.WithHTTPClient(srv.Client()), tidepool.WithDefaultNamespace("products"))
+
+	err := client.Upsert(ctx, []tidepool.Document{
+		{ID: "x", Vector: tidepool.Vector{1, 0}, Attributes: tidepool.Attributes{"kind": "a"}},
+		{ID: "xy", Vector: tidepool.Vector{1, 1}, Attributes: tidepool.Attributes{"kind": "a"}},
+	}, nil)
+	if err != nil {
+		t.Fatalf("upsert failed: %v", err)
+	}
+	if docs := srv.Documents("products"); len(docs) != 3 || docs[0].ID != "seeded" {
+		t.Fatalf("unexpected stored documents: %+v", docs)
+	}
+
+	resp, err := client.Query(ctx, tidepool.Vector{1, 0}, &tidepool.QueryOptions{TopK: 2})
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if len(resp.Results) != 2 || resp.Results[0].ID != "x" || resp.Results[1].ID != "xy" {
+		t.Fatalf("unexpected results: %+v", resp.Results)
+	}
+	if resp.Results[0].Score > 1e-6 {
+		t.Fatalf("expected exact match to have zero distance, got %v", resp.Results[0].Score)
+	}
+
+	resp, err = client.Query(ctx, tidepool.Vector{1, 0}, &tidepool.QueryOptions{Filters: tidepool.Attributes{"kind": "b"}})
+	if err != nil {
+		t.Fatalf("filtered query failed: %v", err)
+	}
+	if len(resp.Results) != 1 || resp.Results[0].ID != "seeded" {
+		t.Fatalf("unexpected filtered results: %+v", resp.Results)
+	}
+
+	resp, err = client.Query(ctx, tidepool.Vector{0, 1}, &tidepool.QueryOptions{TopK: 1, IncludeAttributes: []string{"missing"}})
+	if err != nil || len(resp.Results) != 1 || len(resp.Results[0].Attributes) != 0 {
+		t.Fatalf("expected projected attributes to be empty, got %+v: %v", resp, err)
+	}
+
+	if _, err := client.Query(ctx, nil, &tidepool.QueryOptions{Text: "hello"}); !tidepool.IsValidationError(err) {
+		t.Fatalf("expected text queries to be rejected, got %v", err)
+	}
+
+	requests := srv.Requests()
+	first := requests[0]
+	if first.Method != http.MethodPost || first.Path != "/v1/vectors/products" {
+		t.Fatalf("unexpected first request: %s %s", first.Method, first.Path)
+	}
+	var body struct {
+		Vectors []tidepool.Document `json:"vectors"`
+	}
+	if err := json.Unmarshal(first.Body, &body); err != nil || len(body.Vectors) != 2 {
+		t.Fatalf("expected recorded upsert body, got %s", first.Body)
+	}
+}
+
+func TestServerNamespacesFetchAndDelete(t *testing.T) {
+	ctx := context.Background()
+	srv := NewServer()
+	client := tidepool.New(tidepool.WithHTTPClient(srv.Client()))
+
+	if err := client.CreateNamespace(ctx, "docs", &tidepool.CreateNamespaceOptions{Dimensions: 2}); err != nil {
+		t.Fatalf("create namespace failed: %v", err)
+	}
+	if err := client.CreateNamespace(ctx, "docs", nil); !tidepool.IsConflictError(err) {
+		t.Fatalf("expected conflict, got %v", err)
+	}
+	err := client.Upsert(ctx, []tidepool.Document{{ID: "a", Vector: tidepool.Vector{1, 2, 3}}}, &tidepool.UpsertOptions{Namespace: "docs"})
+	if expected, actual, ok := tidepool.IsDimensionMismatch(err); !ok || expected != 2 || actual != 3 {
+		t.Fatalf("expected dimension mismatch 2 vs 3, got %v", err)
+	}
+	if err := client.Upsert(ctx, []tidepool.Document{{ID: "a", Vector: tidepool.Vector{1, 2}}}, &tidepool.UpsertOptions{Namespace: "docs"}); err != nil {
+		t.Fatalf("upsert failed: %v", err)
+	}
+
+	info, err := client.GetNamespace(ctx, "docs")
+	if err != nil || info.ApproxCount != 1 || info.Dimensions != 2 {
+		t.Fatalf("unexpected namespace info %+v: %v", info, err)
+	}
+
+	results, err := client.Fetch(ctx, []string{"a", "missing"}, &tidepool.FetchOptions{Namespace: "docs"})
+	if !tidepool.IsNotFoundError(err) || len(results) != 1 || results[0].ID != "a" {
+		t.Fatalf("expected partial fetch with not found error, got %+v: %v", results, err)
+	}
+
+	if ok, err := client.Exists(ctx, "a", &tidepool.FetchOptions{Namespace: "docs"}); err != nil || !ok {
+		t.Fatalf("expected a to exist: %v", err)
+	}
+
+	if err := srv.Seed("docs", tidepool.Document{ID: "b", Vector: tidepool.Vector{3, 4}}, tidepool.Document{ID: "c", Vector: tidepool.Vector{5, 6}}); err != nil {
+		t.Fatalf("seed failed: %v", err)
+	}
+	scroll, err := client.Scroll(ctx, "docs", tidepool.ScrollOptions{PageSize: 2})
+	if err != nil {
+		t.Fatalf("scroll failed: %v", err)
+	}
+	var scrolled []string
+	for doc, err := range scroll {
+		if err != nil {
+			t.Fatalf("scroll page failed: %v", err)
+		}
+		scrolled = append(scrolled, doc.ID)
+	}
+	if len(scrolled) != 3 || scrolled[0] != "a" || scrolled[2] != "c" {
+		t.Fatalf("expected to scroll a, b, c, got %v", scrolled)
+	}
+	if err := client.Delete(ctx, []string{"b", "c"}, &tidepool.DeleteOptions{Namespace: "docs"}); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+
+	if err := client.Delete(ctx, []string{"a"}, &tidepool.DeleteOptions{Namespace: "docs"}); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if docs := srv.Documents("docs"); len(docs) != 0 {
+		t.Fatalf("expected document to be deleted, got %+v", docs)
+	}
+
+	if err := client.DeleteNamespace(ctx, "docs"); err != nil {
+		t.Fatalf("delete namespace failed: %v", err)
+	}
+	namespaces, err := client.ListNamespaces(ctx)
+	if err != nil || len(namespaces) != 0 {
+		t.Fatalf("expected no namespaces, got %+v: %v", namespaces, err)
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	client := tidepool.New(tidepool.WithHTTPClient(NewClient()))
+	health, err := client.Health(context.Background(), "query")
+	if err != nil || health.Status != "ok" {
+		t.Fatalf("unexpected health %+v: %v", health, err)
+	}
+}
+
+// failAfter passes the first n upserts through to the fake and fails the rest.
+type failAfter struct {
+	srv *Server
+	n   int
+}
+
+func (f *failAfter) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method == http.MethodPost && strings.HasPrefix(req.URL.Path, "/v1/vectors/") {
+		if f.n == 0 {
+			return nil, errors.New("connection reset")
+		}
+		f.n--
+	}
+	return f.srv.RoundTrip(req)
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	src := NewServer()
+	var seeded []tidepool.Document
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		seeded = append(seeded, tidepool.Document{ID: id, Vector: tidepool.Vector{1, 2}, Attributes: tidepool.Attributes{"id": id}})
+	}
+	if err := src.Seed("tenant", seeded...); err != nil {
+		t.Fatalf("seed failed: %v", err)
+	}
+	srcClient := tidepool.New(tidepool.WithHTTPClient(src.Client()))
+
+	dst := NewServer()
+	dstClient := tidepool.New(tidepool.WithHTTPClient(dst.Client()))
+	var checkpoints []string
+	copied, err := tidepool.Migrate(ctx, srcClient, dstClient, "tenant", tidepool.MigrateOptions{
+		DestNamespace:   "tenant-moved",
+		PageSize:        2,
+		BatchSize:       1,
+		Concurrency:     2,
+		CheckDimensions: true,
+		OnCheckpoint:    func(copied int64, cursor string) { checkpoints = append(checkpoints, cursor) },
+	})
+	if err != nil || copied != 5 {
+		t.Fatalf("expected 5 documents copied, got %d: %v", copied, err)
+	}
+	if got := dst.Documents("tenant-moved"); !reflect.DeepEqual(got, seeded) {
+		t.Fatalf("expected documents to be copied intact, got %+v", got)
+	}
+	if !reflect.DeepEqual(checkpoints, []string{"b", "d", ""}) {
+		t.Fatalf("unexpected checkpoints %q", checkpoints)
+	}
+
+	flaky := NewServer()
+	flakyClient := tidepool.New(tidepool.WithHTTPClient(&http.Client{Transport: &failAfter{srv: flaky, n: 1}}))
+	copied, err = tidepool.Migrate(ctx, srcClient, flakyClient, "tenant", tidepool.MigrateOptions{PageSize: 2})
+	if err == nil || copied != 2 || !strings.Contains(err.Error(), `resume from cursor "b"`) {
+		t.Fatalf("expected failure on the second page naming its cursor, got %d: %v", copied, err)
+	}
+	flakyClient = tidepool.New(tidepool.WithHTTPClient(flaky.Client()))
+	copied, err = tidepool.Migrate(ctx, srcClient, flakyClient, "tenant", tidepool.MigrateOptions{PageSize: 2, Cursor: "b"})
+	if err != nil || copied != 3 || len(flaky.Documents("tenant")) != 5 {
+		t.Fatalf("expected resume to copy the remaining 3 documents, got %d: %v", copied, err)
+	}
+
+	if err := dstClient.CreateNamespace(ctx, "narrow", &tidepool.CreateNamespaceOptions{Dimensions: 3}); err != nil {
+		t.Fatalf("create namespace failed: %v", err)
+	}
+	_, err = tidepool.Migrate(ctx, srcClient, dstClient, "tenant", tidepool.MigrateOptions{DestNamespace: "narrow", CheckDimensions: true})
+	if expected, actual, ok := tidepool.IsDimensionMismatch(err); !ok || expected != 3 || actual != 2 {
+		t.Fatalf("expected dimension mismatch 3 vs 2, got %v", err)
+	}
+	if docs := dst.Documents("narrow"); len(docs) != 0 {
+		t.Fatalf("expected nothing copied after a failed check, got %+v", docs)
+	}
+}
@@ -0,0 +1,150 @@
+package tidepool
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TransportOptions tunes the connection pool of the HTTP transport built by
+// New. Zero fields keep the net/http defaults.
+type TransportOptions struct {
+	// MaxIdleConns caps idle connections across all hosts.
+	MaxIdleConns int
+	// MaxIdleConnsPerHost caps idle connections kept per host. The net/http
+	// default of 2 is low for concurrent workloads and causes connection
+	// churn.
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost caps total connections per host, including those in
+	// use. Requests beyond the cap wait for a free connection.
+	MaxConnsPerHost int
+	// IdleConnTimeout closes idle connections after this long.
+	IdleConnTimeout time.Duration
+}
+
+// newTransport returns the transport for a client built by New, or nil to
+// use http.DefaultTransport when nothing is customized.
+func newTransport(cfg Config) http.RoundTripper {
+	if cfg.Transport == nil && cfg.TLSConfig == nil && !cfg.HTTP2 {
+		return nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.TLSConfig != nil {
+		transport.TLSClientConfig = cfg.TLSConfig.Clone()
+	}
+	if opts := cfg.Transport; opts != nil {
+		if opts.MaxIdleConns > 0 {
+			transport.MaxIdleConns = opts.MaxIdleConns
+		}
+		if opts.MaxIdleConnsPerHost > 0 {
+			transport.MaxIdleConnsPerHost = opts.MaxIdleConnsPerHost
+		}
+		if opts.MaxConnsPerHost > 0 {
+			transport.MaxConnsPerHost = opts.MaxConnsPerHost
+		}
+		if opts.IdleConnTimeout > 0 {
+			transport.IdleConnTimeout = opts.IdleConnTimeout
+		}
+	}
+	if !cfg.HTTP2 {
+		return transport
+	}
+
+	transport.ForceAttemptHTTP2 = true
+	h2c := transport.Clone()
+	h2c.Protocols = new(http.Protocols)
+	h2c.Protocols.SetUnencryptedHTTP2(true)
+	return &http2Transport{
+		tls:   transport,
+		h2c:   h2c,
+		hosts: make(map[string]bool),
+	}
+}
+
+// http2Transport sends plaintext requests as HTTP/2 with prior knowledge
+// (h2c) and HTTPS requests through a transport that negotiates HTTP/2 with
+// ALPN. h2c has no negotiation, so when the first h2c request to a host
+// fails and the same request succeeds over HTTP/1.1, the host is remembered
+// as HTTP/1.1-only. The request is sent again only when that cannot
+// duplicate it; see canFallBack.
+type http2Transport struct {
+	tls *http.Transport
+	h2c *http.Transport
+
+	mu sync.Mutex
+	// hosts records, per plaintext host, whether h2c worked (true) or the
+	// host fell back to HTTP/1.1 (false).
+	hosts map[string]bool
+}
+
+func (t *http2Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "http" {
+		return t.tls.RoundTrip(req)
+	}
+
+	t.mu.Lock()
+	h2c, known := t.hosts[req.URL.Host]
+	t.mu.Unlock()
+	if known && !h2c {
+		return t.tls.RoundTrip(req)
+	}
+
+	resp, err := t.h2c.RoundTrip(req)
+	if err == nil {
+		if !known {
+			t.remember(req.URL.Host, true)
+		}
+		return resp, nil
+	}
+	if known || req.Context().Err() != nil || !canFallBack(req, err) {
+		return nil, err
+	}
+
+	retry := req.Clone(req.Context())
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return nil, err
+		}
+		body, bodyErr := req.GetBody()
+		if bodyErr != nil {
+			return nil, err
+		}
+		retry.Body = body
+	}
+	resp, fallbackErr := t.tls.RoundTrip(retry)
+	if fallbackErr != nil {
+		return nil, err
+	}
+	t.remember(req.URL.Host, false)
+	return resp, nil
+}
+
+// canFallBack reports whether req, which failed over h2c with err, may be
+// sent again over HTTP/1.1. The h2c client writes the request without
+// waiting for the server to accept the connection, so a failure may come
+// after the server acted on it. Resending is safe for GET, HEAD, and OPTIONS
+// requests, and for any request the server answered in HTTP/1.1: such a
+// server rejected the h2c connection preface and never read the request.
+func canFallBack(req *http.Request, err error) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	// net/http does not export this error. Should its text change, writes
+	// fail instead of falling back, which is the safe direction.
+	return strings.Contains(err.Error(), "looked like an HTTP/1.1 header")
+}
+
+func (t *http2Transport) remember(host string, h2c bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.hosts[host] = h2c
+}
+
+// CloseIdleConnections closes idle connections of both transports.
+func (t *http2Transport) CloseIdleConnections() {
+	t.tls.CloseIdleConnections()
+	t.h2c.CloseIdleConnections()
+}
@@ -0,0 +1,233 @@
+package tidepool
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithTLSConfig(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(HealthResponse{Status: "ok"})
+	}))
+	defer srv.Close()
+
+	if _, err := New(WithQueryURL(srv.URL)).Health(context.Background(), "query"); err == nil {
+		t.Fatalf("expected certificate verification to fail without a custom CA")
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	tlsConfig := &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	client := New(
+		WithQueryURL(srv.URL),
+		WithTLSConfig(tlsConfig),
+		WithTransport(TransportOptions{MaxIdleConnsPerHost: 8}),
+	)
+	health, err := client.Health(context.Background(), "query")
+	if err != nil || health.Status != "ok" {
+		t.Fatalf("expected TLS health check to succeed, got %+v: %v", health, err)
+	}
+
+	transport := client.http.Transport.(*http.Transport)
+	if transport.MaxIdleConnsPerHost != 8 {
+		t.Fatalf("expected transport options to apply alongside TLS, got %d", transport.MaxIdleConnsPerHost)
+	}
+	if transport.TLSClientConfig == tlsConfig {
+		t.Fatalf("expected TLS config to be cloned")
+	}
+}
+
+func TestWithHTTP2(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		protos []string
+	)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		protos = append(protos, r.Proto)
+		mu.Unlock()
+		if r.Method == http.MethodPost {
+			var body struct {
+				Vectors []Document `json:"vectors"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil || len(body.Vectors) != 1 {
+				t.Errorf("expected upsert body to arrive intact, got %+v: %v", body, err)
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(HealthResponse{Status: "ok"})
+	})
+	lastProto := func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return protos[len(protos)-1]
+	}
+	ctx := context.Background()
+	docs := []Document{{ID: "a", Vector: Vector{1}}}
+
+	h2c := httptest.NewUnstartedServer(handler)
+	h2c.Config.Protocols = new(http.Protocols)
+	h2c.Config.Protocols.SetHTTP1(true)
+	h2c.Config.Protocols.SetUnencryptedHTTP2(true)
+	h2c.Start()
+	defer h2c.Close()
+
+	client := New(WithBaseURL(h2c.URL), WithHTTP2(true), WithTransport(TransportOptions{MaxConnsPerHost: 4}))
+	if _, err := client.Health(ctx, "query"); err != nil {
+		t.Fatalf("h2c health failed: %v", err)
+	}
+	if err := client.Upsert(ctx, docs, nil); err != nil {
+		t.Fatalf("h2c upsert failed: %v", err)
+	}
+	if got := lastProto(); got != "HTTP/2.0" {
+		t.Fatalf("expected h2c, got %s", got)
+	}
+
+	http1 := httptest.NewServer(handler)
+	defer http1.Close()
+	client = New(WithBaseURL(http1.URL), WithHTTP2(true))
+	if err := client.Upsert(ctx, docs, nil); err != nil {
+		t.Fatalf("expected fallback to HTTP/1.1, got %v", err)
+	}
+	if got := lastProto(); got != "HTTP/1.1" {
+		t.Fatalf("expected HTTP/1.1 after fallback, got %s", got)
+	}
+	transport := client.http.Transport.(*http2Transport)
+	if h2c, known := transport.hosts[strings.TrimPrefix(http1.URL, "http://")]; !known || h2c {
+		t.Fatalf("expected host to be remembered as HTTP/1.1-only")
+	}
+	if _, err := client.Health(ctx, "query"); err != nil {
+		t.Fatalf("health after fallback failed: %v", err)
+	}
+
+	tlsSrv := httptest.NewUnstartedServer(handler)
+	tlsSrv.EnableHTTP2 = true
+	tlsSrv.StartTLS()
+	defer tlsSrv.Close()
+	pool := x509.NewCertPool()
+	pool.AddCert(tlsSrv.Certificate())
+	client = New(WithBaseURL(tlsSrv.URL), WithHTTP2(true), WithTLSConfig(&tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}))
+	if _, err := client.Health(ctx, "query"); err != nil {
+		t.Fatalf("TLS health failed: %v", err)
+	}
+	if got := lastProto(); got != "HTTP/2.0" {
+		t.Fatalf("expected HTTP/2 over TLS, got %s", got)
+	}
+}
+
+func TestHTTP2FallbackOnlyWhenSafe(t *testing.T) {
+	// The listener reads whatever arrives and drops the connection, as a
+	// failing server might after acting on the request.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	defer ln.Close()
+	var conns atomic.Int32
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conns.Add(1)
+			_, _ = conn.Read(make([]byte, 4096))
+			_ = conn.Close()
+		}
+	}()
+
+	ctx := context.Background()
+	client := New(WithBaseURL("http://"+ln.Addr().String()), WithHTTP2(true))
+	if err := client.Upsert(ctx, []Document{{ID: "a", Vector: Vector{1}}}, nil); err == nil {
+		t.Fatalf("expected the upsert to fail")
+	}
+	if n := conns.Load(); n != 1 {
+		t.Fatalf("expected a failed h2c upsert not to be resent over HTTP/1.1, got %d connections", n)
+	}
+	if _, err := client.Health(ctx, "query"); err == nil {
+		t.Fatalf("expected the health check to fail")
+	}
+	if n := conns.Load(); n != 3 {
+		t.Fatalf("expected a failed h2c GET to be resent over HTTP/1.1, got %d connections", n)
+	}
+}
+
+func TestClientClose(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests int
+		closed   int
+	)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		_ = json.NewEncoder(w).Encode(HealthResponse{Status: "ok"})
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateClosed {
+			mu.Lock()
+			closed++
+			mu.Unlock()
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+	closedConns := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return closed
+	}
+	waitClosed := func(want int) {
+		deadline := time.Now().Add(2 * time.Second)
+		for closedConns() < want && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	ctx := context.Background()
+
+	client := New(WithQueryURL(srv.URL), WithTransport(TransportOptions{MaxIdleConnsPerHost: 4}))
+	if _, err := client.Health(ctx, "query"); err != nil {
+		t.Fatalf("health failed: %v", err)
+	}
+	if err := client.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	waitClosed(1)
+	if got := closedConns(); got != 1 {
+		t.Fatalf("expected the idle connection to be closed, got %d closed", got)
+	}
+	if _, err := client.Health(ctx, "query"); !errors.Is(err, ErrClientClosed) {
+		t.Fatalf("expected ErrClientClosed after Close, got %v", err)
+	}
+	if requests != 1 {
+		t.Fatalf("expected no requests after Close, got %d", requests)
+	}
+	if err := client.Close(); err != nil {
+		t.Fatalf("second close failed: %v", err)
+	}
+
+	custom := &http.Client{Transport: &http.Transport{}}
+	client = New(WithQueryURL(srv.URL), WithHTTPClient(custom))
+	if _, err := client.Health(ctx, "query"); err != nil {
+		t.Fatalf("health failed: %v", err)
+	}
+	_ = client.Close()
+	time.Sleep(20 * time.Millisecond)
+	if got := closedConns(); got != 1 {
+		t.Fatalf("expected a caller-supplied client's connections to be left open, got %d closed", got)
+	}
+	custom.CloseIdleConnections()
+}
@@ -0,0 +1,106 @@
+package tidepool
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// TypedDocument is a Document whose attributes are a struct (or any other
+// JSON-object type) instead of an Attributes map. T is marshaled with
+// encoding/json, so its JSON tags name the attributes on the wire.
+type TypedDocument[T any] struct {
+	ID           string
+	Vector       Vector
+	SparseVector *SparseVector
+	Text         string
+	Attributes   T
+	TTLSeconds   int
+}
+
+// TypedResult is a VectorResult whose attributes have been decoded into T.
+type TypedResult[T any] struct {
+	ID         string
+	Score      float32
+	Vector     Vector
+	Namespace  string
+	Attributes T
+}
+
+// UpsertTyped upserts docs through c.Upsert after marshaling each document's
+// attributes into Attributes. Attributes that do not marshal to a JSON
+// object (or null, for no attributes) fail with ErrValidation before
+// anything is sent.
+func UpsertTyped[T any](ctx context.Context, c *Client, docs []TypedDocument[T], opts *UpsertOptions) error {
+	untyped := make([]Document, len(docs))
+	for i, doc := range docs {
+		attrs, err := toAttributes(doc.Attributes)
+		if err != nil {
+			return fmt.Errorf("document %d: %w", i, err)
+		}
+		untyped[i] = Document{
+			ID:           doc.ID,
+			Vector:       doc.Vector,
+			SparseVector: doc.SparseVector,
+			Text:         doc.Text,
+			Attributes:   attrs,
+			TTLSeconds:   doc.TTLSeconds,
+		}
+	}
+	return c.Upsert(ctx, untyped, opts)
+}
+
+// QueryTyped runs c.Query and decodes the attributes of every result into T.
+func QueryTyped[T any](ctx context.Context, c *Client, vector Vector, opts *QueryOptions) ([]TypedResult[T], error) {
+	resp, err := c.Query(ctx, vector, opts)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeResults[T](resp.Results)
+}
+
+// DecodeResults decodes the attributes of results, as returned by Query,
+// Fetch, or any other method, into T. Attributes missing from a result leave
+// the corresponding fields of T at their zero values.
+func DecodeResults[T any](results []VectorResult) ([]TypedResult[T], error) {
+	typed := make([]TypedResult[T], len(results))
+	for i, result := range results {
+		typed[i] = TypedResult[T]{
+			ID:        result.ID,
+			Score:     result.Score,
+			Vector:    result.Vector,
+			Namespace: result.Namespace,
+		}
+		if len(result.Attributes) == 0 {
+			continue
+		}
+		data, err := json.Marshal(result.Attributes)
+		if err != nil {
+			return nil, fmt.Errorf("result %d: %w", i, err)
+		}
+		if err := json.Unmarshal(data, &typed[i].Attributes); err != nil {
+			return nil, fmt.Errorf("result %d: decode attributes: %w", i, err)
+		}
+	}
+	return typed, nil
+}
+
+// toAttributes marshals v and decodes it back as Attributes, keeping numbers
+// as json.Number so integers are sent exactly as T holds them.
+func toAttributes(v any) (Attributes, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("%w: marshal attributes: %v", ErrValidation, err)
+	}
+	if bytes.Equal(data, []byte("null")) {
+		return nil, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var attrs Attributes
+	if err := dec.Decode(&attrs); err != nil {
+		return nil, fmt.Errorf("%w: attributes must marshal to a JSON object", ErrValidation)
+	}
+	return attrs, nil
+}
@@ -0,0 +1,85 @@
+package tidepool
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type typedProduct struct {
+	Title string   `json:"title"`
+	Stock int64    `json:"stock"`
+	Tags  []string `json:"tags,omitempty"`
+}
+
+func TestTypedDocuments(t *testing.T) {
+	var stored []json.RawMessage
+	ingest := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Vectors []struct {
+				Attributes json.RawMessage `json:"attributes"`
+			} `json:"vectors"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		for _, doc := range body.Vectors {
+			stored = append(stored, doc.Attributes)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ingest.Close()
+	query := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		results := make([]map[string]any, len(stored))
+		for i, attrs := range stored {
+			results[i] = map[string]any{"id": "p1", "score": 0.5, "attributes": attrs}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"results": results})
+	}))
+	defer query.Close()
+
+	ctx := context.Background()
+	client := New(WithIngestURL(ingest.URL), WithQueryURL(query.URL), WithUseNumber(true))
+	want := typedProduct{Title: "Lamp", Stock: 1<<53 + 1, Tags: []string{"home"}}
+	docs := []TypedDocument[typedProduct]{{ID: "p1", Vector: Vector{1, 0}, Attributes: want}}
+	if err := UpsertTyped(ctx, client, docs, nil); err != nil {
+		t.Fatalf("upsert failed: %v", err)
+	}
+	if len(stored) != 1 || string(stored[0]) != `{"stock":9007199254740993,"tags":["home"],"title":"Lamp"}` {
+		t.Fatalf("expected attributes marshaled from struct tags, got %s", stored)
+	}
+
+	results, err := QueryTyped[typedProduct](ctx, client, Vector{1, 0}, nil)
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != "p1" || results[0].Score != 0.5 {
+		t.Fatalf("expected one result for p1, got %+v", results)
+	}
+	got := results[0].Attributes
+	if got.Title != want.Title || got.Stock != want.Stock || len(got.Tags) != 1 || got.Tags[0] != "home" {
+		t.Fatalf("expected attributes %+v, got %+v", want, got)
+	}
+
+	decoded, err := DecodeResults[typedProduct]([]VectorResult{{ID: "empty"}})
+	if err != nil || decoded[0].Attributes.Title != "" {
+		t.Fatalf("expected zero attributes for result without attributes, got %+v, %v", decoded, err)
+	}
+	if _, err := DecodeResults[typedProduct]([]VectorResult{{ID: "bad", Attributes: Attributes{"stock": "many"}}}); err == nil {
+		t.Fatalf("expected error decoding mismatched attribute type")
+	}
+
+	scalar := []TypedDocument[int]{{ID: "n", Vector: Vector{1, 0}, Attributes: 3}}
+	if err := UpsertTyped(ctx, client, scalar, nil); !IsValidationError(err) {
+		t.Fatalf("expected validation error for non-object attributes, got %v", err)
+	}
+	none := []TypedDocument[*typedProduct]{{ID: "nil", Vector: Vector{1, 0}}}
+	if err := UpsertTyped(ctx, client, none, nil); err != nil {
+		t.Fatalf("expected nil attributes to upsert without attributes, got %v", err)
+	}
+	if len(stored) != 2 || stored[1] != nil {
+		t.Fatalf("expected nil attributes to be omitted, got %s", stored[1])
+	}
+}
@@ -1,7 +1,10 @@
 package tidepool
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"slices"
 	"time"
 )
 
@@ -16,10 +19,14 @@ type Attributes map[string]AttrValue
 
 // Document represents a vector with metadata.
 type Document struct {
-	ID         string     `json:"id"`
-	Vector     Vector     `json:"vector,omitempty"`
-	Text       string     `json:"text,omitempty"`
-	Attributes Attributes `json:"attributes,omitempty"`
+	ID           string        `json:"id"`
+	Vector       Vector        `json:"vector,omitempty"`
+	SparseVector *SparseVector `json:"sparse_vector,omitempty"`
+	Text         string        `json:"text,omitempty"`
+	Attributes   Attributes    `json:"attributes,omitempty"`
+	// TTLSeconds, when positive, asks the server to expire the document that
+	// many seconds after it is written. Zero means no expiry.
+	TTLSeconds int `json:"ttl_seconds,omitempty"`
 }
 
 // VectorResult is a single query result.
@@ -28,25 +35,48 @@ type VectorResult struct {
 	Score      float32    `json:"score"`
 	Vector     Vector     `json:"vector,omitempty"`
 	Attributes Attributes `json:"attributes,omitempty"`
+	// SparseVector and Text are the document's stored sparse vector and
+	// text, when the server returns them.
+	SparseVector *SparseVector `json:"sparse_vector,omitempty"`
+	Text         string        `json:"text,omitempty"`
+	// Namespace is the namespace the result came from. QueryMulti always
+	// sets it; other methods leave it empty unless the server sends it.
+	Namespace string `json:"namespace,omitempty"`
 }
 
 // UnmarshalJSON supports both "score" (current) and legacy "dist"/"distance" fields.
 func (r *VectorResult) UnmarshalJSON(data []byte) error {
+	return r.decode(data, false)
+}
+
+// decode implements UnmarshalJSON. With useNumber, numbers in Attributes are
+// decoded as json.Number instead of float64.
+func (r *VectorResult) decode(data []byte, useNumber bool) error {
 	type alias struct {
-		ID         string     `json:"id"`
-		Vector     Vector     `json:"vector,omitempty"`
-		Attributes Attributes `json:"attributes,omitempty"`
-		Score      *float32   `json:"score"`
-		Dist       *float32   `json:"dist"`
-		Distance   *float32   `json:"distance"`
+		ID           string        `json:"id"`
+		Vector       Vector        `json:"vector,omitempty"`
+		Attributes   Attributes    `json:"attributes,omitempty"`
+		SparseVector *SparseVector `json:"sparse_vector,omitempty"`
+		Text         string        `json:"text,omitempty"`
+		Namespace    string        `json:"namespace,omitempty"`
+		Score        *float32      `json:"score"`
+		Dist         *float32      `json:"dist"`
+		Distance     *float32      `json:"distance"`
 	}
 	var decoded alias
-	if err := json.Unmarshal(data, &decoded); err != nil {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if useNumber {
+		dec.UseNumber()
+	}
+	if err := dec.Decode(&decoded); err != nil {
 		return err
 	}
 	r.ID = decoded.ID
 	r.Vector = decoded.Vector
 	r.Attributes = decoded.Attributes
+	r.SparseVector = decoded.SparseVector
+	r.Text = decoded.Text
+	r.Namespace = decoded.Namespace
 	switch {
 	case decoded.Score != nil:
 		r.Score = *decoded.Score
@@ -64,6 +94,64 @@ func (r *VectorResult) UnmarshalJSON(data []byte) error {
 type QueryResponse struct {
 	Results   []VectorResult `json:"results"`
 	Namespace string         `json:"namespace"`
+	// NextCursor is set when more results are available. Pass it as
+	// QueryOptions.Cursor to fetch the next page; empty means no more pages.
+	NextCursor string `json:"next_cursor,omitempty"`
+	// TookMS is the server-reported query time in milliseconds, or zero when
+	// the server does not report it.
+	TookMS float64 `json:"took_ms,omitempty"`
+	// RoundTrip is the client-observed duration of the HTTP exchange,
+	// including retries; MultiQuery reports the shared batch round trip on
+	// every response. RoundTrip minus TookMS approximates network and
+	// queueing overhead.
+	RoundTrip time.Duration `json:"-"`
+	// Warnings holds non-fatal problems the server reported with the query,
+	// such as a top_k larger than a segment. See WithWarningHandler.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// IsEmpty reports whether the query matched nothing: the response holds no
+// results. The query itself succeeded; a missing namespace is reported as
+// an ErrNotFound error instead.
+func (r *QueryResponse) IsEmpty() bool {
+	return r == nil || len(r.Results) == 0
+}
+
+// UpsertResponse reports the outcome of UpsertWithResult.
+type UpsertResponse struct {
+	// Upserted is the number of vectors written.
+	Upserted int `json:"upserted"`
+	// Namespace is the namespace the vectors were written to.
+	Namespace string `json:"namespace"`
+	// Warnings collects the non-fatal problems the server reported, over all
+	// batches. See WithWarningHandler.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// add accumulates the response body of one upsert batch of n documents. An
+// empty body, or one without an "upserted" count, counts all n.
+func (r *UpsertResponse) add(body []byte, n int) error {
+	if len(bytes.TrimSpace(body)) == 0 {
+		r.Upserted += n
+		return nil
+	}
+	var resp struct {
+		Upserted  *int     `json:"upserted"`
+		Namespace string   `json:"namespace"`
+		Warnings  []string `json:"warnings"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return decodeError("upsert", body, err)
+	}
+	if resp.Upserted != nil {
+		n = *resp.Upserted
+	}
+	r.Upserted += n
+	if resp.Namespace != "" {
+		r.Namespace = resp.Namespace
+	}
+	r.Warnings = append(r.Warnings, resp.Warnings...)
+	return nil
 }
 
 // DistanceMetric controls how distances are computed.
@@ -73,8 +161,36 @@ const (
 	DistanceCosine     DistanceMetric = "cosine_distance"
 	DistanceEuclidean  DistanceMetric = "euclidean_squared"
 	DistanceDotProduct DistanceMetric = "dot_product"
+	// DistanceEuclideanL2 is the true (non-squared) Euclidean distance. See
+	// VectorResult.EuclideanDistance for servers that answer it with squared
+	// distances.
+	DistanceEuclideanL2 DistanceMetric = "euclidean"
 )
 
+// distanceMetrics lists the metrics the server accepts.
+var distanceMetrics = []DistanceMetric{DistanceCosine, DistanceEuclidean, DistanceDotProduct, DistanceEuclideanL2}
+
+// Valid reports whether m is one of the known distance metrics. The empty
+// metric, which means "use the server default", is not itself valid, but is
+// accepted wherever a metric is optional.
+func (m DistanceMetric) Valid() bool {
+	return slices.Contains(distanceMetrics, m)
+}
+
+// HigherIsBetter reports whether larger scores are better matches under m.
+// It is true only for DistanceDotProduct, whose scores are signed
+// similarities; the other metrics, and the empty metric, score by distance,
+// where smaller is better.
+func (m DistanceMetric) HigherIsBetter() bool {
+	return m == DistanceDotProduct
+}
+
+// Embedder turns texts into vectors, returning one vector per text in the
+// same order.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([]Vector, error)
+}
+
 // QueryMode controls how the query is executed.
 type QueryMode string
 
@@ -100,8 +216,35 @@ type NamespaceInfo struct {
 	PendingCompaction *bool  `json:"pending_compaction,omitempty"`
 }
 
-// NamespaceStatus describes namespace compaction state.
-type NamespaceStatus struct {
+// NamespaceDescription combines a namespace's NamespaceInfo with its
+// CompactionStatus, as returned by DescribeNamespace.
+type NamespaceDescription struct {
+	Namespace         string     `json:"namespace"`
+	ApproxCount       int64      `json:"approx_count"`
+	Dimensions        int        `json:"dimensions"`
+	PendingCompaction bool       `json:"pending_compaction"`
+	LastRun           *time.Time `json:"last_run,omitempty"`
+	WALFiles          int        `json:"wal_files"`
+	WALEntries        int        `json:"wal_entries"`
+	Segments          int        `json:"segments"`
+	TotalVecs         int        `json:"total_vecs"`
+}
+
+// NamespaceList is a list of namespaces as returned by ListNamespaces.
+type NamespaceList []NamespaceInfo
+
+// Names returns just the namespace names, in order.
+func (l NamespaceList) Names() []string {
+	names := make([]string, len(l))
+	for i, info := range l {
+		names[i] = info.Namespace
+	}
+	return names
+}
+
+// CompactionStatus describes WAL and segment state, for the ingest service
+// as a whole or for a single namespace.
+type CompactionStatus struct {
 	LastRun    *time.Time `json:"last_run,omitempty"`
 	WALFiles   int        `json:"wal_files"`
 	WALEntries int        `json:"wal_entries"`
@@ -110,26 +253,73 @@ type NamespaceStatus struct {
 	Dimensions int        `json:"dimensions"`
 }
 
+// Pending reports whether WAL entries are still waiting to be compacted.
+func (s CompactionStatus) Pending() bool {
+	return s.WALEntries > 0
+}
+
+// NamespaceStatus describes namespace compaction state.
+//
+// Deprecated: Use CompactionStatus.
+type NamespaceStatus = CompactionStatus
+
 // IngestStatus describes ingest service state.
-type IngestStatus struct {
-	LastRun    *time.Time `json:"last_run,omitempty"`
-	WALFiles   int        `json:"wal_files"`
-	WALEntries int        `json:"wal_entries"`
-	Segments   int        `json:"segments"`
-	TotalVecs  int        `json:"total_vecs"`
-	Dimensions int        `json:"dimensions"`
+//
+// Deprecated: Use CompactionStatus.
+type IngestStatus = CompactionStatus
+
+// CompactWaitOptions configures CompactAndWait.
+type CompactWaitOptions struct {
+	// PollInterval is the delay between status checks. Zero uses the
+	// client's poll interval.
+	PollInterval time.Duration
+	// Timeout bounds the whole call, including the compaction request. Zero
+	// waits until the context is done.
+	Timeout time.Duration
+	// OnStatus, when set, is called with every status polled, e.g. to log
+	// the remaining WAL entries.
+	OnStatus func(status *CompactionStatus)
 }
 
 // HealthResponse contains service health information.
 type HealthResponse struct {
 	Service string `json:"service"`
 	Status  string `json:"status"`
+	// Features lists optional capabilities the service supports, such as
+	// FeatureQuantizedVectors. Older servers omit it.
+	Features []string `json:"features,omitempty"`
 }
 
 // UpsertOptions configures upsert behavior.
 type UpsertOptions struct {
 	Namespace      string
 	DistanceMetric DistanceMetric
+	// BatchSize overrides the client's upsert batch size for this call.
+	BatchSize int
+	// Normalize L2-normalizes every document vector before sending when
+	// DistanceMetric is DistanceCosine. The caller's documents are not modified.
+	Normalize bool
+	// OnProgress, when set, is called after each batch is acknowledged with
+	// the cumulative number of documents written and the total planned.
+	// UpsertStream cannot know the total in advance and passes -1. Calls are
+	// never concurrent, and done never decreases.
+	OnProgress func(done, total int)
+	// TTL sets an expiry on every document in the call that does not set its
+	// own Document.TTLSeconds. It is rounded up to whole seconds; zero means
+	// no expiry.
+	TTL time.Duration
+}
+
+// Bool returns a pointer to b, for optional fields such as
+// QueryOptions.IncludeVectors.
+func Bool(b bool) *bool {
+	return &b
+}
+
+// Float32 returns a pointer to f, for optional fields such as
+// QueryOptions.MinScore.
+func Float32(f float32) *float32 {
+	return &f
 }
 
 // QueryOptions configures query behavior.
@@ -137,18 +327,95 @@ type QueryOptions struct {
 	TopK           int
 	Namespace      string
 	DistanceMetric DistanceMetric
-	IncludeVectors bool
-	Filters        Attributes
-	EfSearch       int
-	NProbe         int
-	Text           string
-	Mode           QueryMode
-	Alpha          *float32
-	Fusion         FusionMode
-	RRFK           *int
+	// IncludeVectors controls whether results carry their vectors. Nil omits
+	// the field so the server default applies; use Bool(true) or Bool(false)
+	// to request explicitly.
+	IncludeVectors *bool
+	// IncludeAttributes limits the attributes returned with each result to
+	// these keys. Empty returns the server default.
+	IncludeAttributes []string
+	Filters           Attributes
+	// MinScore drops results that score worse than it. Scores are compared
+	// in the direction of the metric: for DistanceDotProduct, and for text
+	// and hybrid queries, it is a floor (keep Score >= MinScore); for the
+	// distance metrics DistanceCosine, DistanceEuclidean, and
+	// DistanceEuclideanL2 it is a ceiling (keep Score <= MinScore). A vector
+	// query without a known metric is treated as a distance. The threshold
+	// is sent as "min_score" and also applied to the decoded results, for
+	// servers that ignore it. It must be finite.
+	MinScore *float32
+	EfSearch int
+	NProbe   int
+	Text     string
+	Mode     QueryMode
+	// Alpha weights vector against text scores in hybrid blend fusion. Values
+	// outside [0, 1] are clamped by default; see WithAlphaClamp and
+	// WithStrictAlpha.
+	Alpha  *float32
+	Fusion FusionMode
+	RRFK   *int
+	// Cursor continues a previous query from QueryResponse.NextCursor.
+	Cursor string
+	// Rerank, when set, is called by Query, and for each MultiQuery
+	// response, with the query text and decoded results, and may reorder,
+	// filter, or rescore them. It is skipped when fewer than two results are
+	// returned.
+	Rerank func(ctx context.Context, query string, results []VectorResult) ([]VectorResult, error)
+	// DedupeBy, when set, collapses results that share a value at
+	// Attributes[DedupeBy], keeping the best-ranked one. Results without the
+	// attribute are always kept. Applied before Rerank, and to each
+	// MultiQuery response.
+	DedupeBy string
+	// SparseVector is sent with the dense vector for hybrid search.
+	SparseVector *SparseVector
+	// Vectors holds several query vectors for multi-vector (late-interaction)
+	// search, such as ColBERT token embeddings, and is sent as "vectors".
+	// When set it takes precedence over the vector passed to Query, which is
+	// then not sent. Each vector must be valid and all must have the same
+	// dimension. MultiQuery does not accept it.
+	Vectors []Vector
+	// Extra holds additional top-level fields merged into the query request
+	// body, for server parameters this client does not model yet. Keys that
+	// collide with a modeled field (such as "top_k" or "filters") are
+	// rejected with ErrValidation; set those through the typed options.
+	Extra Attributes
+}
+
+// ListOptions configures a paged listing.
+type ListOptions struct {
+	// Limit caps the number of entries per page. Zero uses the server default.
+	Limit int
+	// Cursor continues a previous listing from its next cursor.
+	Cursor string
+}
+
+// ScrollOptions configures Scroll.
+type ScrollOptions struct {
+	// PageSize caps the number of documents per request. Zero uses the
+	// server default.
+	PageSize int
+}
+
+// CreateNamespaceOptions configures namespace creation.
+type CreateNamespaceOptions struct {
+	Dimensions     int
+	DistanceMetric DistanceMetric
+}
+
+// FetchOptions configures fetch behavior.
+type FetchOptions struct {
+	Namespace string
 }
 
 // DeleteOptions configures delete behavior.
 type DeleteOptions struct {
 	Namespace string
+	// AllowDeleteAll permits DeleteByFilter with empty filters, which deletes
+	// every vector in the namespace.
+	AllowDeleteAll bool
+	// BatchSize overrides the client's batch size for this Delete call.
+	BatchSize int
+	// ContinueOnError makes Delete attempt every batch after a failure and
+	// return all batch errors joined, instead of stopping at the first.
+	ContinueOnError bool
 }
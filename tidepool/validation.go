@@ -3,20 +3,101 @@ package tidepool
 import (
 	"fmt"
 	"math"
+	"strings"
 )
 
 // ValidateVector validates vector contents and optional expected dimensions.
 func ValidateVector(v Vector, expectedDims int) error {
+	if err := validateVectorShape(v, expectedDims); err != nil {
+		return err
+	}
+	for i, val := range v {
+		if math.IsNaN(float64(val)) || math.IsInf(float64(val), 0) {
+			return fmt.Errorf("%w: invalid value at index %d", ErrValidation, i)
+		}
+	}
+	return nil
+}
+
+// validateVectorShape checks that v is non-empty and, when expectedDims is
+// positive, has that many components.
+func validateVectorShape(v Vector, expectedDims int) error {
 	if len(v) == 0 {
 		return fmt.Errorf("%w: vector cannot be empty", ErrValidation)
 	}
 	if expectedDims > 0 && len(v) != expectedDims {
-		return fmt.Errorf("%w: expected %d dimensions, got %d", ErrValidation, expectedDims, len(v))
+		return &dimensionError{expected: expectedDims, actual: len(v)}
 	}
-	for i, val := range v {
+	return nil
+}
+
+// validateVector is ValidateVector without the per-element scan when the
+// client was created with WithSkipVectorValidation.
+func (c *Client) validateVector(v Vector, expectedDims int) error {
+	if c.config.SkipVectorValidation {
+		return validateVectorShape(v, expectedDims)
+	}
+	return ValidateVector(v, expectedDims)
+}
+
+// ValidateSparseVector validates that sv is non-empty, has one value per
+// index, has no repeated indices, and holds only finite values.
+func ValidateSparseVector(sv *SparseVector) error {
+	if sv == nil || len(sv.Indices) == 0 {
+		return fmt.Errorf("%w: sparse vector cannot be empty", ErrValidation)
+	}
+	if len(sv.Indices) != len(sv.Values) {
+		return fmt.Errorf("%w: sparse vector has %d indices but %d values", ErrValidation, len(sv.Indices), len(sv.Values))
+	}
+	seen := make(map[uint32]struct{}, len(sv.Indices))
+	for i, index := range sv.Indices {
+		if _, ok := seen[index]; ok {
+			return fmt.Errorf("%w: duplicate sparse index %d", ErrValidation, index)
+		}
+		seen[index] = struct{}{}
+		val := sv.Values[i]
 		if math.IsNaN(float64(val)) || math.IsInf(float64(val), 0) {
-			return fmt.Errorf("%w: invalid value at index %d", ErrValidation, i)
+			return fmt.Errorf("%w: invalid sparse value at index %d", ErrValidation, index)
 		}
 	}
 	return nil
 }
+
+// validateMetric rejects unknown distance metrics. An empty metric selects
+// the server default and is accepted.
+func validateMetric(m DistanceMetric) error {
+	if m == "" || m.Valid() {
+		return nil
+	}
+	names := make([]string, len(distanceMetrics))
+	for i, known := range distanceMetrics {
+		names[i] = string(known)
+	}
+	return fmt.Errorf("%w: unknown distance metric %q (accepted: %s)", ErrValidation, m, strings.Join(names, ", "))
+}
+
+// validateDocument checks that doc has an ID and something to index: a dense
+// vector, a sparse vector, or text. Vectors are checked with ValidateVector
+// and ValidateSparseVector.
+func (c *Client) validateDocument(doc Document) error {
+	if doc.ID == "" {
+		return fmt.Errorf("%w: document id cannot be empty", ErrValidation)
+	}
+	if len(doc.Vector) == 0 && doc.SparseVector == nil && doc.Text == "" {
+		return fmt.Errorf("%w: document %q has no vector or text", ErrValidation, doc.ID)
+	}
+	if len(doc.Vector) > 0 {
+		if err := c.validateVector(doc.Vector, 0); err != nil {
+			return err
+		}
+	}
+	if doc.SparseVector != nil {
+		if err := ValidateSparseVector(doc.SparseVector); err != nil {
+			return err
+		}
+	}
+	if doc.TTLSeconds < 0 {
+		return fmt.Errorf("%w: TTLSeconds must be non-negative", ErrValidation)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package tidepool
+
+import "math"
+
+// Norm returns the L2 norm of v.
+func (v Vector) Norm() float32 {
+	var sum float64
+	for _, val := range v {
+		sum += float64(val) * float64(val)
+	}
+	return float32(math.Sqrt(sum))
+}
+
+// Normalize returns an L2-normalized copy of v. A zero vector is returned
+// unchanged rather than producing NaNs.
+func (v Vector) Normalize() Vector {
+	norm := float64(v.Norm())
+	if norm == 0 {
+		return v
+	}
+	out := make(Vector, len(v))
+	for i, val := range v {
+		out[i] = float32(float64(val) / norm)
+	}
+	return out
+}
+
+// NewVector converts vals to a Vector and validates it like ValidateVector:
+// it must be non-empty, and every value must be finite once converted to
+// float32, so values beyond the float32 range are rejected too. A []float32
+// needs no conversion; use Vector(vals) and ValidateVector.
+func NewVector(vals []float64) (Vector, error) {
+	v := make(Vector, len(vals))
+	for i, val := range vals {
+		v[i] = float32(val)
+	}
+	if err := ValidateVector(v, 0); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// MustVector is like NewVector but panics if vals is not a valid vector.
+func MustVector(vals []float64) Vector {
+	v, err := NewVector(vals)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// Float64s returns the values of v as a []float64.
+func (v Vector) Float64s() []float64 {
+	out := make([]float64, len(v))
+	for i, val := range v {
+		out[i] = float64(val)
+	}
+	return out
+}
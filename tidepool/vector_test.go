@@ -0,0 +1,86 @@
+package tidepool
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewVector(t *testing.T) {
+	v, err := NewVector([]float64{0.5, -2, 3})
+	if err != nil {
+		t.Fatalf("new vector failed: %v", err)
+	}
+	if len(v) != 3 || v[0] != 0.5 || v[1] != -2 || v[2] != 3 {
+		t.Fatalf("unexpected vector: %v", v)
+	}
+	if f := v.Float64s(); len(f) != 3 || f[0] != 0.5 || f[2] != 3 {
+		t.Fatalf("unexpected float64s: %v", f)
+	}
+
+	for _, vals := range [][]float64{nil, {1, math.NaN()}, {math.Inf(-1)}, {1e300}} {
+		if _, err := NewVector(vals); !IsValidationError(err) {
+			t.Fatalf("expected validation error for %v, got %v", vals, err)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected MustVector to panic on NaN")
+		}
+	}()
+	MustVector([]float64{math.NaN()})
+}
+
+func TestVectorNormalize(t *testing.T) {
+	v := Vector{3, 4}
+	if v.Norm() != 5 {
+		t.Fatalf("expected norm 5, got %v", v.Norm())
+	}
+	n := v.Normalize()
+	if math.Abs(float64(n[0])-0.6) > 1e-6 || math.Abs(float64(n[1])-0.8) > 1e-6 {
+		t.Fatalf("unexpected normalized vector: %v", n)
+	}
+	if v[0] != 3 {
+		t.Fatalf("expected original vector to be unchanged")
+	}
+
+	zero := Vector{0, 0}
+	if z := zero.Normalize(); z[0] != 0 || z[1] != 0 {
+		t.Fatalf("expected zero vector unchanged, got %v", z)
+	}
+}
+
+func TestUpsertNormalize(t *testing.T) {
+	var captured struct {
+		Vectors []Document `json:"vectors"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&captured); err != nil {
+			t.Fatalf("decode body: %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	client := New(WithIngestURL(srv.URL))
+	docs := []Document{{ID: "a", Vector: Vector{3, 4}}}
+	err := client.Upsert(context.Background(), docs, &UpsertOptions{Normalize: true, DistanceMetric: DistanceCosine})
+	if err != nil {
+		t.Fatalf("upsert failed: %v", err)
+	}
+	if got := captured.Vectors[0].Vector; math.Abs(float64(got.Norm())-1) > 1e-6 {
+		t.Fatalf("expected unit vector to be sent, got %v", got)
+	}
+	if docs[0].Vector[0] != 3 {
+		t.Fatalf("expected caller documents to be unchanged")
+	}
+
+	err = client.Upsert(context.Background(), []Document{{ID: "b", Vector: Vector{float32(math.NaN())}}}, &UpsertOptions{Normalize: true, DistanceMetric: DistanceCosine})
+	if !IsValidationError(err) {
+		t.Fatalf("expected validation error for NaN vector, got %v", err)
+	}
+}
@@ -0,0 +1,8 @@
+package tidepool
+
+// Version is the version of this client library. It is sent in the default
+// User-Agent header.
+const Version = "0.1.0"
+
+// defaultUserAgent is the User-Agent sent on every request.
+const defaultUserAgent = "tidepool-go/" + Version